@@ -1,5 +1,9 @@
 import requests
 import logging
+import platform
+from urllib.parse import urlsplit, urlunsplit
+
+CLIENT_APP_VERSION = '1.0'
 
 
 def send_csv_file(file_path, url):
@@ -55,3 +59,46 @@ def post_features(features_url, features):
         return True, 'Данные отправлены успешно!'
     else:
         return False, 'Ошибка отправки данных!'
+
+
+def session_close_url(sessions_url, video_id):
+    # ссылка на сессии содержит токен доступа в параметрах, поэтому путь добавляем до них
+    parts = urlsplit(sessions_url)
+    path = f"{parts.path.rstrip('/')}/{video_id}/close"
+    return urlunsplit((parts.scheme, parts.netloc, path, parts.query, parts.fragment))
+
+
+def open_session(sessions_url, video_id, fps=None):
+    try:
+        response = requests.post(sessions_url, json={'video_id': video_id,
+                                                     'fps': fps,
+                                                     'device_info': platform.platform(),
+                                                     'client_app_version': CLIENT_APP_VERSION})
+        if response.status_code == 201:
+            return True
+        logging.warning(f"Произошла ошибка при открытии сессии: {response.status_code}")
+    except Exception as e:
+        logging.error(f"Произошла ошибка: {str(e)}")
+    return False
+
+
+def close_session(sessions_url, video_id, frame_count):
+    try:
+        response = requests.post(session_close_url(sessions_url, video_id), json={'frame_count': frame_count})
+        if response.status_code == 200:
+            return True
+        logging.warning(f"Произошла ошибка при закрытии сессии: {response.status_code}")
+    except Exception as e:
+        logging.error(f"Произошла ошибка: {str(e)}")
+    return False
+
+
+def post_fatigue_events(fatigue_events_url, events):
+    try:
+        response = requests.post(fatigue_events_url, json={'events': events}, timeout=10)
+        if response.status_code == 201:
+            return True
+        logging.warning(f"Произошла ошибка при отправке событий усталости: {response.status_code}")
+    except Exception as e:
+        logging.error(f"Произошла ошибка: {str(e)}")
+    return False
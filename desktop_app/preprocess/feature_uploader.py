@@ -5,7 +5,7 @@ import math
 import csv
 import os
 import logging
-from api.http import send_csv_file
+from api.http import send_csv_file, close_session
 from PyQt5.QtCore import QThread, pyqtSignal
 
 mp_drawing = mp.solutions.drawing_utils
@@ -196,15 +196,19 @@ class FeatureUploader(QThread):
         self.filepath = ""
         self.is_tired = False
         self.url = ""
+        self.sessions_url = ""
         self.user_id = ""
+        self.frame_count = 0
 
-    def setup(self, video_id, filepath, is_tired, url, user_id):
+    def setup(self, video_id, filepath, is_tired, url, sessions_url, user_id, frame_count):
         super().__init__()
         self.video_id = video_id
         self.filepath = filepath
         self.is_tired = is_tired
         self.url = url
+        self.sessions_url = sessions_url
         self.user_id = user_id
+        self.frame_count = frame_count
 
     def run(self):
         header = ['video_id',
@@ -235,7 +239,10 @@ class FeatureUploader(QThread):
 
             logging.info("Создание... " + self.filepath)
 
+        # сессию открыл VideoRecorder при начале записи, закрываем ее после отправки признаков,
+        # так как сервис принимает признаки только в открытой сессии
         send_csv_file(csv_filename, self.url)
+        close_session(self.sessions_url, self.video_id, self.frame_count)
         # удаляем csv
         # delete_csv_file(csv_filename)
 
@@ -249,14 +256,16 @@ class FeatureUploaderForFineTune(QThread):
         super().__init__()
         self.is_tired = False
         self.url = ""
+        self.sessions_url = ""
         self.user_id = ""
         self.video_id = ""
         self.features = []
 
-    def setup(self, features, video_id, is_tired, url, user_id):
+    def setup(self, features, video_id, is_tired, url, sessions_url, user_id):
         super().__init__()
         self.is_tired = is_tired
         self.url = url
+        self.sessions_url = sessions_url
         self.user_id = user_id
         self.features = features
         self.video_id = video_id
@@ -287,7 +296,9 @@ class FeatureUploaderForFineTune(QThread):
                 else:
                     writer.writerow([self.video_id, *row, 0, self.user_id])
 
+        # сессию открыло окно предсказаний при начале записи, а запись остановлена на время отправки
         send_csv_file(csv_filename, self.url)
+        close_session(self.sessions_url, self.video_id, len(self.features))
         # удаляем csv
         # delete_csv_file(csv_filename)
 
@@ -1,14 +1,17 @@
 from PyQt5.QtCore import QThread, pyqtSignal
 from vidgear.gears import CamGear, WriteGear
+from api.http import open_session
+import logging
 import uuid
 import time
 
 
 class VideoRecorder(QThread):
-    finished = pyqtSignal(str, str)  # Сигнал о завершении записи
+    finished = pyqtSignal(str, str, int)  # Сигнал о завершении записи
 
-    def __init__(self, video_len_sec=15):
+    def __init__(self, sessions_url, video_len_sec=15):
         super().__init__()
+        self.sessions_url = sessions_url
         self.video_len_sec = video_len_sec
 
     def run(self):
@@ -23,8 +26,15 @@ class VideoRecorder(QThread):
         video_id = str(uuid.uuid4())
         filename = f"{video_id}.mp4"
 
+        # Сессия открывается вместе с началом записи: признаки видео принимаются только в открытой сессии
+        if not open_session(self.sessions_url, video_id, stream.framerate):
+            logging.error("Не удалось открыть сессию, запись видео отменена")
+            stream.stop()
+            return
+
         writer = WriteGear(output=filename)
 
+        frame_count = 0
         start = time.perf_counter()
         while True:
             frame = stream.read()
@@ -33,6 +43,7 @@ class VideoRecorder(QThread):
                 break
 
             writer.write(frame)
+            frame_count += 1
 
             stop = time.perf_counter()
             if stop - start > self.video_len_sec:
@@ -40,4 +51,4 @@ class VideoRecorder(QThread):
 
         stream.stop()
         writer.close()
-        self.finished.emit(video_id, filename)
+        self.finished.emit(video_id, filename, frame_count)
@@ -42,7 +42,9 @@ class LoginWindow(QWidget):
         password = self.password.text()
         success, message, data = http.perform_login(self.login_url, username, password)
         if success:
-            if 'face_model' in data['content']['model_urls']:
+            # Предсказывать можно и глобальной моделью, пока у пользователя нет персональной
+            models = data['content'].get('models') or {}
+            if 'face_model' in models:
                 self.main_window = PredictorWindow(data['content'])
             else:
                 self.main_window = MainDataUploaderWindow(data['content'])
@@ -69,7 +69,7 @@ class MainDataUploaderWindow(QWidget):
         self.timer = QTimer(self)
         self.timer.timeout.connect(self.ask_to_record_video)
         self.timer.start(60000000)  # Запуск таймера на каждый час (3600000 миллисекунд = 1 час)
-        self.video_recorder = VideoRecorder()
+        self.video_recorder = VideoRecorder(self.model_cfg['sessions']['face_model'])
         self.feature_uploader = FeatureUploader()
         self.video_recorder.finished.connect(self.ask_if_tired)
 
@@ -95,23 +95,24 @@ class MainDataUploaderWindow(QWidget):
         if not self.video_recorder.isRunning():
             self.video_recorder.start()
 
-    def ask_if_tired(self, video_id, filename):
+    def ask_if_tired(self, video_id, filename, frame_count):
         reply = QMessageBox.question(self, 'Состояние', 'Вы устали?',
                                      QMessageBox.Yes | QMessageBox.No, QMessageBox.No)
         user_id = self.model_cfg['user_id']
         upload_features_url = self.model_cfg['upload_features']['face_model']
+        sessions_url = self.model_cfg['sessions']['face_model']
 
         if reply == QMessageBox.Yes:
             tired_path = './videos/tired'
             shutil.move(filename, tired_path)
             self.feature_uploader.setup(video_id, os.path.join(tired_path, filename), True, upload_features_url,
-                                        user_id)
+                                        sessions_url, user_id, frame_count)
 
         else:
             awake_path = './videos/awake'
             shutil.move(filename, awake_path)
             self.feature_uploader.setup(video_id, os.path.join(awake_path, filename), False, upload_features_url,
-                                        user_id)
+                                        sessions_url, user_id, frame_count)
 
         self.update_count()
 
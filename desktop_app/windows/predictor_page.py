@@ -1,10 +1,13 @@
 from PyQt5.QtWidgets import QWidget, QLabel, QPushButton, QVBoxLayout, QMessageBox
 from xgboost_predictor.video_predictor import FaceXGBModel, FaceModelLoader
+from xgboost_predictor.fatigue_event_sender import FatigueEventSender
 from preprocess.feature_uploader import FeatureUploaderForFineTune
+from api.http import open_session, close_session
 import cv2
 from PyQt5.QtGui import QImage, QPixmap
 from PyQt5.QtCore import Qt
 import uuid
+from datetime import datetime, timezone
 
 
 class PredictorWindow(QWidget):
@@ -28,22 +31,37 @@ class PredictorWindow(QWidget):
 
         self.setLayout(self.layout)
 
-        face_model_url = cfg['model_urls']['face_model']
+        face_model = cfg['models']['face_model']
+        # Глобальная модель выдается, пока персональная модель пользователя не обучена
+        self.is_personalized = face_model['is_personalized']
         self.upload_features_url = cfg['upload_features']['face_model']
+        self.sessions_url = cfg['sessions']['face_model']
         self.user_id = cfg['user_id']
-        self.model_loader = FaceModelLoader(face_model_url)
+        # Сессия мониторинга, к которой относятся события усталости
+        self.monitoring_session_id = str(uuid.uuid4())
+        # Сессия текущей записи признаков, открывается при начале записи
+        self.recording_video_id = None
+        self.model_loader = FaceModelLoader(face_model['url'], face_model.get('sha256'))
         self.model_loader.loaded.connect(self.on_model_loaded)
         self.model_loader.start()
 
         self.feature_uploader = FeatureUploaderForFineTune()
         self.feature_uploader.finished.connect(self.continue_prediction)
 
+        self.fatigue_event_sender = FatigueEventSender(cfg['fatigue_events'])
+        self.fatigue_event_sender.start()
+
     def on_model_loaded(self, model):
         if model is not None:
-            self.label.setText('Model loaded, processing video...')
+            if self.is_personalized:
+                self.label.setText('Model loaded, processing video...')
+            else:
+                self.label.setText('Используется общая модель, отправьте свои признаки для обучения персональной')
             self.video_processor = FaceXGBModel(model)
             self.video_processor.predictionSignal.connect(self.update_prediction)
             self.video_processor.frameSignal.connect(self.update_frame)
+            self.video_processor.fatigueSignal.connect(self.record_fatigue_event)
+            self.video_processor.recordingSignal.connect(self.open_recording_session)
             self.video_processor.start()
         else:
             self.label.setText('Failed to load model.')
@@ -60,16 +78,48 @@ class PredictorWindow(QWidget):
     def continue_prediction(self):
         self.video_processor.set_continue()
 
+    def open_recording_session(self, fps):
+        video_id = str(uuid.uuid4())
+        if open_session(self.sessions_url, video_id, fps):
+            self.recording_video_id = video_id
+
     def upload_features(self, is_tired):
+        # Пауза останавливает запись, сессия закрывается после отправки признаков
         self.video_processor.set_pause()
+        video_id = self.recording_video_id
+        self.recording_video_id = None
+        if video_id is None:
+            self.label.setText('Сессия записи не открыта, признаки не отправлены')
+            self.continue_prediction()
+            return
+
         features = self.video_processor.get_last_features()
-        video_id = str(uuid.uuid4())
-        self.feature_uploader.setup(features, video_id, is_tired, self.upload_features_url, self.user_id)
+        self.feature_uploader.setup(features, video_id, is_tired, self.upload_features_url, self.sessions_url,
+                                    self.user_id)
         self.feature_uploader.start()
 
     def update_prediction(self, prediction):
         self.label.setText(prediction)  # Обновление текста метки на основе полученного предсказания
 
+    def record_fatigue_event(self, confidence):
+        self.fatigue_event_sender.add_event({
+            'session_id': self.monitoring_session_id,
+            'model_type': 'face_model',
+            'model_version': self.model_loader.model_version,
+            'confidence': confidence,
+            'occurred_at': datetime.now(timezone.utc).isoformat(),
+        })
+
+    def closeEvent(self, event):
+        # Отправляем накопившиеся события перед закрытием окна
+        self.fatigue_event_sender.stop()
+        self.fatigue_event_sender.wait(5000)
+        # Закрываем сессию записи, признаки которой не отправлялись
+        if self.recording_video_id is not None:
+            close_session(self.sessions_url, self.recording_video_id, 0)
+            self.recording_video_id = None
+        super().closeEvent(event)
+
     def update_frame(self, frame):
         rgb_image = cv2.cvtColor(frame, cv2.COLOR_BGR2RGB)
         h, w, ch = rgb_image.shape
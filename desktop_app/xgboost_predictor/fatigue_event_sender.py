@@ -0,0 +1,46 @@
+import queue
+
+from PyQt5.QtCore import QThread
+
+from api.http import post_fatigue_events
+
+
+# FatigueEventSender - отправляет зафиксированные события усталости в отдельном потоке,
+# чтобы запросы не задерживали обработку кадров
+class FatigueEventSender(QThread):
+    # Максимальное количество событий в одном запросе
+    batch_size = 100
+
+    def __init__(self, fatigue_events_url):
+        super().__init__()
+        self.fatigue_events_url = fatigue_events_url
+        self.events = queue.Queue()
+        self.running = True
+
+    # add_event - метод добавления события в очередь отправки
+    def add_event(self, event):
+        self.events.put(event)
+
+    # stop - метод остановки
+    def stop(self):
+        self.running = False
+
+    def run(self):
+        while self.running or not self.events.empty():
+            try:
+                events = [self.events.get(timeout=1)]
+            except queue.Empty:
+                continue
+
+            # Забираем накопившиеся события, чтобы отправить их одним запросом
+            while len(events) < self.batch_size and not self.events.empty():
+                events.append(self.events.get())
+
+            # Неотправленные события возвращаем в очередь и повторяем попытку позже
+            if not post_fatigue_events(self.fatigue_events_url, events):
+                for event in events:
+                    self.events.put(event)
+                if self.running:
+                    self.sleep(5)
+                else:
+                    break
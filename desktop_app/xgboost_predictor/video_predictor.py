@@ -1,4 +1,7 @@
 import time
+import hashlib
+import logging
+from collections import deque
 
 from preprocess.feature_uploader import (eye_feature, mouth_feature,
                                          perimeter, perimeter_feature,
@@ -16,13 +19,24 @@ import requests
 class FaceModelLoader(QThread):
     loaded = pyqtSignal(object)
 
-    def __init__(self, url):
+    def __init__(self, url, sha256=None):
         super().__init__()
         self.url = url
+        # Контрольная сумма файла модели из ответа на вход, если сервер ее знает
+        self.sha256 = sha256
+        # Версия модели - sha256 файла модели, передается в событиях усталости
+        self.model_version = None
 
     def run(self):
         response = requests.get(self.url)
         if response.status_code == 200:
+            model_version = hashlib.sha256(response.content).hexdigest()
+            if self.sha256 is not None and model_version != self.sha256:
+                logging.error(f"Контрольная сумма модели не совпадает: {model_version} != {self.sha256}")
+                self.loaded.emit(None)
+                return
+            self.model_version = model_version
+
             # Предполагаем, что модель сохранена в бинарном формате XGB
             filename = './models/face_model/model.xgb'
             with open(filename, 'wb') as f:
@@ -40,6 +54,10 @@ class FaceXGBModel(QThread):
     predictionSignal = pyqtSignal(str)
     # Сигнал для отправления захваченного кадра
     frameSignal = pyqtSignal(object)
+    # Сигнал о переходе в состояние усталости с уверенностью модели
+    fatigueSignal = pyqtSignal(float)
+    # Сигнал о начале записи с частотой кадров камеры: при запуске и после паузы
+    recordingSignal = pyqtSignal(float)
 
     # Конструктор
     def __init__(self, model, limited_array_size=16, buf_capacity=900):
@@ -48,6 +66,10 @@ class FaceXGBModel(QThread):
         self.limited_array_size = limited_array_size
         # Объявяем массив, которая хранит последние {limited_array_size} предсказаний
         self.check_awake = LimitedSizeArray(limited_array_size)
+        # Объявяем массив вероятностей усталости для тех же предсказаний
+        self.tired_probabilities = deque(maxlen=limited_array_size)
+        # Текущее состояние, событие усталости отправляется только при переходе в уставшее состояние
+        self.is_tired = False
 
         # Задаем модель
         self.face_model = model
@@ -82,14 +104,23 @@ class FaceXGBModel(QThread):
     def run(self):
         # Захватываем видео веб-камеры
         cap = cv2.VideoCapture(1)
+        fps = cap.get(cv2.CAP_PROP_FPS)
+        self.recordingSignal.emit(fps)
 
+        paused = False
         # Пока не остановили делаем цикл
         while self.running:
             # Если в режиме паузы ждем 2 секунды
             if self.pause is True:
+                paused = True
                 time.sleep(2)
                 continue
 
+            # После паузы запись начинается заново
+            if paused:
+                paused = False
+                self.recordingSignal.emit(fps)
+
             # Инициализируем класс face_mesh
             with mp_face_mesh.FaceMesh(
                     max_num_faces=1,
@@ -160,13 +191,22 @@ class FaceXGBModel(QThread):
 
                     # Сохраняем предсказание
                     self.check_awake.push(0 if prediction[0] < 0.5 else 1)
+                    self.tired_probabilities.append(1 - float(prediction[0]))
 
                     # Если все значения в check_awake = 0, то только тогда устанавливаем значение
                     # label = Текущее состояние: уставшее
                     label = 'Текущее состояние: не уставшее'
-                    if self.check_awake.count_zeros() == 0:
+                    is_tired = self.check_awake.count_zeros() == 0
+                    if is_tired:
                         label = 'Текущее состояние: уставшее'
 
+                    # Пока массив не заполнен предсказаниями, состояние не считается определенным
+                    if len(self.tired_probabilities) == self.limited_array_size:
+                        if is_tired and not self.is_tired:
+                            confidence = sum(self.tired_probabilities) / len(self.tired_probabilities)
+                            self.fatigueSignal.emit(confidence)
+                        self.is_tired = is_tired
+
                     # Отправляем текущее состояние на отрисовку
                     self.predictionSignal.emit(label)
 
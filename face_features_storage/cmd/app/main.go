@@ -1,6 +1,8 @@
 package main
 
 import (
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/cmd/commands/fatigue_alerts"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/cmd/commands/retention"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/cmd/commands/serve"
 	"github.com/urfave/cli/v2"
 	"log"
@@ -23,6 +25,14 @@ func main() {
 				Name:   "serve",
 				Action: serve.Action,
 			},
+			{
+				Name:   "retention",
+				Action: retention.Action,
+			},
+			{
+				Name:   "fatigue-alerts",
+				Action: fatigue_alerts.Action,
+			},
 		},
 	}
 
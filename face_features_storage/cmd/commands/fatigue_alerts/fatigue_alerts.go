@@ -0,0 +1,41 @@
+package fatigue_alerts
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"github.com/urfave/cli/v2"
+	"os/signal"
+	"syscall"
+)
+
+func Action(_ *cli.Context) error {
+	cfg := config.GetConfigFatigueAlerts()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	dbClient, err := postgresql.NewClient(context.Background(), cfg.ToDBConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer dbClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fatigueAlerts := workers.NewFatigueAlerts(
+		data.NewRepository(dbClient),
+		cfg.WebhookEvents,
+		cfg.ServiceToken,
+		cfg.ToRetryPolicy(),
+		cfg.FatigueAlertsTimeout,
+		cfg.FatigueAlertsBatchSize,
+		cfg.FatigueAlertsPollInterval,
+		l,
+	)
+
+	return fatigueAlerts.Run(ctx)
+}
@@ -0,0 +1,33 @@
+package retention
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"github.com/urfave/cli/v2"
+)
+
+func Action(_ *cli.Context) error {
+	cfg := config.GetConfigRetention()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	dbClient, err := postgresql.NewClient(context.Background(), cfg.ToDBConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer dbClient.Close()
+
+	retention := workers.NewRetention(
+		data.NewRepository(dbClient),
+		dbClient,
+		cfg.MaxAgeMonths,
+		cfg.SkipUntrainedUsers,
+		cfg.UntrainedUsersURL,
+		l)
+
+	return retention.Run(context.Background())
+}
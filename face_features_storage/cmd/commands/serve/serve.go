@@ -26,6 +26,7 @@ func Action(_ *cli.Context) error {
 	coreHandler := handlers.NewCoreHandler(
 		data.NewRepository(dbClient),
 		cfg.StorageHandler,
+		cfg.ServiceToken,
 		dbClient,
 		validate,
 		l)
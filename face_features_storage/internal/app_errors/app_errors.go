@@ -31,7 +31,7 @@ func (e AppError) Error() string {
 	}
 
 	if e.InternalError != nil {
-		e.InternalError.Error()
+		return e.InternalError.Error()
 	}
 
 	return "undefined error"
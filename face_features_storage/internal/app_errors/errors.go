@@ -53,4 +53,10 @@ var (
 		"wrong token",
 		7,
 		http.StatusUnauthorized)
+
+	ErrAlreadyExists = NewAppError(
+		"AlreadyExists",
+		"entity already exists",
+		8,
+		http.StatusConflict)
 )
@@ -45,6 +45,8 @@ type Config struct {
 	LoggerConfig
 	SwaggerConfig
 	StorageHandler string `env:"STORAGE_HANDLER_URL" env-default:"http://0.0.0.0:3391"`
+	// ServiceToken - общий токен внутренних сервисов, передается в заголовке X-Service-Token
+	ServiceToken string `env:"SERVICE_TOKEN" env-required:"true"`
 }
 
 var instance *Config
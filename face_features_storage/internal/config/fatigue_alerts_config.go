@@ -0,0 +1,71 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+	"time"
+)
+
+type FatigueAlertsConfig struct {
+	DBConfig
+	LoggerConfig
+
+	WebhookEvents string `env:"WEBHOOK_EVENTS_URL" env-default:"http://0.0.0.0:3391/api/v1/webhooks/events"`
+	// ServiceToken - общий токен внутренних сервисов, передается в заголовке X-Service-Token
+	ServiceToken string `env:"SERVICE_TOKEN" env-required:"true"`
+
+	FatigueAlertsTimeout        time.Duration `env:"FATIGUE_ALERTS_TIMEOUT" env-default:"10s"`
+	FatigueAlertsBatchSize      int           `env:"FATIGUE_ALERTS_BATCH_SIZE" env-default:"100"`
+	FatigueAlertsPollInterval   time.Duration `env:"FATIGUE_ALERTS_POLL_INTERVAL" env-default:"1s"`
+	FatigueAlertsRetryBaseDelay time.Duration `env:"FATIGUE_ALERTS_RETRY_BASE_DELAY" env-default:"5s"`
+	FatigueAlertsRetryMaxDelay  time.Duration `env:"FATIGUE_ALERTS_RETRY_MAX_DELAY" env-default:"5m"`
+}
+
+func (c FatigueAlertsConfig) ToDBConfig() postgresql.DBConfig {
+	return postgresql.DBConfig{
+		Port:                  c.DBPort,
+		Host:                  c.DBHost,
+		Name:                  c.DBName,
+		Password:              c.DBPassword,
+		Username:              c.DBUsername,
+		MaxConnectionAttempts: c.MaxConnectionAttempts,
+		AutoMigrate:           c.AutoMigrate,
+		MigrationsDir:         c.MigrationsDir,
+	}
+}
+
+func (c FatigueAlertsConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+func (c FatigueAlertsConfig) ToRetryPolicy() workers.FatigueAlertsRetryPolicy {
+	return workers.FatigueAlertsRetryPolicy{
+		BaseDelay: c.FatigueAlertsRetryBaseDelay,
+		MaxDelay:  c.FatigueAlertsRetryMaxDelay,
+	}
+}
+
+var instanceFatigueAlerts *FatigueAlertsConfig
+var onceFatigueAlerts sync.Once
+
+func GetConfigFatigueAlerts() *FatigueAlertsConfig {
+	onceFatigueAlerts.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceFatigueAlerts = &FatigueAlertsConfig{}
+		if err := cleanenv.ReadEnv(instanceFatigueAlerts); err != nil {
+			help, _ := cleanenv.GetDescription(instanceFatigueAlerts, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+	})
+
+	return instanceFatigueAlerts
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+)
+
+type RetentionConfig struct {
+	DBConfig
+	LoggerConfig
+
+	// MaxAgeMonths - секции признаков старше данного количества месяцев удаляются
+	MaxAgeMonths int `env:"RETENTION_MAX_AGE_MONTHS" env-default:"12"`
+	// SkipUntrainedUsers - не удалять признаки пользователей, модели которых еще не обучены
+	SkipUntrainedUsers bool   `env:"RETENTION_SKIP_UNTRAINED_USERS" env-default:"true"`
+	UntrainedUsersURL  string `env:"UNTRAINED_USERS_URL" env-default:"http://0.0.0.0:3391/api/v1/untrained_users"`
+}
+
+func (c RetentionConfig) ToDBConfig() postgresql.DBConfig {
+	return postgresql.DBConfig{
+		Port:                  c.DBPort,
+		Host:                  c.DBHost,
+		Name:                  c.DBName,
+		Password:              c.DBPassword,
+		Username:              c.DBUsername,
+		MaxConnectionAttempts: c.MaxConnectionAttempts,
+		AutoMigrate:           c.AutoMigrate,
+		MigrationsDir:         c.MigrationsDir,
+	}
+}
+
+func (c RetentionConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+var instanceRetention *RetentionConfig
+var onceRetention sync.Once
+
+func GetConfigRetention() *RetentionConfig {
+	onceRetention.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceRetention = &RetentionConfig{}
+		if err := cleanenv.ReadEnv(instanceRetention); err != nil {
+			help, _ := cleanenv.GetDescription(instanceRetention, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+	})
+
+	return instanceRetention
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB - БД для тестов репозитория без Postgres: запоминает выполненные запросы,
+// а результаты Get и ExecQueryRow возвращает из заданных в тесте функций
+type fakeDB struct {
+	execs  []string
+	copied [][]interface{}
+
+	get      func(dest interface{}, query string, args []interface{}) error
+	queryRow func(query string, args []interface{}) pgx.Row
+}
+
+func (db *fakeDB) Client(_ context.Context) postgresql.PGXdb {
+	return db
+}
+
+func (db *fakeDB) Acquire(_ context.Context) (postgresql.Connection, error) {
+	return nil, errors.New("fakeDB: acquire is not supported")
+}
+
+func (db *fakeDB) Close() {}
+
+func (db *fakeDB) Select(_ context.Context, _ interface{}, _ string, _ ...interface{}) error {
+	return nil
+}
+
+func (db *fakeDB) Get(_ context.Context, dest interface{}, query string, args ...interface{}) error {
+	if db.get == nil {
+		return pgx.ErrNoRows
+	}
+	return db.get(dest, query, args)
+}
+
+func (db *fakeDB) Exec(_ context.Context, query string, _ ...interface{}) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, query)
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeDB) ExecQueryRow(_ context.Context, query string, args ...interface{}) pgx.Row {
+	if db.queryRow == nil {
+		return fakeRow{err: pgx.ErrNoRows}
+	}
+	return db.queryRow(query, args)
+}
+
+func (db *fakeDB) CopyFrom(_ context.Context, _ pgx.Identifier, _ []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	var count int64
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return count, err
+		}
+		db.copied = append(db.copied, values)
+		count++
+	}
+	return count, rowSrc.Err()
+}
+
+// fakeRow - строка результата ExecQueryRow
+type fakeRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	for idx := range dest {
+		switch d := dest[idx].(type) {
+		case *bool:
+			*d = r.values[idx].(bool)
+		case *int64:
+			*d = r.values[idx].(int64)
+		default:
+			return errors.New("fakeRow: unsupported scan type")
+		}
+	}
+	return nil
+}
+
+// appErrorCode - код AppError из цепочки ошибок или 0, если ее нет
+func appErrorCode(err error) int {
+	var appErr *app_errors.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return 0
+}
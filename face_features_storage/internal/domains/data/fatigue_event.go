@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	FatigueEventsTable = "fatigue_events"
+)
+
+// FatigueEvent - срабатывание детекции усталости на клиенте.
+// ModelVersion - s3 ключ модели, которой было сделано предсказание
+type FatigueEvent struct {
+	EventID      int64     `db:"event_id" json:"event_id"`
+	UserID       string    `db:"user_id" json:"user_id"`
+	SessionID    string    `db:"session_id" json:"session_id"`
+	ModelType    string    `db:"model_type" json:"model_type"`
+	ModelVersion string    `db:"model_version" json:"model_version"`
+	Confidence   float64   `db:"confidence" json:"confidence"`
+	OccurredAt   time.Time `db:"occurred_at" json:"occurred_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// ClaimedFatigueAlert - событие усталости, взятое на отправку предупреждения в вебхуки.
+// AlertAttempts - номер текущей попытки отправки
+type ClaimedFatigueAlert struct {
+	FatigueEvent
+	AlertAttempts int `db:"alert_attempts"`
+}
+
+// claimFatigueAlertsQuery - забирает события, предупреждения по которым еще не отправлены и время попытки которых
+// наступило, пропуская строки, заблокированные другими воркерами. До окончания аренды событие скрыто
+// от остальных воркеров, а если воркер упал, не записав результат, предупреждение будет отправлено повторно
+const claimFatigueAlertsQuery = `
+UPDATE fatigue_events SET alert_next_attempt_at = now() + make_interval(secs => $1), alert_attempts = alert_attempts + 1
+WHERE event_id IN (
+    SELECT event_id FROM fatigue_events
+    WHERE alert_sent_at IS NULL AND alert_next_attempt_at <= now()
+    ORDER BY alert_next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING event_id, user_id, session_id, model_type, model_version, confidence, occurred_at, created_at, alert_attempts`
+
+// FatigueEventsFilter - фильтр выборки событий усталости, nil поля не участвуют в фильтрации.
+// Период задается по времени события на клиенте
+type FatigueEventsFilter struct {
+	UserID    *string
+	SessionID *string
+	From      *time.Time
+	To        *time.Time
+}
+
+// FatigueEventsSummary - сводка событий усталости пользователя за одну сессию (смену)
+type FatigueEventsSummary struct {
+	UserID        string    `db:"user_id" json:"user_id"`
+	SessionID     string    `db:"session_id" json:"session_id"`
+	EventsCount   int64     `db:"events_count" json:"events_count"`
+	FirstAt       time.Time `db:"first_at" json:"first_at"`
+	LastAt        time.Time `db:"last_at" json:"last_at"`
+	AvgConfidence float64   `db:"avg_confidence" json:"avg_confidence"`
+	MaxConfidence float64   `db:"max_confidence" json:"max_confidence"`
+}
+
+var fatigueEventColumns = []string{
+	"event_id",
+	"user_id",
+	"session_id",
+	"model_type",
+	"model_version",
+	"confidence",
+	"occurred_at",
+	"created_at",
+}
+
+func (f FatigueEventsFilter) where(qb sq.SelectBuilder) sq.SelectBuilder {
+	if f.UserID != nil {
+		qb = qb.Where(sq.Eq{"user_id": *f.UserID})
+	}
+	if f.SessionID != nil {
+		qb = qb.Where(sq.Eq{"session_id": *f.SessionID})
+	}
+	if f.From != nil {
+		qb = qb.Where(sq.GtOrEq{"occurred_at": *f.From})
+	}
+	if f.To != nil {
+		qb = qb.Where(sq.Lt{"occurred_at": *f.To})
+	}
+	return qb
+}
+
+// SaveFatigueEvents - сохраняет пакет событий одним запросом, клиент может копить события без сети и отправлять их разом.
+// Предупреждения по сохраненным событиям отправляет в вебхуки воркер fatigue-alerts
+func (r *Repository) SaveFatigueEvents(ctx context.Context, events []FatigueEvent) ([]FatigueEvent, error) {
+	op := "data.Repository.SaveFatigueEvents"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Insert(FatigueEventsTable).
+		Columns(
+			"user_id",
+			"session_id",
+			"model_type",
+			"model_version",
+			"confidence",
+			"occurred_at",
+		)
+	for _, event := range events {
+		qb = qb.Values(
+			event.UserID,
+			event.SessionID,
+			event.ModelType,
+			event.ModelVersion,
+			event.Confidence,
+			event.OccurredAt,
+		)
+	}
+
+	q, i, err := qb.
+		Suffix("RETURNING " + strings.Join(fatigueEventColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]FatigueEvent, 0, len(events))
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: save fatigue events", op))
+
+	return res, nil
+}
+
+func (r *Repository) GetFatigueEvents(ctx context.Context, filter FatigueEventsFilter, afterID int64, limit uint64) ([]FatigueEvent, error) {
+	op := "data.Repository.GetFatigueEvents"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(fatigueEventColumns...).
+		From(FatigueEventsTable).
+		Where(sq.Gt{"event_id": afterID})
+
+	q, i, err := filter.where(qb).
+		OrderBy("event_id").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]FatigueEvent, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res)), zap.Int64("after_id", afterID)).
+		Info(fmt.Sprintf("%s: get fatigue events page", op))
+
+	return res, nil
+}
+
+// GetFatigueEventsSummary - считает события усталости по пользователям и сессиям за период
+func (r *Repository) GetFatigueEventsSummary(ctx context.Context, filter FatigueEventsFilter) ([]FatigueEventsSummary, error) {
+	op := "data.Repository.GetFatigueEventsSummary"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(
+			"user_id",
+			"session_id",
+			"count(*) AS events_count",
+			"min(occurred_at) AS first_at",
+			"max(occurred_at) AS last_at",
+			"avg(confidence) AS avg_confidence",
+			"max(confidence) AS max_confidence",
+		).
+		From(FatigueEventsTable)
+
+	q, i, err := filter.where(qb).
+		GroupBy("user_id", "session_id").
+		OrderBy("first_at").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]FatigueEventsSummary, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: get fatigue events summary", op))
+
+	return res, nil
+}
+
+// ClaimFatigueAlerts - забирает до limit событий для отправки предупреждений, скрывая их от других воркеров на lease
+func (r *Repository) ClaimFatigueAlerts(ctx context.Context, limit int, lease time.Duration) ([]ClaimedFatigueAlert, error) {
+	op := "data.Repository.ClaimFatigueAlerts"
+
+	res := make([]ClaimedFatigueAlert, 0)
+	err := r.db.Client(ctx).Select(ctx, &res, claimFatigueAlertsQuery, lease.Seconds(), limit)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+// SetFatigueAlertsSent - отмечает предупреждения по событиям eventIDs отправленными
+func (r *Repository) SetFatigueAlertsSent(ctx context.Context, eventIDs []int64) error {
+	op := "data.Repository.SetFatigueAlertsSent"
+
+	q, i, err := r.queryBuilder.
+		Update(FatigueEventsTable).
+		Set("alert_sent_at", sq.Expr("now()")).
+		Where(sq.Eq{"event_id": eventIDs}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return nil
+}
+
+// SetFatigueAlertsNextAttempt - откладывает повторную отправку предупреждений по событиям eventIDs до nextAttemptAt
+func (r *Repository) SetFatigueAlertsNextAttempt(ctx context.Context, eventIDs []int64, nextAttemptAt time.Time) error {
+	op := "data.Repository.SetFatigueAlertsNextAttempt"
+
+	q, i, err := r.queryBuilder.
+		Update(FatigueEventsTable).
+		Set("alert_next_attempt_at", nextAttemptAt).
+		Where(sq.Eq{"event_id": eventIDs}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return nil
+}
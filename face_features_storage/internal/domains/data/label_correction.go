@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	LabelCorrectionsTable = "label_corrections"
+)
+
+// LabelCorrection - запись журнала исправления меток класса признаков видео.
+// Если FrameFrom и FrameTo не заданы, то исправляются метки всего видео
+type LabelCorrection struct {
+	CorrectionID  int64     `db:"correction_id" json:"correction_id"`
+	ModelType     string    `db:"model_type" json:"model_type"`
+	VideoID       string    `db:"video_id" json:"video_id"`
+	UserID        string    `db:"user_id" json:"user_id"`
+	FrameFrom     *int      `db:"frame_from" json:"frame_from"`
+	FrameTo       *int      `db:"frame_to" json:"frame_to"`
+	OldLabels     []int32   `db:"old_labels" json:"old_labels"`
+	NewLabel      int       `db:"new_label" json:"new_label"`
+	FeaturesCount int       `db:"features_count" json:"features_count"`
+	Reason        *string   `db:"reason" json:"reason"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+var labelCorrectionColumns = []string{
+	"correction_id",
+	"model_type",
+	"video_id",
+	"user_id",
+	"frame_from",
+	"frame_to",
+	"old_labels",
+	"new_label",
+	"features_count",
+	"reason",
+	"created_at",
+}
+
+// FrameColumn - признак с номером кадра, по которому исправляются метки диапазона кадров видео
+const FrameColumn = "frame_count"
+
+// correctedFeaturesCond - условие отбора признаков, метки которых исправляются
+func correctedFeaturesCond(correction LabelCorrection) sq.And {
+	cond := sq.And{sq.Eq{"video_id": correction.VideoID, "user_id": correction.UserID}}
+	if correction.FrameFrom != nil {
+		cond = append(cond, sq.GtOrEq{FrameColumn: *correction.FrameFrom})
+	}
+	if correction.FrameTo != nil {
+		cond = append(cond, sq.LtOrEq{FrameColumn: *correction.FrameTo})
+	}
+	return cond
+}
+
+// CorrectLabels - заменяет метки класса признаков видео (или диапазона кадров видео) в таблице схемы
+// и записывает исправление в журнал. Диапазон кадров можно задать только для схем с признаком FrameColumn
+func (r *Repository) CorrectLabels(ctx context.Context, schema FeatureSchema, correction LabelCorrection) (*LabelCorrection, error) {
+	op := "data.Repository.CorrectLabels"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	if (correction.FrameFrom != nil || correction.FrameTo != nil) && !schema.HasColumn(FrameColumn) {
+		return nil, app_errors.ErrValidationError.WrapError(op, "frame range is not supported by model type schema")
+	}
+
+	table := pgx.Identifier{schema.TableName}.Sanitize()
+	cond := correctedFeaturesCond(correction)
+
+	// запоминаем метки, которые были у признаков до исправления
+	q, i, err := r.queryBuilder.
+		Select("DISTINCT label").
+		From(table).
+		Where(cond).
+		OrderBy("label").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	oldLabels := make([]int32, 0)
+	err = r.db.Client(ctx).Select(ctx, &oldLabels, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	if len(oldLabels) == 0 {
+		return nil, app_errors.ErrNotFound.WrapError(op, "features for correction not found")
+	}
+
+	// меняем только отличающиеся метки, чтобы в журнал попало реальное количество исправленных признаков
+	q, i, err = r.queryBuilder.
+		Update(table).
+		Set("label", correction.NewLabel).
+		Where(cond).
+		Where(sq.NotEq{"label": correction.NewLabel}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	setMap := sq.Eq{
+		"model_type":     schema.ModelType,
+		"video_id":       correction.VideoID,
+		"user_id":        correction.UserID,
+		"frame_from":     correction.FrameFrom,
+		"frame_to":       correction.FrameTo,
+		"old_labels":     oldLabels,
+		"new_label":      correction.NewLabel,
+		"features_count": tag.RowsAffected(),
+		"reason":         correction.Reason,
+	}
+
+	q, i, err = r.queryBuilder.
+		Insert(LabelCorrectionsTable).
+		SetMap(setMap).
+		Suffix("RETURNING " + strings.Join(labelCorrectionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res LabelCorrection
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("model_type", schema.ModelType),
+		zap.String("video_id", correction.VideoID),
+		zap.String("user_id", correction.UserID),
+		zap.Int("new_label", correction.NewLabel),
+		zap.Int64("count", tag.RowsAffected()),
+	).Info(fmt.Sprintf("%s: correct features labels", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetLabelCorrections(ctx context.Context, modelType, userID string, videoID *string) ([]LabelCorrection, error) {
+	op := "data.Repository.GetLabelCorrections"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(labelCorrectionColumns...).
+		From(LabelCorrectionsTable).
+		Where(sq.Eq{"model_type": modelType, "user_id": userID})
+
+	if videoID != nil {
+		qb = qb.Where(sq.Eq{"video_id": *videoID})
+	}
+
+	q, i, err := qb.
+		OrderBy("correction_id DESC").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]LabelCorrection, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("user_id", userID), zap.Int("count", len(res))).
+		Info(fmt.Sprintf("%s: get label corrections", op))
+
+	return res, nil
+}
@@ -0,0 +1,47 @@
+package data
+
+import "time"
+
+// Feature - строка таблицы признаков схемы по именам колонок, NULL значения равны nil
+type Feature map[string]any
+
+// FeatureID - курсор пагинации выгрузки признаков
+func (f Feature) FeatureID() int64 {
+	featureID, _ := f["feature_id"].(int64)
+	return featureID
+}
+
+// FeaturesFilter - фильтр выборки признаков, nil поля не участвуют в фильтрации
+type FeaturesFilter struct {
+	UserID  *string
+	VideoID *string
+	Label   *int
+	From    *time.Time
+	To      *time.Time
+}
+
+// IsEmpty - возвращает true, если не задано ни одного условия фильтрации
+func (f FeaturesFilter) IsEmpty() bool {
+	return f.UserID == nil && f.VideoID == nil && f.Label == nil && f.From == nil && f.To == nil
+}
+
+// FeatureStats - статистика по одному признаку, nil при отсутствии данных
+type FeatureStats struct {
+	Mean   *float64 `json:"mean"`
+	StdDev *float64 `json:"stddev"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+}
+
+type DatasetStats struct {
+	UserID             string `json:"user_id"`
+	TotalCount         uint64 `json:"total_count"`
+	VideoSessionsCount uint64 `json:"video_sessions_count"`
+	// количество признаков по каждой метке класса
+	LabelCounts map[int]uint64 `json:"label_counts"`
+	// количество признаков без метки, они входят в TotalCount, но не в LabelCounts
+	UnlabeledCount uint64 `json:"unlabeled_count"`
+	// отношение количества признаков самого частого класса к самому редкому
+	ClassImbalanceRatio *float64                `json:"class_imbalance_ratio"`
+	Features            map[string]FeatureStats `json:"features"`
+}
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// FeaturesPartition - месячная секция таблицы признаков
+type FeaturesPartition struct {
+	Name  string
+	Month time.Time
+}
+
+// End - верхняя (не включительно) граница секции
+func (p FeaturesPartition) End() time.Time {
+	return p.Month.AddDate(0, 1, 0)
+}
+
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// FeaturesPartitionName - имя месячной секции таблицы признаков table
+func FeaturesPartitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), int(month.Month()))
+}
+
+func parseFeaturesPartitionName(table, name string) (time.Time, bool) {
+	var year, month int
+	_, err := fmt.Sscanf(name, table+"_y%04dm%02d", &year, &month)
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// FeaturesPartitionsLockKey - ключ advisory-блокировки создания секций таблиц признаков
+const FeaturesPartitionsLockKey int64 = 7_203_915_101
+
+// EnsureFeaturesPartitions - создает секции таблицы признаков table для месяца t и следующего за ним,
+// чтобы вставка на границе месяцев не падала из-за отсутствия секции.
+// Вызывается внутри транзакции загрузки: создание секций разных загрузок и реплик
+// выстраивается в очередь advisory-блокировкой, которая снимается вместе с завершением транзакции
+func (r *Repository) EnsureFeaturesPartitions(ctx context.Context, table string, t time.Time) error {
+	op := "data.Repository.EnsureFeaturesPartitions"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	current := MonthStart(t)
+	for _, month := range []time.Time{current, current.AddDate(0, 1, 0)} {
+		name := FeaturesPartitionName(table, month)
+		if _, ok := r.ensuredPartitions.Load(name); ok {
+			continue
+		}
+
+		var exists bool
+		err := r.db.Client(ctx).ExecQueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL",
+			pgx.Identifier{name}.Sanitize()).Scan(&exists)
+		if err != nil {
+			return app_errors.ErrSQLExec.WrapError(op, err.Error())
+		}
+		// Запоминаем только секции, которые уже существуют: секция, созданная в текущей транзакции,
+		// пропадет при ее откате, поэтому ее существование проверит следующая загрузка
+		if exists {
+			r.ensuredPartitions.Store(name, struct{}{})
+			continue
+		}
+
+		_, err = r.db.Client(ctx).Exec(ctx, "SELECT pg_advisory_xact_lock($1)", FeaturesPartitionsLockKey)
+		if err != nil {
+			return app_errors.ErrSQLExec.WrapError(op, err.Error())
+		}
+
+		q := fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			pgx.Identifier{name}.Sanitize(),
+			pgx.Identifier{table}.Sanitize(),
+			month.Format(time.RFC3339),
+			month.AddDate(0, 1, 0).Format(time.RFC3339),
+		)
+
+		_, err = r.db.Client(ctx).Exec(ctx, q)
+		if err != nil {
+			return app_errors.ErrSQLExec.WrapError(op, err.Error())
+		}
+
+		l.With(zap.String("partition", name)).Debug(fmt.Sprintf("%s: ensure features partition", op))
+	}
+
+	return nil
+}
+
+// GetFeaturesPartitions - месячные секции таблицы признаков table
+func (r *Repository) GetFeaturesPartitions(ctx context.Context, table string) ([]FeaturesPartition, error) {
+	op := "data.Repository.GetFeaturesPartitions"
+
+	q, i, err := r.queryBuilder.
+		Select("child.relname").
+		From("pg_inherits").
+		Join("pg_class parent ON parent.oid = pg_inherits.inhparent").
+		Join("pg_class child ON child.oid = pg_inherits.inhrelid").
+		Where(sq.Eq{"parent.relname": table}).
+		OrderBy("child.relname").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var names []string
+	err = r.db.Client(ctx).Select(ctx, &names, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]FeaturesPartition, 0, len(names))
+	for _, name := range names {
+		// секции, созданные не по схеме именования, не трогаем
+		month, ok := parseFeaturesPartitionName(table, name)
+		if !ok {
+			continue
+		}
+		res = append(res, FeaturesPartition{Name: name, Month: month})
+	}
+
+	return res, nil
+}
+
+func (r *Repository) DropFeaturesPartition(ctx context.Context, partition FeaturesPartition) error {
+	op := "data.Repository.DropFeaturesPartition"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	_, err := r.db.Client(ctx).Exec(ctx, fmt.Sprintf("DROP TABLE %s", pgx.Identifier{partition.Name}.Sanitize()))
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	r.ensuredPartitions.Delete(partition.Name)
+	l.With(zap.String("partition", partition.Name)).Info(fmt.Sprintf("%s: drop features partition", op))
+
+	return nil
+}
+
+// CountPartitionUsersFeatures - количество признаков в секции, принадлежащих пользователям userIDs
+func (r *Repository) CountPartitionUsersFeatures(ctx context.Context, partition FeaturesPartition, userIDs []string) (uint64, error) {
+	op := "data.Repository.CountPartitionUsersFeatures"
+
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	q, i, err := r.queryBuilder.
+		Select("count(*)").
+		From(pgx.Identifier{partition.Name}.Sanitize()).
+		Where(sq.Eq{"user_id": userIDs}).
+		ToSql()
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var count uint64
+	err = r.db.Client(ctx).ExecQueryRow(ctx, q, i...).Scan(&count)
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return count, nil
+}
+
+// DeletePartitionFeaturesExceptUsers - удаляет из секции признаки всех пользователей, кроме userIDs
+func (r *Repository) DeletePartitionFeaturesExceptUsers(ctx context.Context, partition FeaturesPartition, userIDs []string) (int64, error) {
+	op := "data.Repository.DeletePartitionFeaturesExceptUsers"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Delete(pgx.Identifier{partition.Name}.Sanitize()).
+		Where(sq.NotEq{"user_id": userIDs}).
+		ToSql()
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("partition", partition.Name), zap.Int64("count", tag.RowsAffected())).
+		Info(fmt.Sprintf("%s: delete features from partition", op))
+
+	return tag.RowsAffected(), nil
+}
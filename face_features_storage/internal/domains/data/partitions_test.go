@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"testing"
+	"time"
+)
+
+func TestEnsureFeaturesPartitions(t *testing.T) {
+	uploadedAt := time.Date(2026, time.December, 31, 23, 59, 0, 0, time.UTC)
+	createExecs := []string{
+		"SELECT pg_advisory_xact_lock($1)",
+		`CREATE TABLE IF NOT EXISTS "video_features_y2026m12" PARTITION OF "video_features" FOR VALUES FROM ('2026-12-01T00:00:00Z') TO ('2027-01-01T00:00:00Z')`,
+		"SELECT pg_advisory_xact_lock($1)",
+		`CREATE TABLE IF NOT EXISTS "video_features_y2027m01" PARTITION OF "video_features" FOR VALUES FROM ('2027-01-01T00:00:00Z') TO ('2027-02-01T00:00:00Z')`,
+	}
+
+	tests := []struct {
+		name   string
+		exists bool
+		// wantExecs - запросы первой и второй загрузки
+		wantExecs   []string
+		wantQueries int
+	}{
+		{
+			// созданные в транзакции секции не запоминаются, поэтому вторая загрузка проверяет их снова
+			name:        "partitions are missing",
+			exists:      false,
+			wantExecs:   append(append([]string{}, createExecs...), createExecs...),
+			wantQueries: 4,
+		},
+		{
+			// существующие секции запоминаются и больше не проверяются
+			name:        "partitions exist",
+			exists:      true,
+			wantQueries: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries int
+			db := &fakeDB{
+				queryRow: func(_ string, _ []interface{}) pgx.Row {
+					queries++
+					return fakeRow{values: []interface{}{tt.exists}}
+				},
+			}
+			r := NewRepository(db)
+
+			for idx := 0; idx < 2; idx++ {
+				err := r.EnsureFeaturesPartitions(context.Background(), FeaturesTable, uploadedAt)
+				if err != nil {
+					t.Fatalf("EnsureFeaturesPartitions() error = %v", err)
+				}
+			}
+
+			if queries != tt.wantQueries {
+				t.Errorf("existence checks = %d, want %d", queries, tt.wantQueries)
+			}
+			if len(db.execs) != len(tt.wantExecs) {
+				t.Fatalf("execs = %q, want %q", db.execs, tt.wantExecs)
+			}
+			for idx := range tt.wantExecs {
+				if db.execs[idx] != tt.wantExecs[idx] {
+					t.Errorf("exec[%d] = %s, want %s", idx, db.execs[idx], tt.wantExecs[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestDropFeaturesPartitionForgetsEnsuredPartition(t *testing.T) {
+	var queries int
+	db := &fakeDB{
+		queryRow: func(_ string, _ []interface{}) pgx.Row {
+			queries++
+			return fakeRow{values: []interface{}{true}}
+		},
+	}
+	r := NewRepository(db)
+	month := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	err := r.EnsureFeaturesPartitions(context.Background(), FeaturesTable, month)
+	if err != nil {
+		t.Fatalf("EnsureFeaturesPartitions() error = %v", err)
+	}
+
+	err = r.DropFeaturesPartition(context.Background(), FeaturesPartition{
+		Name:  FeaturesPartitionName(FeaturesTable, month),
+		Month: month,
+	})
+	if err != nil {
+		t.Fatalf("DropFeaturesPartition() error = %v", err)
+	}
+
+	// удаленную секцию следующая загрузка проверяет заново, следующий месяц остается в кеше
+	err = r.EnsureFeaturesPartitions(context.Background(), FeaturesTable, month)
+	if err != nil {
+		t.Fatalf("EnsureFeaturesPartitions() error = %v", err)
+	}
+	if queries != 3 {
+		t.Errorf("existence checks = %d, want 3", queries)
+	}
+}
+
+func TestParseFeaturesPartitionName(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition string
+		wantMonth time.Time
+		wantOK    bool
+	}{
+		{name: "valid", partition: "video_features_y2026m10", wantMonth: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), wantOK: true},
+		{name: "invalid month", partition: "video_features_y2026m13"},
+		{name: "other table", partition: "features_eye_model_y2026m10"},
+		{name: "custom partition", partition: "video_features_default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			month, ok := parseFeaturesPartitionName(FeaturesTable, tt.partition)
+			if ok != tt.wantOK || !month.Equal(tt.wantMonth) {
+				t.Errorf("parseFeaturesPartitionName(%s) = %v, %v, want %v, %v", tt.partition, month, ok, tt.wantMonth, tt.wantOK)
+			}
+		})
+	}
+}
@@ -11,34 +11,75 @@ import (
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 	"io"
-	"mime/multipart"
 	"strconv"
+	"sync"
+	"time"
 )
 
 const (
 	FeaturesTable = "video_features"
 )
 
+const (
+	FaceModel = "face_model"
+)
+
 type Repository struct {
 	db           postgresql.DB
 	queryBuilder sq.StatementBuilderType
+
+	// секции таблиц признаков, существование которых уже проверено
+	ensuredPartitions sync.Map
 }
 
 func NewRepository(db postgresql.DB) *Repository {
 	return &Repository{db: db, queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar)}
 }
 
-func (r *Repository) SaveFaceVideoFeatures(ctx context.Context, csvFile multipart.File) (uint64, error) {
-	op := "data.Repository.SaveFaceVideoFeatures"
+// SaveFeatures - сохраняет признаки из csv файла в таблицу схемы. Колонки файла определяются по заголовку
+// и проверяются по схеме: неизвестные колонки и отсутствующие признаки схемы считаются ошибкой
+func (r *Repository) SaveFeatures(ctx context.Context, schema FeatureSchema, csvFile io.Reader, userID string) (uint64, error) {
+	op := "data.Repository.SaveFeatures"
 	l := logger.EntryWithRequestIDFromContext(ctx)
 
+	// признаки лица загружаются в рамках сессий записи видео
+	isFaceModel := schema.ModelType == FaceModel
+
+	// признаки попадают в секцию текущего месяца
+	err := r.EnsureFeaturesPartitions(ctx, schema.TableName, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	reader := csv.NewReader(csvFile)
-	header, _ := reader.Read()
+	header, err := reader.Read()
+	if err != nil {
+		return 0, app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	types, err := headerColumnTypes(schema, header)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	userIdx, videoIdx := -1, -1
+	for idx, column := range header {
+		switch column {
+		case "user_id":
+			userIdx = idx
+		case "video_id":
+			videoIdx = idx
+		}
+	}
+	if isFaceModel && videoIdx < 0 {
+		return 0, app_errors.ErrValidationError.WrapError(op, "missing column video_id")
+	}
 
 	batchLen := 250
 	rows := make([][]interface{}, 0, batchLen)
 
 	var featuresCount uint64
+	// видео, для которых уже проверена сессия загрузки
+	checkedVideos := make(map[string]struct{})
 
 	for {
 		record, err := reader.Read()
@@ -50,21 +91,33 @@ func (r *Repository) SaveFaceVideoFeatures(ctx context.Context, csvFile multipar
 			return 0, app_errors.ErrParseError.WrapError(op, err.Error())
 		}
 
-		row := make([]interface{}, len(record))
-
-		row[0] = record[0]
+		// признаки должны принадлежать загружающему пользователю
+		if record[userIdx] != userID {
+			return 0, app_errors.ErrValidationError.WrapError(op, "features belong to another user")
+		}
 
-		row[1], _ = strconv.Atoi(record[1])
-		row[8], _ = strconv.Atoi(record[8])
-		row[9] = record[9]
+		// признаки должны ссылаться на открытую сессию загружающего пользователя
+		if isFaceModel {
+			if _, ok := checkedVideos[record[videoIdx]]; !ok {
+				err = r.checkUploadSession(ctx, record[videoIdx], userID)
+				if err != nil {
+					return 0, fmt.Errorf("%s: %w", op, err)
+				}
+				checkedVideos[record[videoIdx]] = struct{}{}
+			}
+		}
 
-		for i := 2; i < 8; i++ {
-			row[i], _ = strconv.ParseFloat(record[i], 64)
+		row := make([]interface{}, len(record))
+		for idx, value := range record {
+			row[idx], err = parseColumnValue(types[idx], value)
+			if err != nil {
+				return 0, app_errors.ErrParseError.WrapError(op, fmt.Sprintf("column %s: %s", header[idx], err.Error()))
+			}
 		}
 
 		rows = append(rows, row)
 		if len(rows) == batchLen {
-			_, err = r.db.Client(ctx).CopyFrom(ctx, pgx.Identifier{FeaturesTable}, header, pgx.CopyFromRows(rows))
+			_, err = r.db.Client(ctx).CopyFrom(ctx, pgx.Identifier{schema.TableName}, header, pgx.CopyFromRows(rows))
 			if err != nil {
 				return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
 			}
@@ -76,13 +129,214 @@ func (r *Repository) SaveFaceVideoFeatures(ctx context.Context, csvFile multipar
 
 	if len(rows) > 0 {
 		featuresCount += uint64(len(rows))
-		_, err := r.db.Client(ctx).CopyFrom(ctx, pgx.Identifier{FeaturesTable}, header, pgx.CopyFromRows(rows))
+		_, err := r.db.Client(ctx).CopyFrom(ctx, pgx.Identifier{schema.TableName}, header, pgx.CopyFromRows(rows))
 		if err != nil {
 			return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
 		}
 	}
 
-	l.With(zap.Uint64("count", featuresCount)).Info(fmt.Sprintf("%s: save face model features", op))
+	l.With(zap.String("model_type", schema.ModelType), zap.Uint64("count", featuresCount)).
+		Info(fmt.Sprintf("%s: save model features", op))
 
 	return featuresCount, nil
 }
+
+// headerColumnTypes - типы колонок заголовка csv файла по схеме признаков
+func headerColumnTypes(schema FeatureSchema, header []string) ([]string, error) {
+	op := "data.headerColumnTypes"
+
+	columnTypes := schema.ColumnTypes()
+	types := make([]string, len(header))
+	seen := make(map[string]struct{}, len(header))
+	for idx, column := range header {
+		columnType, ok := columnTypes[column]
+		if !ok {
+			return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("unknown column %s", column))
+		}
+		if _, ok = seen[column]; ok {
+			return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("duplicate column %s", column))
+		}
+		seen[column] = struct{}{}
+		types[idx] = columnType
+	}
+
+	// обязательны все признаки схемы, id пользователя и метка класса
+	required := append([]SchemaColumn{{Name: "user_id"}, {Name: "label"}}, schema.Columns...)
+	for _, column := range required {
+		if _, ok := seen[column.Name]; !ok {
+			return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("missing column %s", column.Name))
+		}
+	}
+
+	return types, nil
+}
+
+func parseColumnValue(columnType, value string) (interface{}, error) {
+	switch columnType {
+	case ColumnTypeInt:
+		return strconv.Atoi(value)
+	case ColumnTypeFloat:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
+
+// GetFeatures - страница признаков таблицы схемы с feature_id больше afterID
+func (r *Repository) GetFeatures(ctx context.Context, schema FeatureSchema, filter FeaturesFilter, afterID int64, limit uint64) ([]Feature, error) {
+	op := "data.Repository.GetFeatures"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	exportColumns := schema.ExportColumns()
+	columns := make([]string, 0, len(exportColumns)+1)
+	columns = append(columns, "feature_id")
+	for _, column := range exportColumns {
+		columns = append(columns, pgx.Identifier{column.Name}.Sanitize())
+	}
+
+	qb := r.queryBuilder.
+		Select(columns...).
+		From(pgx.Identifier{schema.TableName}.Sanitize()).
+		Where(sq.Gt{"feature_id": afterID})
+
+	if filter.UserID != nil {
+		qb = qb.Where(sq.Eq{"user_id": *filter.UserID})
+	}
+	if filter.VideoID != nil {
+		qb = qb.Where(sq.Eq{"video_id": *filter.VideoID})
+	}
+	if filter.Label != nil {
+		qb = qb.Where(sq.Eq{"label": *filter.Label})
+	}
+	if filter.From != nil {
+		qb = qb.Where(sq.GtOrEq{"created_at": *filter.From})
+	}
+	if filter.To != nil {
+		qb = qb.Where(sq.Lt{"created_at": *filter.To})
+	}
+
+	q, i, err := qb.
+		OrderBy("feature_id").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res []Feature
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", schema.ModelType), zap.Int("count", len(res)), zap.Int64("after_id", afterID)).
+		Info(fmt.Sprintf("%s: get features page", op))
+
+	return res, nil
+}
+
+// GetDatasetStats - статистика датасета пользователя в таблице схемы, статистики считаются по вещественным признакам
+func (r *Repository) GetDatasetStats(ctx context.Context, schema FeatureSchema, userID string) (*DatasetStats, error) {
+	op := "data.Repository.GetDatasetStats"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	table := pgx.Identifier{schema.TableName}.Sanitize()
+	statsColumns := schema.StatsColumns()
+
+	res := DatasetStats{
+		UserID:      userID,
+		LabelCounts: make(map[int]uint64),
+		Features:    make(map[string]FeatureStats, len(statsColumns)),
+	}
+
+	// считаем количество признаков по каждой метке класса
+	q, i, err := r.queryBuilder.
+		Select("label", "count(*) AS rows_count").
+		From(table).
+		Where(sq.Eq{"user_id": userID}).
+		GroupBy("label").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var labelCounts []struct {
+		Label     *int   `db:"label"`
+		RowsCount uint64 `db:"rows_count"`
+	}
+	err = r.db.Client(ctx).Select(ctx, &labelCounts, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var minCount, maxCount uint64
+	for _, labelCount := range labelCounts {
+		res.TotalCount += labelCount.RowsCount
+		// признаки без метки не относятся ни к одному классу и не учитываются в дисбалансе классов
+		if labelCount.Label == nil {
+			res.UnlabeledCount = labelCount.RowsCount
+			continue
+		}
+		res.LabelCounts[*labelCount.Label] = labelCount.RowsCount
+
+		if minCount == 0 || labelCount.RowsCount < minCount {
+			minCount = labelCount.RowsCount
+		}
+		if labelCount.RowsCount > maxCount {
+			maxCount = labelCount.RowsCount
+		}
+	}
+	// дисбаланс классов имеет смысл только при наличии хотя бы двух классов
+	if len(res.LabelCounts) > 1 {
+		ratio := float64(maxCount) / float64(minCount)
+		res.ClassImbalanceRatio = &ratio
+	}
+
+	// считаем количество видео и статистики признаков одним запросом
+	columns := make([]string, 0, 1+4*len(statsColumns))
+	columns = append(columns, "count(DISTINCT video_id)")
+	for _, column := range statsColumns {
+		column = pgx.Identifier{column}.Sanitize()
+		columns = append(columns,
+			fmt.Sprintf("avg(%s)", column),
+			fmt.Sprintf("stddev_samp(%s)", column),
+			fmt.Sprintf("min(%s)", column),
+			fmt.Sprintf("max(%s)", column),
+		)
+	}
+
+	q, i, err = r.queryBuilder.
+		Select(columns...).
+		From(table).
+		Where(sq.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	featureStats := make([]FeatureStats, len(statsColumns))
+	dest := make([]interface{}, 0, len(columns))
+	dest = append(dest, &res.VideoSessionsCount)
+	for idx := range featureStats {
+		dest = append(dest,
+			&featureStats[idx].Mean,
+			&featureStats[idx].StdDev,
+			&featureStats[idx].Min,
+			&featureStats[idx].Max,
+		)
+	}
+
+	err = r.db.Client(ctx).ExecQueryRow(ctx, q, i...).Scan(dest...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	for idx, column := range statsColumns {
+		res.Features[column] = featureStats[idx]
+	}
+
+	l.With(zap.String("model_type", schema.ModelType), zap.String("user_id", userID), zap.Uint64("count", res.TotalCount)).
+		Info(fmt.Sprintf("%s: get dataset stats", op))
+
+	return &res, nil
+}
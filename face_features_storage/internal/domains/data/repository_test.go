@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testing"
+)
+
+func TestSaveFeatures(t *testing.T) {
+	const userID = "11111111-1111-1111-1111-111111111111"
+	schema := FeatureSchema{
+		ModelType: "eye_model",
+		TableName: "features_eye_model",
+		Columns:   []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}, {Name: "blinks", Type: ColumnTypeInt}},
+	}
+
+	tests := []struct {
+		name      string
+		csv       string
+		wantCount uint64
+		wantCode  int
+	}{
+		{
+			name:      "valid",
+			csv:       "ear,blinks,label,user_id\n0.31,2,0," + userID + "\n0.12,7,1," + userID + "\n",
+			wantCount: 2,
+		},
+		{
+			name:      "columns in any order",
+			csv:       "user_id,label,blinks,ear\n" + userID + ",1,3,0.2\n",
+			wantCount: 1,
+		},
+		{
+			name:     "unknown column",
+			csv:      "ear,blinks,mar,label,user_id\n0.31,2,0.5,0," + userID + "\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "duplicate column",
+			csv:      "ear,ear,blinks,label,user_id\n0.31,0.31,2,0," + userID + "\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "missing schema column",
+			csv:      "ear,label,user_id\n0.31,0," + userID + "\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "missing user_id",
+			csv:      "ear,blinks,label\n0.31,2,0\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "missing label",
+			csv:      "ear,blinks,user_id\n0.31,2," + userID + "\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "features of another user",
+			csv:      "ear,blinks,label,user_id\n0.31,2,0," + userID + "\n0.12,7,1,22222222-2222-2222-2222-222222222222\n",
+			wantCode: app_errors.ErrValidationError.Code,
+		},
+		{
+			name:     "value of wrong type",
+			csv:      "ear,blinks,label,user_id\n0.31,two,0," + userID + "\n",
+			wantCode: app_errors.ErrParseError.Code,
+		},
+		{
+			name:     "empty file",
+			csv:      "",
+			wantCode: app_errors.ErrParseError.Code,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{
+				// секции таблицы уже созданы
+				queryRow: func(_ string, _ []interface{}) pgx.Row {
+					return fakeRow{values: []interface{}{true}}
+				},
+			}
+			r := NewRepository(db)
+
+			count, err := r.SaveFeatures(context.Background(), schema, strings.NewReader(tt.csv), userID)
+			if tt.wantCode != 0 {
+				if appErrorCode(err) != tt.wantCode {
+					t.Fatalf("SaveFeatures() error = %v, want code %d", err, tt.wantCode)
+				}
+				if len(db.copied) != 0 {
+					t.Errorf("copied %d rows, want none on error", len(db.copied))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SaveFeatures() error = %v", err)
+			}
+			if count != tt.wantCount || uint64(len(db.copied)) != tt.wantCount {
+				t.Errorf("count = %d, copied = %d, want %d", count, len(db.copied), tt.wantCount)
+			}
+		})
+	}
+}
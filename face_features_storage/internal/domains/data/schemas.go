@@ -0,0 +1,380 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FeatureSchemasTable = "feature_schemas"
+
+	// schemaTablePrefix - префикс таблиц признаков, создаваемых по схемам
+	schemaTablePrefix = "features_"
+)
+
+const (
+	ColumnTypeInt    = "int"
+	ColumnTypeFloat  = "float"
+	ColumnTypeString = "string"
+	ColumnTypeTime   = "time"
+)
+
+// maxIdentifierLength - длина идентификатора в Postgres (NAMEDATALEN - 1). Более длинные имена
+// молча обрезаются, и разные признаки после обрезки могут стать одной колонкой
+const maxIdentifierLength = 63
+
+const (
+	userVideoIndexSuffix = "_user_id_video_id_idx"
+	featureIDIndexSuffix = "_feature_id_idx"
+)
+
+// maxModelTypeLength - тип модели входит в имена таблицы схемы, ее месячных секций (features_<model_type>_yYYYYmMM)
+// и индексов, самое длинное из которых - features_<model_type>_user_id_video_id_idx
+const maxModelTypeLength = maxIdentifierLength - len(schemaTablePrefix) - len(userVideoIndexSuffix)
+
+// identifierRegexp - тип модели входит в имя таблицы, а имена признаков становятся колонками,
+// поэтому допускаются только строчные латинские буквы, цифры и подчеркивания
+var identifierRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// columnSQLTypes - типы колонок таблицы признаков для типов из схемы
+var columnSQLTypes = map[string]string{
+	ColumnTypeInt:   "INT",
+	ColumnTypeFloat: "DOUBLE PRECISION",
+}
+
+// SystemColumns - колонки, которые есть в таблице признаков любой схемы
+var SystemColumns = []SchemaColumn{
+	{Name: "video_id", Type: ColumnTypeString},
+	{Name: "user_id", Type: ColumnTypeString},
+	{Name: "label", Type: ColumnTypeInt},
+}
+
+// reservedColumns - колонки, которые заполняются сервисом или есть в любой таблице признаков
+var reservedColumns = map[string]struct{}{
+	"video_id":   {},
+	"user_id":    {},
+	"label":      {},
+	"feature_id": {},
+	"created_at": {},
+}
+
+// SchemaColumn - признак модели и его тип
+type SchemaColumn struct {
+	Name string `json:"name" validate:"required,max=63"`
+	Type string `json:"type" validate:"required,oneof=int float"`
+}
+
+// FeatureSchema - схема признаков модели одного типа и таблица, в которой они хранятся
+type FeatureSchema struct {
+	ModelType string         `db:"model_type" json:"model_type"`
+	TableName string         `db:"table_name" json:"table_name"`
+	Columns   []SchemaColumn `db:"columns" json:"columns"`
+	Version   int            `db:"version" json:"version"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// ColumnTypes - типы всех колонок, которые можно загрузить в таблицу схемы
+func (s FeatureSchema) ColumnTypes() map[string]string {
+	res := make(map[string]string, len(SystemColumns)+len(s.Columns))
+	for _, column := range SystemColumns {
+		res[column.Name] = column.Type
+	}
+	for _, column := range s.Columns {
+		res[column.Name] = column.Type
+	}
+	return res
+}
+
+// HasColumn - возвращает true, если признак column есть в схеме
+func (s FeatureSchema) HasColumn(column string) bool {
+	for _, schemaColumn := range s.Columns {
+		if schemaColumn.Name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportColumns - колонки таблицы схемы в порядке выгрузки, совпадающем с форматом загружаемого csv
+func (s FeatureSchema) ExportColumns() []SchemaColumn {
+	res := make([]SchemaColumn, 0, len(s.Columns)+4)
+	res = append(res, SchemaColumn{Name: "video_id", Type: ColumnTypeString})
+	res = append(res, s.Columns...)
+	res = append(res,
+		SchemaColumn{Name: "label", Type: ColumnTypeInt},
+		SchemaColumn{Name: "user_id", Type: ColumnTypeString},
+		SchemaColumn{Name: "created_at", Type: ColumnTypeTime},
+	)
+	return res
+}
+
+// StatsColumns - признаки схемы, по которым считается статистика датасета
+func (s FeatureSchema) StatsColumns() []string {
+	res := make([]string, 0, len(s.Columns))
+	for _, column := range s.Columns {
+		if column.Type == ColumnTypeFloat {
+			res = append(res, column.Name)
+		}
+	}
+	return res
+}
+
+var featureSchemaColumns = []string{
+	"model_type",
+	"table_name",
+	"columns",
+	"version",
+	"created_at",
+	"updated_at",
+}
+
+func SchemaTableName(modelType string) string {
+	return schemaTablePrefix + modelType
+}
+
+// ValidateFeatureSchema - проверяет, что тип модели и имена признаков можно использовать
+// как идентификаторы Postgres без обрезки и что признаки не повторяются
+func ValidateFeatureSchema(modelType string, columns []SchemaColumn) error {
+	op := "data.ValidateFeatureSchema"
+
+	if len(modelType) > maxModelTypeLength || !identifierRegexp.MatchString(modelType) {
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("invalid model_type: %s", modelType))
+	}
+
+	if len(columns) == 0 {
+		return app_errors.ErrValidationError.WrapError(op, "columns are empty")
+	}
+
+	names := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		if len(column.Name) > maxIdentifierLength || !identifierRegexp.MatchString(column.Name) {
+			return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("invalid column name: %s", column.Name))
+		}
+		if _, ok := columnSQLTypes[column.Type]; !ok {
+			return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("invalid type of column %s: %s", column.Name, column.Type))
+		}
+		if _, ok := names[column.Name]; ok {
+			return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("duplicate column name: %s", column.Name))
+		}
+		names[column.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+func (r *Repository) GetFeatureSchemas(ctx context.Context) ([]FeatureSchema, error) {
+	op := "data.Repository.GetFeatureSchemas"
+
+	q, i, err := r.queryBuilder.
+		Select(featureSchemaColumns...).
+		From(FeatureSchemasTable).
+		OrderBy("model_type").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]FeatureSchema, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+func (r *Repository) GetFeatureSchema(ctx context.Context, modelType string) (*FeatureSchema, error) {
+	op := "data.Repository.GetFeatureSchema"
+
+	q, i, err := r.queryBuilder.
+		Select(featureSchemaColumns...).
+		From(FeatureSchemasTable).
+		Where(sq.Eq{"model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res FeatureSchema
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+// ApplyFeatureSchema - создает таблицу признаков для нового типа модели или добавляет в существующую таблицу
+// новые колонки. Удалять колонки и менять их тип нельзя, так как в таблице уже могут быть признаки.
+// Системные колонки есть в любой таблице признаков, поэтому в схеме они пропускаются
+func (r *Repository) ApplyFeatureSchema(ctx context.Context, modelType string, columns []SchemaColumn) (*FeatureSchema, error) {
+	op := "data.Repository.ApplyFeatureSchema"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	// имена из схемы подставляются в DDL, поэтому проверяются до любых запросов
+	err := ValidateFeatureSchema(modelType, columns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	newColumns := make([]SchemaColumn, 0, len(columns))
+	for _, column := range columns {
+		if _, ok := reservedColumns[column.Name]; ok {
+			continue
+		}
+		newColumns = append(newColumns, column)
+	}
+
+	existing, err := r.GetFeatureSchema(ctx, modelType)
+	if err != nil && !app_errors.IsNotFound(err) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if existing == nil {
+		res, err := r.createFeatureSchema(ctx, modelType, newColumns)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		l.With(zap.String("model_type", modelType), zap.String("table", res.TableName)).
+			Info(fmt.Sprintf("%s: create feature schema", op))
+
+		return res, nil
+	}
+
+	// проверяем, что все существующие колонки остались в схеме с тем же типом
+	newTypes := make(map[string]string, len(newColumns))
+	for _, column := range newColumns {
+		newTypes[column.Name] = column.Type
+	}
+	existingTypes := make(map[string]string, len(existing.Columns))
+	for _, column := range existing.Columns {
+		existingTypes[column.Name] = column.Type
+
+		newType, ok := newTypes[column.Name]
+		if !ok {
+			return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("column %s can not be removed", column.Name))
+		}
+		if newType != column.Type {
+			return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("type of column %s can not be changed", column.Name))
+		}
+	}
+
+	added := make([]SchemaColumn, 0)
+	for _, column := range newColumns {
+		if _, ok := existingTypes[column.Name]; !ok {
+			added = append(added, column)
+		}
+	}
+	if len(added) == 0 {
+		return existing, nil
+	}
+
+	// новые колонки добавляются допускающими NULL, так как у уже сохраненных признаков их нет
+	alters := make([]string, 0, len(added))
+	for _, column := range added {
+		alters = append(alters, fmt.Sprintf("ADD COLUMN %s %s", pgx.Identifier{column.Name}.Sanitize(), columnSQLTypes[column.Type]))
+	}
+	_, err = r.db.Client(ctx).Exec(ctx, fmt.Sprintf("ALTER TABLE %s %s",
+		pgx.Identifier{existing.TableName}.Sanitize(),
+		strings.Join(alters, ", "),
+	))
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	q, i, err := r.queryBuilder.
+		Update(FeatureSchemasTable).
+		Set("columns", append(existing.Columns, added...)).
+		Set("version", sq.Expr("version + 1")).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"model_type": modelType}).
+		Suffix("RETURNING " + strings.Join(featureSchemaColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res FeatureSchema
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", modelType), zap.Int("added_columns", len(added)), zap.Int("version", res.Version)).
+		Info(fmt.Sprintf("%s: migrate feature schema", op))
+
+	return &res, nil
+}
+
+func (r *Repository) createFeatureSchema(ctx context.Context, modelType string, columns []SchemaColumn) (*FeatureSchema, error) {
+	op := "data.Repository.createFeatureSchema"
+
+	tableName := SchemaTableName(modelType)
+	table := pgx.Identifier{tableName}.Sanitize()
+
+	definitions := make([]string, 0, len(columns)+5)
+	definitions = append(definitions, "video_id CHAR(36)", "user_id CHAR(36) NOT NULL", "label INT")
+	for _, column := range columns {
+		definitions = append(definitions, fmt.Sprintf("%s %s", pgx.Identifier{column.Name}.Sanitize(), columnSQLTypes[column.Type]))
+	}
+	// таблица секционируется по месяцам, как и video_features, чтобы на нее распространялось удаление
+	// устаревших признаков. Первичный ключ секционированной таблицы должен включать created_at,
+	// поэтому вместо него используется индекс по feature_id
+	definitions = append(definitions, "feature_id BIGSERIAL", "created_at TIMESTAMPTZ NOT NULL DEFAULT now()")
+
+	_, err := r.db.Client(ctx).Exec(ctx, fmt.Sprintf("CREATE TABLE %s (%s) PARTITION BY RANGE (created_at)",
+		table,
+		strings.Join(definitions, ", "),
+	))
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, fmt.Sprintf("CREATE INDEX %s ON %s (user_id, video_id)",
+		pgx.Identifier{tableName + userVideoIndexSuffix}.Sanitize(),
+		table,
+	))
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, fmt.Sprintf("CREATE INDEX %s ON %s (feature_id)",
+		pgx.Identifier{tableName + featureIDIndexSuffix}.Sanitize(),
+		table,
+	))
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	q, i, err := r.queryBuilder.
+		Insert(FeatureSchemasTable).
+		Columns("model_type", "table_name", "columns").
+		Values(modelType, tableName, columns).
+		Suffix("RETURNING " + strings.Join(featureSchemaColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res FeatureSchema
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"testing"
+)
+
+func TestValidateFeatureSchema(t *testing.T) {
+	features := []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}, {Name: "blinks", Type: ColumnTypeInt}}
+
+	tests := []struct {
+		name      string
+		modelType string
+		columns   []SchemaColumn
+		wantErr   bool
+	}{
+		{name: "valid", modelType: "face_model", columns: features},
+		{name: "model type with max length", modelType: strings.Repeat("m", maxModelTypeLength), columns: features},
+		{name: "too long model type", modelType: strings.Repeat("m", maxModelTypeLength+1), columns: features, wantErr: true},
+		{name: "model type with uppercase", modelType: "FaceModel", columns: features, wantErr: true},
+		{name: "model type with quote", modelType: `face"; DROP TABLE video_features; --`, columns: features, wantErr: true},
+		{name: "model type starts with digit", modelType: "1model", columns: features, wantErr: true},
+		{name: "empty model type", modelType: "", columns: features, wantErr: true},
+		{name: "no columns", modelType: "face_model", columns: nil, wantErr: true},
+		{
+			name:      "column with max length",
+			modelType: "face_model",
+			columns:   []SchemaColumn{{Name: strings.Repeat("c", maxIdentifierLength), Type: ColumnTypeFloat}},
+		},
+		{
+			// после обрезки до 63 байт обе колонки стали бы одной
+			name:      "too long column",
+			modelType: "face_model",
+			columns: []SchemaColumn{
+				{Name: strings.Repeat("c", maxIdentifierLength) + "_a", Type: ColumnTypeFloat},
+				{Name: strings.Repeat("c", maxIdentifierLength) + "_b", Type: ColumnTypeFloat},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "column with space",
+			modelType: "face_model",
+			columns:   []SchemaColumn{{Name: "eye ratio", Type: ColumnTypeFloat}},
+			wantErr:   true,
+		},
+		{
+			name:      "column with unknown type",
+			modelType: "face_model",
+			columns:   []SchemaColumn{{Name: "ear", Type: ColumnTypeString}},
+			wantErr:   true,
+		},
+		{
+			name:      "duplicate column",
+			modelType: "face_model",
+			columns:   []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}, {Name: "ear", Type: ColumnTypeInt}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFeatureSchema(tt.modelType, tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateFeatureSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && appErrorCode(err) != app_errors.ErrValidationError.Code {
+				t.Errorf("ValidateFeatureSchema() error = %v, want validation error", err)
+			}
+		})
+	}
+}
+
+func TestApplyFeatureSchemaCreatesTable(t *testing.T) {
+	db := &fakeDB{}
+	db.get = func(dest interface{}, query string, args []interface{}) error {
+		if !strings.HasPrefix(query, "INSERT") {
+			return pgx.ErrNoRows
+		}
+		*dest.(*FeatureSchema) = FeatureSchema{
+			ModelType: args[0].(string),
+			TableName: args[1].(string),
+			Columns:   args[2].([]SchemaColumn),
+			Version:   1,
+		}
+		return nil
+	}
+	r := NewRepository(db)
+
+	columns := []SchemaColumn{
+		{Name: "ear", Type: ColumnTypeFloat},
+		// системные колонки есть в любой таблице и не дублируются
+		{Name: "label", Type: ColumnTypeInt},
+		{Name: "blinks", Type: ColumnTypeInt},
+	}
+	schema, err := r.ApplyFeatureSchema(context.Background(), "eye_model", columns)
+	if err != nil {
+		t.Fatalf("ApplyFeatureSchema() error = %v", err)
+	}
+
+	if schema.TableName != "features_eye_model" {
+		t.Errorf("TableName = %s, want features_eye_model", schema.TableName)
+	}
+	if len(schema.Columns) != 2 {
+		t.Errorf("Columns = %v, want ear and blinks", schema.Columns)
+	}
+
+	wantExecs := []string{
+		`CREATE TABLE "features_eye_model" (video_id CHAR(36), user_id CHAR(36) NOT NULL, label INT, "ear" DOUBLE PRECISION, "blinks" INT, feature_id BIGSERIAL, created_at TIMESTAMPTZ NOT NULL DEFAULT now()) PARTITION BY RANGE (created_at)`,
+		`CREATE INDEX "features_eye_model_user_id_video_id_idx" ON "features_eye_model" (user_id, video_id)`,
+		`CREATE INDEX "features_eye_model_feature_id_idx" ON "features_eye_model" (feature_id)`,
+	}
+	if len(db.execs) != len(wantExecs) {
+		t.Fatalf("execs = %q, want %q", db.execs, wantExecs)
+	}
+	for idx := range wantExecs {
+		if db.execs[idx] != wantExecs[idx] {
+			t.Errorf("exec[%d] = %s, want %s", idx, db.execs[idx], wantExecs[idx])
+		}
+	}
+}
+
+func TestApplyFeatureSchemaMigratesTable(t *testing.T) {
+	existing := FeatureSchema{
+		ModelType: "eye_model",
+		TableName: "features_eye_model",
+		Columns:   []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}},
+		Version:   1,
+	}
+
+	tests := []struct {
+		name      string
+		columns   []SchemaColumn
+		wantExecs []string
+		wantErr   bool
+	}{
+		{
+			name:    "add columns",
+			columns: []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}, {Name: "blinks", Type: ColumnTypeInt}, {Name: "mar", Type: ColumnTypeFloat}},
+			wantExecs: []string{
+				`ALTER TABLE "features_eye_model" ADD COLUMN "blinks" INT, ADD COLUMN "mar" DOUBLE PRECISION`,
+			},
+		},
+		{
+			name:    "same schema",
+			columns: []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}},
+		},
+		{
+			name:    "remove column",
+			columns: []SchemaColumn{{Name: "blinks", Type: ColumnTypeInt}},
+			wantErr: true,
+		},
+		{
+			name:    "change column type",
+			columns: []SchemaColumn{{Name: "ear", Type: ColumnTypeInt}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid new column",
+			columns: []SchemaColumn{{Name: "ear", Type: ColumnTypeFloat}, {Name: `mar"`, Type: ColumnTypeFloat}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			db.get = func(dest interface{}, query string, args []interface{}) error {
+				res := existing
+				if strings.HasPrefix(query, "UPDATE") {
+					res.Columns = args[0].([]SchemaColumn)
+					res.Version++
+				}
+				*dest.(*FeatureSchema) = res
+				return nil
+			}
+			r := NewRepository(db)
+
+			schema, err := r.ApplyFeatureSchema(context.Background(), existing.ModelType, tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyFeatureSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if appErrorCode(err) != app_errors.ErrValidationError.Code {
+					t.Errorf("ApplyFeatureSchema() error = %v, want validation error", err)
+				}
+				if len(db.execs) != 0 {
+					t.Errorf("execs = %q, want none on error", db.execs)
+				}
+				return
+			}
+
+			if len(db.execs) != len(tt.wantExecs) {
+				t.Fatalf("execs = %q, want %q", db.execs, tt.wantExecs)
+			}
+			for idx := range tt.wantExecs {
+				if db.execs[idx] != tt.wantExecs[idx] {
+					t.Errorf("exec[%d] = %s, want %s", idx, db.execs[idx], tt.wantExecs[idx])
+				}
+			}
+			if len(schema.Columns) != len(tt.columns) {
+				t.Errorf("Columns = %v, want %v", schema.Columns, tt.columns)
+			}
+		})
+	}
+}
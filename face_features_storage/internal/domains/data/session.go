@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	SessionsTable = "video_sessions"
+)
+
+type VideoSession struct {
+	VideoID          string     `db:"video_id" json:"video_id"`
+	UserID           string     `db:"user_id" json:"user_id"`
+	StartedAt        time.Time  `db:"started_at" json:"started_at"`
+	EndedAt          *time.Time `db:"ended_at" json:"ended_at"`
+	FPS              *float64   `db:"fps" json:"fps"`
+	DeviceInfo       *string    `db:"device_info" json:"device_info"`
+	ClientAppVersion *string    `db:"client_app_version" json:"client_app_version"`
+	FrameCount       int        `db:"frame_count" json:"frame_count"`
+}
+
+func (s VideoSession) IsOpen() bool {
+	return s.EndedAt == nil
+}
+
+var sessionColumns = []string{
+	"video_id",
+	"user_id",
+	"started_at",
+	"ended_at",
+	"fps",
+	"device_info",
+	"client_app_version",
+	"frame_count",
+}
+
+func (r *Repository) OpenVideoSession(ctx context.Context, session VideoSession) (*VideoSession, error) {
+	op := "data.Repository.OpenVideoSession"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	setMap := sq.Eq{
+		"video_id":           session.VideoID,
+		"user_id":            session.UserID,
+		"fps":                session.FPS,
+		"device_info":        session.DeviceInfo,
+		"client_app_version": session.ClientAppVersion,
+	}
+
+	q, i, err := r.queryBuilder.
+		Insert(SessionsTable).
+		SetMap(setMap).
+		Suffix("ON CONFLICT (video_id) DO NOTHING RETURNING " + strings.Join(sessionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res VideoSession
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrAlreadyExists.WrapError(op, "video session already exists")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("video_id", session.VideoID), zap.String("user_id", session.UserID)).
+		Info(fmt.Sprintf("%s: open video session", op))
+
+	return &res, nil
+}
+
+// CloseVideoSession - закрывает открытую сессию пользователя, при frameCount == nil
+// количество кадров берется по сохраненным признакам
+func (r *Repository) CloseVideoSession(ctx context.Context, videoID, userID string, frameCount *int) (*VideoSession, error) {
+	op := "data.Repository.CloseVideoSession"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	var frameCountValue interface{}
+	if frameCount != nil {
+		frameCountValue = *frameCount
+	} else {
+		frameCountValue = sq.Expr(fmt.Sprintf("(SELECT count(*) FROM %s WHERE video_id = ? AND user_id = ?)", FeaturesTable),
+			videoID, userID)
+	}
+
+	q, i, err := r.queryBuilder.
+		Update(SessionsTable).
+		Set("ended_at", sq.Expr("now()")).
+		Set("frame_count", frameCountValue).
+		Where(sq.Eq{"video_id": videoID, "user_id": userID, "ended_at": nil}).
+		Suffix("RETURNING " + strings.Join(sessionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res VideoSession
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, "open video session not found")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("video_id", videoID), zap.Int("frame_count", res.FrameCount)).
+		Info(fmt.Sprintf("%s: close video session", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetVideoSession(ctx context.Context, videoID string) (*VideoSession, error) {
+	op := "data.Repository.GetVideoSession"
+
+	q, i, err := r.queryBuilder.
+		Select(sessionColumns...).
+		From(SessionsTable).
+		Where(sq.Eq{"video_id": videoID}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res VideoSession
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+// GetVideoSessions - возвращает сессии пользователя, при isOpen == nil возвращаются все сессии
+func (r *Repository) GetVideoSessions(ctx context.Context, userID string, isOpen *bool) ([]VideoSession, error) {
+	op := "data.Repository.GetVideoSessions"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(sessionColumns...).
+		From(SessionsTable).
+		Where(sq.Eq{"user_id": userID})
+
+	if isOpen != nil {
+		if *isOpen {
+			qb = qb.Where(sq.Eq{"ended_at": nil})
+		} else {
+			qb = qb.Where(sq.NotEq{"ended_at": nil})
+		}
+	}
+
+	q, i, err := qb.OrderBy("started_at DESC").ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]VideoSession, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: find video sessions by user_id", op))
+
+	return res, nil
+}
+
+// checkUploadSession - проверяет, что признаки ссылаются на открытую сессию загружающего пользователя
+func (r *Repository) checkUploadSession(ctx context.Context, videoID, userID string) error {
+	op := "data.Repository.checkUploadSession"
+
+	session, err := r.GetVideoSession(ctx, videoID)
+	if err != nil {
+		if app_errors.IsNotFound(err) {
+			return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("video session %s not found", videoID))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if strings.TrimSpace(session.UserID) != userID {
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("video session %s belongs to another user", videoID))
+	}
+
+	if !session.IsOpen() {
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("video session %s is closed", videoID))
+	}
+
+	return nil
+}
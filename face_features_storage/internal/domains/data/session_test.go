@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCloseVideoSessionCountsOnlyUserFeatures(t *testing.T) {
+	const (
+		videoID = "22222222-2222-2222-2222-222222222222"
+		userID  = "11111111-1111-1111-1111-111111111111"
+	)
+
+	var query string
+	var args []interface{}
+	db := &fakeDB{}
+	db.get = func(dest interface{}, q string, a []interface{}) error {
+		query, args = q, a
+		*dest.(*VideoSession) = VideoSession{VideoID: videoID, UserID: userID}
+		return nil
+	}
+	r := NewRepository(db)
+
+	_, err := r.CloseVideoSession(context.Background(), videoID, userID, nil)
+	if err != nil {
+		t.Fatalf("CloseVideoSession() error = %v", err)
+	}
+
+	// признаки с тем же video_id, загруженные другим пользователем, не должны попасть в количество кадров
+	if !strings.Contains(query, "WHERE video_id = $1 AND user_id = $2)") {
+		t.Errorf("query = %s, want frame count filtered by video_id and user_id", query)
+	}
+	if len(args) < 2 || args[0] != videoID || args[1] != userID {
+		t.Errorf("args = %v, want [%s %s ...]", args, videoID, userID)
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/go-chi/chi/v5"
 	"mime/multipart"
 	"net/http"
 )
@@ -21,8 +22,27 @@ import (
 //	@Failure	400	{object}	app_errors.AppError
 //	@Router		/face_model/save_features [post]
 func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request) error {
+	return c.saveFeatures(w, r, data.FaceModel)
+}
+
+// SaveFeatures godoc
+//
+//	@Summary	Принимает csv файл с признаками модели, колонки файла проверяются по схеме признаков типа модели
+//	@ID			save features
+//	@Tags		Save CSV
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Param		file		formData	file	true	"Загружаемый csv"
+//	@Success	204
+//	@Failure	400	{object}	app_errors.AppError
+//	@Failure	404	{object}	app_errors.AppError
+//	@Router		/{model_type}/save_features [post]
+func (c *CoreHandler) SaveFeatures(w http.ResponseWriter, r *http.Request) error {
+	return c.saveFeatures(w, r, chi.URLParam(r, "model_type"))
+}
+
+func (c *CoreHandler) saveFeatures(w http.ResponseWriter, r *http.Request, modelType string) error {
 	// объявляем текущую операцию для оборачивания ошибки
-	op := "handlers.CoreHandler.SaveVideoFeatures"
+	op := "handlers.CoreHandler.saveFeatures"
 	// берем логгер из контекста
 	l := logger.EntryWithRequestIDFromContext(r.Context())
 
@@ -33,8 +53,6 @@ func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request)
 	}
 	// берем строковое значение user_id из формы
 	userID := r.FormValue("user_id")
-	// берем строковое значение model_type из формы
-	modelType := r.FormValue("model_type")
 
 	// закрываем файл при выходе из функции
 	defer func(file multipart.File) {
@@ -44,11 +62,17 @@ func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request)
 		}
 	}(file)
 
+	// находим схему признаков, по которой проверяется файл
+	schema, err := c.dataRepository.GetFeatureSchema(r.Context(), modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	// делаем сохранение данных в транзакции
 	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
 		var featuresCount uint64
 		// сохраняем данные файла в таблице признаков
-		featuresCount, err = c.dataRepository.SaveFaceVideoFeatures(txCtx, file)
+		featuresCount, err = c.dataRepository.SaveFeatures(txCtx, *schema, file, userID)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
@@ -65,14 +89,15 @@ func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Возвращаем пустой ответ со статусом 204
-	api.WriteSuccess(r.Context(), w, struct{}{}, http.StatusNoContent, l)
+	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
 type IncreaseFeaturesRequest struct {
-	ModelType     string `json:"model_type"  validate:"required"`
-	UserID        string `json:"user_id"  validate:"required"`
-	FeaturesCount int    `json:"features_count"  validate:"required"`
+	ModelType      string `json:"model_type"  validate:"required"`
+	UserID         string `json:"user_id"  validate:"required"`
+	FeaturesCount  int    `json:"features_count"`
+	RelabeledCount int    `json:"relabeled_count"`
 }
 
 func (c *CoreHandler) sendFeaturesCount(userID, modelType string, featuresCount int) error {
@@ -86,6 +111,38 @@ func (c *CoreHandler) sendFeaturesCount(userID, modelType string, featuresCount
 		FeaturesCount: featuresCount,
 	}
 
+	err := c.sendFeaturesChange(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CoreHandler) sendRelabeledCount(userID, modelType string, relabeledCount int) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.sendRelabeledCount"
+
+	// определяем тело запроса
+	req := IncreaseFeaturesRequest{
+		ModelType:      modelType,
+		UserID:         userID,
+		RelabeledCount: relabeledCount,
+	}
+
+	err := c.sendFeaturesChange(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sendFeaturesChange - отправляет изменения признаков пользователя в сервис работы с моделями
+func (c *CoreHandler) sendFeaturesChange(req IncreaseFeaturesRequest) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.sendFeaturesChange"
+
 	// сериализуем тело запроса в JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
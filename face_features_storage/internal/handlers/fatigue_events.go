@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	customTools "github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/tools"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultFatigueEventsPageSize - размер страницы событий, если клиент не задал limit
+	defaultFatigueEventsPageSize = 100
+	// maxFatigueEventsPageSize - максимальный размер страницы событий
+	maxFatigueEventsPageSize = 1000
+)
+
+type FatigueEventRequest struct {
+	UserID       string    `json:"user_id" validate:"required"`
+	SessionID    string    `json:"session_id" validate:"required,max=64"`
+	ModelType    string    `json:"model_type" validate:"required,max=64"`
+	ModelVersion string    `json:"model_version" validate:"required,max=512"`
+	Confidence   float64   `json:"confidence" validate:"gt=0,lte=1"`
+	OccurredAt   time.Time `json:"occurred_at" validate:"required"`
+}
+
+type SaveFatigueEventsRequest struct {
+	Events []FatigueEventRequest `json:"events" validate:"required,min=1,max=1000,dive"`
+}
+
+// SaveFatigueEvents godoc
+//
+//	@Summary		Сохраняет события усталости, зафиксированные клиентом
+//	@Description	Доступна только внутренним сервисам, клиенты отправляют события через user_data_service.
+//	@ID				save fatigue events
+//	@Tags			Fatigue events
+//	@Param			X-Service-Token	header		string						true	"Токен внутренних сервисов"
+//	@Param			events_data		body		SaveFatigueEventsRequest	true	"Пакет событий"
+//	@Success		201				{array}		data.FatigueEvent
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/fatigue_events [post]
+func (c *CoreHandler) SaveFatigueEvents(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.SaveFatigueEvents"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// десереализуем данные из тела запроса
+	var req SaveFatigueEventsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	events := make([]data.FatigueEvent, 0, len(req.Events))
+	for _, event := range req.Events {
+		events = append(events, data.FatigueEvent{
+			UserID:       event.UserID,
+			SessionID:    event.SessionID,
+			ModelType:    event.ModelType,
+			ModelVersion: event.ModelVersion,
+			Confidence:   event.Confidence,
+			OccurredAt:   event.OccurredAt,
+		})
+	}
+
+	// сохраняем события в БД, предупреждения по ним в вебхуки отправит воркер fatigue-alerts
+	saved, err := c.dataRepository.SaveFatigueEvents(r.Context(), events)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем сохраненные события со статусом 201
+	api.WriteSuccess(r.Context(), w, saved, http.StatusCreated, l)
+	return nil
+}
+
+// GetFatigueEvents godoc
+//
+//	@Summary		Возвращает события усталости
+//	@Description	Возвращает одну страницу событий, курсор следующей страницы передается в заголовке X-Next-Cursor
+//	@ID				get fatigue events
+//	@Tags			Fatigue events
+//	@Param			user_id		query		string	false	"ID пользователя"
+//	@Param			session_id	query		string	false	"ID сессии"
+//	@Param			from		query		string	false	"Начало периода (RFC3339)"
+//	@Param			to			query		string	false	"Конец периода (RFC3339)"
+//	@Param			after		query		int		false	"Курсор: события после данного id"
+//	@Param			limit		query		int		false	"Размер страницы"
+//	@Success		200			{array}		data.FatigueEvent
+//	@Failure		400			{object}	app_errors.AppError
+//	@Router			/fatigue_events [get]
+func (c *CoreHandler) GetFatigueEvents(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetFatigueEvents"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	query := r.URL.Query()
+
+	// разбираем параметры фильтрации
+	filter, err := parseFatigueEventsFilter(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// разбираем параметры пагинации
+	var afterID int64
+	if after := query.Get("after"); after != "" {
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+	}
+
+	limit := uint64(defaultFatigueEventsPageSize)
+	if limitString := query.Get("limit"); limitString != "" {
+		limit, err = strconv.ParseUint(limitString, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		if limit == 0 || limit > maxFatigueEventsPageSize {
+			return app_errors.ErrValidationError.WrapError(op,
+				fmt.Sprintf("limit must be between 1 and %d", maxFatigueEventsPageSize))
+		}
+	}
+
+	// находим страницу событий
+	events, err := c.dataRepository.GetFatigueEvents(r.Context(), filter, afterID, limit)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if uint64(len(events)) == limit {
+		w.Header().Set(NextCursorHeader, strconv.FormatInt(events[len(events)-1].EventID, 10))
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, events, http.StatusOK, l)
+	return nil
+}
+
+// GetFatigueEventsSummary godoc
+//
+//	@Summary	Возвращает количество событий усталости по пользователям и сессиям (сменам) за период
+//	@ID			get fatigue events summary
+//	@Tags		Fatigue events
+//	@Param		user_id		query		string	false	"ID пользователя"
+//	@Param		session_id	query		string	false	"ID сессии"
+//	@Param		from		query		string	false	"Начало периода (RFC3339)"
+//	@Param		to			query		string	false	"Конец периода (RFC3339)"
+//	@Success	200			{array}		data.FatigueEventsSummary
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/fatigue_events/summary [get]
+func (c *CoreHandler) GetFatigueEventsSummary(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetFatigueEventsSummary"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// разбираем параметры фильтрации
+	filter, err := parseFatigueEventsFilter(r.URL.Query())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// считаем сводку событий
+	summary, err := c.dataRepository.GetFatigueEventsSummary(r.Context(), filter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, summary, http.StatusOK, l)
+	return nil
+}
+
+func parseFatigueEventsFilter(query url.Values) (data.FatigueEventsFilter, error) {
+	op := "handlers.parseFatigueEventsFilter"
+
+	var filter data.FatigueEventsFilter
+
+	if userID := query.Get("user_id"); userID != "" {
+		filter.UserID = tools.PString(userID)
+	}
+	if sessionID := query.Get("session_id"); sessionID != "" {
+		filter.SessionID = tools.PString(sessionID)
+	}
+	if from := query.Get("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		filter.From = &fromTime
+	}
+	if to := query.Get("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		filter.To = &toTime
+	}
+
+	return filter, nil
+}
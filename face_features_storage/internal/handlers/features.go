@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/tools"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// featuresBatchSize - размер страницы при потоковой выгрузке всех признаков
+	featuresBatchSize = 5000
+	// maxFeaturesPageSize - максимальный размер страницы, запрашиваемой клиентом
+	maxFeaturesPageSize = 10000
+
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// GetVideoFeatures godoc
+//
+//	@Summary		Выгружает признаки из видео
+//	@Description	Без limit выгружает потоком все подходящие признаки. С limit возвращает одну страницу,
+//	@Description	курсор следующей страницы передается в заголовке X-Next-Cursor.
+//	@Description	Доступна только внутренним сервисам, нужен хотя бы один фильтр.
+//	@ID				get features
+//	@Tags			Features
+//	@Param			X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param			user_id		query	string	false	"ID пользователя"
+//	@Param			video_id	query	string	false	"ID видео"
+//	@Param			label		query	int		false	"Метка класса"
+//	@Param			from		query	string	false	"Начало периода загрузки (RFC3339)"
+//	@Param			to			query	string	false	"Конец периода загрузки (RFC3339)"
+//	@Param			after		query	int		false	"Курсор: признаки после данного id"
+//	@Param			limit		query	int		false	"Размер страницы"
+//	@Param			format		query	string	false	"Формат выгрузки"	Enums(csv, jsonl, parquet)
+//	@Success		200
+//	@Failure		400	{object}	app_errors.AppError
+//	@Router			/face_model/features [get]
+func (c *CoreHandler) GetVideoFeatures(w http.ResponseWriter, r *http.Request) error {
+	return c.getFeatures(w, r, data.FaceModel)
+}
+
+// GetFeatures godoc
+//
+//	@Summary		Выгружает признаки модели, колонки выгрузки совпадают с колонками схемы признаков типа модели
+//	@Description	Без limit выгружает потоком все подходящие признаки. С limit возвращает одну страницу,
+//	@Description	курсор следующей страницы передается в заголовке X-Next-Cursor.
+//	@Description	Доступна только внутренним сервисам, нужен хотя бы один фильтр.
+//	@ID				get model features
+//	@Tags			Features
+//	@Param			X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param			model_type	path	string	true	"Тип модели"
+//	@Param			user_id		query	string	false	"ID пользователя"
+//	@Param			video_id	query	string	false	"ID видео"
+//	@Param			label		query	int		false	"Метка класса"
+//	@Param			from		query	string	false	"Начало периода загрузки (RFC3339)"
+//	@Param			to			query	string	false	"Конец периода загрузки (RFC3339)"
+//	@Param			after		query	int		false	"Курсор: признаки после данного id"
+//	@Param			limit		query	int		false	"Размер страницы"
+//	@Param			format		query	string	false	"Формат выгрузки"	Enums(csv, jsonl, parquet)
+//	@Success		200
+//	@Failure		400	{object}	app_errors.AppError
+//	@Failure		404	{object}	app_errors.AppError
+//	@Router			/{model_type}/features [get]
+func (c *CoreHandler) GetFeatures(w http.ResponseWriter, r *http.Request) error {
+	return c.getFeatures(w, r, chi.URLParam(r, "model_type"))
+}
+
+func (c *CoreHandler) getFeatures(w http.ResponseWriter, r *http.Request, modelType string) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.getFeatures"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	query := r.URL.Query()
+
+	// разбираем параметры фильтрации
+	filter, err := parseFeaturesFilter(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// выгрузка без фильтров отдала бы признаки всех пользователей за все время
+	if filter.IsEmpty() {
+		return app_errors.ErrValidationError.WrapError(op, "at least one filter is required")
+	}
+
+	// разбираем параметры пагинации
+	var afterID int64
+	if after := query.Get("after"); after != "" {
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+	}
+
+	var limit uint64
+	if limitString := query.Get("limit"); limitString != "" {
+		limit, err = strconv.ParseUint(limitString, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		if limit == 0 || limit > maxFeaturesPageSize {
+			return app_errors.ErrValidationError.WrapError(op,
+				fmt.Sprintf("limit must be between 1 and %d", maxFeaturesPageSize))
+		}
+	}
+
+	// колонки выгрузки определяются схемой признаков типа модели
+	schema, err := c.dataRepository.GetFeatureSchema(r.Context(), modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	writer, err := NewFeaturesWriter(query.Get("format"), schema.ExportColumns(), w)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// первую страницу получаем до записи заголовков, чтобы иметь возможность вернуть ошибку
+	pageSize := uint64(featuresBatchSize)
+	if limit > 0 {
+		pageSize = limit
+	}
+	rows, err := c.dataRepository.GetFeatures(r.Context(), *schema, filter, afterID, pageSize)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.Header().Set("Content-Type", writer.ContentType())
+	if limit > 0 && uint64(len(rows)) == limit {
+		w.Header().Set(NextCursorHeader, strconv.FormatInt(rows[len(rows)-1].FeatureID(), 10))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		err = writer.Write(rows)
+		if err != nil {
+			// заголовки уже отправлены, поэтому ошибку можно только залогировать
+			l.Error(fmt.Sprintf("%s: %v", op, err))
+			return nil
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		// в режиме одной страницы или при достижении конца выборки завершаем выгрузку
+		if limit > 0 || uint64(len(rows)) < pageSize {
+			break
+		}
+
+		rows, err = c.dataRepository.GetFeatures(r.Context(), *schema, filter, rows[len(rows)-1].FeatureID(), pageSize)
+		if err != nil {
+			l.Error(fmt.Sprintf("%s: %v", op, err))
+			return nil
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		l.Error(fmt.Sprintf("%s: %v", op, err))
+	}
+
+	return nil
+}
+
+func parseFeaturesFilter(query url.Values) (data.FeaturesFilter, error) {
+	op := "handlers.parseFeaturesFilter"
+
+	var filter data.FeaturesFilter
+
+	if userID := query.Get("user_id"); userID != "" {
+		filter.UserID = tools.PString(userID)
+	}
+	if videoID := query.Get("video_id"); videoID != "" {
+		filter.VideoID = tools.PString(videoID)
+	}
+	if labelString := query.Get("label"); labelString != "" {
+		label, err := strconv.Atoi(labelString)
+		if err != nil {
+			return filter, app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		filter.Label = tools.PInt(label)
+	}
+	if from := query.Get("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		filter.From = &fromTime
+	}
+	if to := query.Get("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		filter.To = &toTime
+	}
+
+	return filter, nil
+}
+
+// GetDatasetStats godoc
+//
+//	@Summary	Возвращает статистику датасета признаков лица пользователя
+//	@ID			get dataset stats
+//	@Tags		Features
+//	@Param		user_id	query		string	true	"ID пользователя"
+//	@Success	200		{object}	data.DatasetStats
+//	@Failure	400		{object}	app_errors.AppError
+//	@Router		/face_model/stats [get]
+func (c *CoreHandler) GetDatasetStats(w http.ResponseWriter, r *http.Request) error {
+	return c.getDatasetStats(w, r, data.FaceModel)
+}
+
+// GetModelDatasetStats godoc
+//
+//	@Summary	Возвращает статистику датасета признаков пользователя, статистики считаются по вещественным признакам схемы
+//	@ID			get model dataset stats
+//	@Tags		Features
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Success	200			{object}	data.DatasetStats
+//	@Failure	400			{object}	app_errors.AppError
+//	@Failure	404			{object}	app_errors.AppError
+//	@Router		/{model_type}/stats [get]
+func (c *CoreHandler) GetModelDatasetStats(w http.ResponseWriter, r *http.Request) error {
+	return c.getDatasetStats(w, r, chi.URLParam(r, "model_type"))
+}
+
+func (c *CoreHandler) getDatasetStats(w http.ResponseWriter, r *http.Request, modelType string) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.getDatasetStats"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// берем id пользователя из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+
+	schema, err := c.dataRepository.GetFeatureSchema(r.Context(), modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// считаем статистику датасета пользователя
+	stats, err := c.dataRepository.GetDatasetStats(r.Context(), *schema, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, stats, http.StatusOK, l)
+	return nil
+}
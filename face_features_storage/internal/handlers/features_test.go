@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testServiceToken = "service-token"
+
+// fakeFeaturesRepository - хранилище признаков в памяти, отдает признаки страницами по курсору feature_id
+type fakeFeaturesRepository struct {
+	DataRepository
+
+	features []data.Feature
+	// afterIDs - курсоры, с которыми запрашивались страницы
+	afterIDs []int64
+}
+
+func newFakeFeaturesRepository(count int) *fakeFeaturesRepository {
+	r := &fakeFeaturesRepository{}
+	for idx := 1; idx <= count; idx++ {
+		r.features = append(r.features, data.Feature{
+			"feature_id": int64(idx),
+			"video_id":   fmt.Sprintf("video-%d", idx),
+			"user_id":    "user",
+		})
+	}
+	return r
+}
+
+func (r *fakeFeaturesRepository) GetFeatureSchema(_ context.Context, modelType string) (*data.FeatureSchema, error) {
+	return &data.FeatureSchema{ModelType: modelType, TableName: "features_" + modelType}, nil
+}
+
+func (r *fakeFeaturesRepository) GetFeatures(_ context.Context, _ data.FeatureSchema, _ data.FeaturesFilter, afterID int64, limit uint64) ([]data.Feature, error) {
+	r.afterIDs = append(r.afterIDs, afterID)
+
+	var res []data.Feature
+	for _, feature := range r.features {
+		if feature.FeatureID() > afterID && uint64(len(res)) < limit {
+			res = append(res, feature)
+		}
+	}
+	return res, nil
+}
+
+// exportedVideoIDs - video_id строк выгрузки в csv без заголовка
+func exportedVideoIDs(t *testing.T, body string) []string {
+	t.Helper()
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+
+	var res []string
+	for _, record := range records[1:] {
+		res = append(res, record[0])
+	}
+	return res
+}
+
+func TestGetFeaturesCursor(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantCursor   string
+		wantVideoIDs []string
+	}{
+		{
+			name:         "first page",
+			query:        "user_id=user&limit=2",
+			wantStatus:   http.StatusOK,
+			wantCursor:   "2",
+			wantVideoIDs: []string{"video-1", "video-2"},
+		},
+		{
+			name:         "next page",
+			query:        "user_id=user&limit=2&after=2",
+			wantStatus:   http.StatusOK,
+			wantCursor:   "4",
+			wantVideoIDs: []string{"video-3", "video-4"},
+		},
+		{
+			name:         "last page",
+			query:        "user_id=user&limit=2&after=4",
+			wantStatus:   http.StatusOK,
+			wantVideoIDs: []string{"video-5"},
+		},
+		{
+			name:         "stream without limit",
+			query:        "user_id=user",
+			wantStatus:   http.StatusOK,
+			wantVideoIDs: []string{"video-1", "video-2", "video-3", "video-4", "video-5"},
+		},
+		{
+			name:       "limit out of range",
+			query:      "user_id=user&limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid cursor",
+			query:      "user_id=user&limit=2&after=abc",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeFeaturesRepository(5)
+			c := NewCoreHandler(repo, "", testServiceToken, nil, validator.New(), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/face_model/features?"+tt.query, nil)
+			req.Header.Set(ServiceTokenHeader, testServiceToken)
+			rec := httptest.NewRecorder()
+			c.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if cursor := rec.Header().Get(NextCursorHeader); cursor != tt.wantCursor {
+				t.Errorf("%s = %q, want %q", NextCursorHeader, cursor, tt.wantCursor)
+			}
+
+			videoIDs := exportedVideoIDs(t, rec.Body.String())
+			if strings.Join(videoIDs, ",") != strings.Join(tt.wantVideoIDs, ",") {
+				t.Errorf("video ids = %v, want %v", videoIDs, tt.wantVideoIDs)
+			}
+		})
+	}
+}
+
+func TestGetFeaturesStreamsAllBatches(t *testing.T) {
+	repo := newFakeFeaturesRepository(2*featuresBatchSize + 1)
+	c := NewCoreHandler(repo, "", testServiceToken, nil, validator.New(), zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/face_model/features?user_id=user", nil)
+	req.Header.Set(ServiceTokenHeader, testServiceToken)
+	rec := httptest.NewRecorder()
+	c.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if cursor := rec.Header().Get(NextCursorHeader); cursor != "" {
+		t.Errorf("%s = %q, want none in stream mode", NextCursorHeader, cursor)
+	}
+
+	// каждая следующая порция запрашивается после последнего feature_id предыдущей
+	wantAfterIDs := []int64{0, featuresBatchSize, 2 * featuresBatchSize}
+	if fmt.Sprint(repo.afterIDs) != fmt.Sprint(wantAfterIDs) {
+		t.Errorf("after ids = %v, want %v", repo.afterIDs, wantAfterIDs)
+	}
+	if videoIDs := exportedVideoIDs(t, rec.Body.String()); len(videoIDs) != 2*featuresBatchSize+1 {
+		t.Errorf("exported %d rows, want %d", len(videoIDs), 2*featuresBatchSize+1)
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// FeaturesWriter - потоковая запись признаков в ответ в выбранном формате
+type FeaturesWriter interface {
+	ContentType() string
+	Write(rows []data.Feature) error
+	Close() error
+}
+
+// NewFeaturesWriter - создает запись признаков с колонками columns в порядке выгрузки
+func NewFeaturesWriter(format string, columns []data.SchemaColumn, w io.Writer) (FeaturesWriter, error) {
+	op := "handlers.NewFeaturesWriter"
+
+	switch format {
+	case "", FormatCSV:
+		return &csvFeaturesWriter{writer: csv.NewWriter(w), columns: columns}, nil
+	case FormatJSONL:
+		return &jsonlFeaturesWriter{encoder: json.NewEncoder(w), columns: columns}, nil
+	case FormatParquet:
+		return newParquetFeaturesWriter(columns, w), nil
+	default:
+		return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("unknown format: %s", format))
+	}
+}
+
+type csvFeaturesWriter struct {
+	writer        *csv.Writer
+	columns       []data.SchemaColumn
+	headerWritten bool
+}
+
+func (c *csvFeaturesWriter) ContentType() string {
+	return "text/csv"
+}
+
+func (c *csvFeaturesWriter) Write(rows []data.Feature) error {
+	if !c.headerWritten {
+		// заголовок совпадает с форматом загружаемого csv
+		header := make([]string, len(c.columns))
+		for idx, column := range c.columns {
+			header[idx] = column.Name
+		}
+		err := c.writer.Write(header)
+		if err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	record := make([]string, len(c.columns))
+	for _, row := range rows {
+		for idx, column := range c.columns {
+			record[idx] = formatFeatureValue(row[column.Name])
+		}
+
+		err := c.writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvFeaturesWriter) Close() error {
+	// пустая выборка все равно должна содержать заголовок
+	return c.Write(nil)
+}
+
+// formatFeatureValue - значение колонки в csv, NULL записывается пустой строкой
+func formatFeatureValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case int32:
+		return strconv.Itoa(int(v))
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type jsonlFeaturesWriter struct {
+	encoder *json.Encoder
+	columns []data.SchemaColumn
+}
+
+func (j *jsonlFeaturesWriter) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (j *jsonlFeaturesWriter) Write(rows []data.Feature) error {
+	for _, row := range rows {
+		// курсор пагинации в выгрузку не попадает
+		object := make(map[string]any, len(j.columns))
+		for _, column := range j.columns {
+			object[column.Name] = row[column.Name]
+		}
+
+		err := j.encoder.Encode(object)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonlFeaturesWriter) Close() error {
+	return nil
+}
+
+// parquetColumnNodes - типы колонок parquet для типов колонок схемы, все колонки допускают NULL
+var parquetColumnNodes = map[string]parquet.Node{
+	data.ColumnTypeInt:    parquet.Optional(parquet.Int(32)),
+	data.ColumnTypeFloat:  parquet.Optional(parquet.Leaf(parquet.DoubleType)),
+	data.ColumnTypeString: parquet.Optional(parquet.String()),
+	data.ColumnTypeTime:   parquet.Optional(parquet.Timestamp(parquet.Microsecond)),
+}
+
+type parquetFeaturesWriter struct {
+	writer *parquet.Writer
+	// columnNames - имена колонок в порядке колонок схемы parquet
+	columnNames []string
+}
+
+func newParquetFeaturesWriter(columns []data.SchemaColumn, w io.Writer) *parquetFeaturesWriter {
+	group := make(parquet.Group, len(columns))
+	for _, column := range columns {
+		group[column.Name] = parquetColumnNodes[column.Type]
+	}
+	schema := parquet.NewSchema("features", group)
+
+	// колонки группы parquet упорядочены по имени, а не в порядке схемы признаков
+	columnNames := make([]string, 0, len(columns))
+	for _, path := range schema.Columns() {
+		columnNames = append(columnNames, path[0])
+	}
+
+	return &parquetFeaturesWriter{writer: parquet.NewWriter(w, schema), columnNames: columnNames}
+}
+
+func (p *parquetFeaturesWriter) ContentType() string {
+	return "application/vnd.apache.parquet"
+}
+
+func (p *parquetFeaturesWriter) Write(rows []data.Feature) error {
+	parquetRows := make([]parquet.Row, 0, len(rows))
+	for _, row := range rows {
+		parquetRow := make(parquet.Row, len(p.columnNames))
+		for idx, name := range p.columnNames {
+			value := row[name]
+			if value == nil {
+				parquetRow[idx] = parquet.NullValue().Level(0, 0, idx)
+				continue
+			}
+			parquetRow[idx] = parquetFeatureValue(value).Level(0, 1, idx)
+		}
+		parquetRows = append(parquetRows, parquetRow)
+	}
+
+	_, err := p.writer.WriteRows(parquetRows)
+	if err != nil {
+		return err
+	}
+	// каждая страница выборки записывается отдельной row group
+	return p.writer.Flush()
+}
+
+func (p *parquetFeaturesWriter) Close() error {
+	return p.writer.Close()
+}
+
+func parquetFeatureValue(value any) parquet.Value {
+	switch v := value.(type) {
+	case time.Time:
+		return parquet.Int64Value(v.UnixMicro())
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	default:
+		return parquet.ValueOf(v)
+	}
+}
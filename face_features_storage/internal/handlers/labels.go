@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	customTools "github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type CorrectLabelsRequest struct {
+	VideoID   string  `json:"video_id" validate:"required"`
+	UserID    string  `json:"user_id" validate:"required"`
+	FrameFrom *int    `json:"frame_from" validate:"omitempty,gte=0"`
+	FrameTo   *int    `json:"frame_to" validate:"omitempty,gte=0"`
+	Label     *int    `json:"label" validate:"required,oneof=0 1"`
+	Reason    *string `json:"reason" validate:"omitempty,max=256"`
+}
+
+// CorrectLabels godoc
+//
+//	@Summary	Исправляет метки класса признаков видео или диапазона кадров видео
+//	@ID			correct labels
+//	@Tags		Labels
+//	@Param		correction_data	body		CorrectLabelsRequest	true	"Данные исправления меток"
+//	@Success	200				{object}	data.LabelCorrection
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/face_model/label_corrections [post]
+func (c *CoreHandler) CorrectLabels(w http.ResponseWriter, r *http.Request) error {
+	return c.correctLabels(w, r, data.FaceModel)
+}
+
+// CorrectModelLabels godoc
+//
+//	@Summary		Исправляет метки класса признаков видео модели
+//	@Description	Диапазон кадров можно задать только для типов моделей, в схеме которых есть признак frame_count
+//	@ID				correct model labels
+//	@Tags			Labels
+//	@Param			model_type		path		string					true	"Тип модели"
+//	@Param			correction_data	body		CorrectLabelsRequest	true	"Данные исправления меток"
+//	@Success		200				{object}	data.LabelCorrection
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		404				{object}	app_errors.AppError
+//	@Router			/{model_type}/label_corrections [post]
+func (c *CoreHandler) CorrectModelLabels(w http.ResponseWriter, r *http.Request) error {
+	return c.correctLabels(w, r, chi.URLParam(r, "model_type"))
+}
+
+func (c *CoreHandler) correctLabels(w http.ResponseWriter, r *http.Request, modelType string) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.correctLabels"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// десереализуем данные из тела запроса
+	var req CorrectLabelsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	if req.FrameFrom != nil && req.FrameTo != nil && *req.FrameFrom > *req.FrameTo {
+		return app_errors.ErrValidationError.WrapError(op, "frame_from is greater than frame_to")
+	}
+
+	// метки исправляются в таблице признаков схемы типа модели
+	schema, err := c.dataRepository.GetFeatureSchema(r.Context(), modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var correction *data.LabelCorrection
+	// делаем исправление меток в транзакции, чтобы изменение признаков и запись в журнал не разошлись
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		correction, err = c.dataRepository.CorrectLabels(txCtx, *schema, data.LabelCorrection{
+			VideoID:   req.VideoID,
+			UserID:    req.UserID,
+			FrameFrom: req.FrameFrom,
+			FrameTo:   req.FrameTo,
+			NewLabel:  *req.Label,
+			Reason:    req.Reason,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// сообщаем сервису работы с моделями об исправленных признаках, чтобы модель была переобучена
+		if correction.FeaturesCount > 0 {
+			err = c.sendRelabeledCount(req.UserID, schema.ModelType, correction.FeaturesCount)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// возвращаем запись журнала исправлений со статусом 200
+	api.WriteSuccess(r.Context(), w, correction, http.StatusOK, l)
+	return nil
+}
+
+// GetLabelCorrections godoc
+//
+//	@Summary	Возвращает журнал исправлений меток класса признаков лица пользователя
+//	@ID			get label corrections
+//	@Tags		Labels
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Param		video_id	query		string	false	"ID видео"
+//	@Success	200			{array}		data.LabelCorrection
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/face_model/label_corrections [get]
+func (c *CoreHandler) GetLabelCorrections(w http.ResponseWriter, r *http.Request) error {
+	return c.getLabelCorrections(w, r, data.FaceModel)
+}
+
+// GetModelLabelCorrections godoc
+//
+//	@Summary	Возвращает журнал исправлений меток класса признаков модели пользователя
+//	@ID			get model label corrections
+//	@Tags		Labels
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Param		video_id	query		string	false	"ID видео"
+//	@Success	200			{array}		data.LabelCorrection
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/{model_type}/label_corrections [get]
+func (c *CoreHandler) GetModelLabelCorrections(w http.ResponseWriter, r *http.Request) error {
+	return c.getLabelCorrections(w, r, chi.URLParam(r, "model_type"))
+}
+
+func (c *CoreHandler) getLabelCorrections(w http.ResponseWriter, r *http.Request, modelType string) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.getLabelCorrections"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// берем id пользователя из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+
+	var videoID *string
+	if videoIDString := r.URL.Query().Get("video_id"); videoIDString != "" {
+		videoID = &videoIDString
+	}
+
+	// находим исправления меток пользователя
+	corrections, err := c.dataRepository.GetLabelCorrections(r.Context(), modelType, userID, videoID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, corrections, http.StatusOK, l)
+	return nil
+}
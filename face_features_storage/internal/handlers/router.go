@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
@@ -12,18 +14,33 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"mime/multipart"
+	"io"
 	"net/http"
 	"strings"
 )
 
 type DataRepository interface {
-	SaveFaceVideoFeatures(ctx context.Context, file multipart.File) (uint64, error)
+	SaveFeatures(ctx context.Context, schema data.FeatureSchema, csvFile io.Reader, userID string) (uint64, error)
+	GetFeatureSchemas(ctx context.Context) ([]data.FeatureSchema, error)
+	GetFeatureSchema(ctx context.Context, modelType string) (*data.FeatureSchema, error)
+	ApplyFeatureSchema(ctx context.Context, modelType string, columns []data.SchemaColumn) (*data.FeatureSchema, error)
+	GetFeatures(ctx context.Context, schema data.FeatureSchema, filter data.FeaturesFilter, afterID int64, limit uint64) ([]data.Feature, error)
+	GetDatasetStats(ctx context.Context, schema data.FeatureSchema, userID string) (*data.DatasetStats, error)
+	OpenVideoSession(ctx context.Context, session data.VideoSession) (*data.VideoSession, error)
+	CloseVideoSession(ctx context.Context, videoID, userID string, frameCount *int) (*data.VideoSession, error)
+	GetVideoSessions(ctx context.Context, userID string, isOpen *bool) ([]data.VideoSession, error)
+	CorrectLabels(ctx context.Context, schema data.FeatureSchema, correction data.LabelCorrection) (*data.LabelCorrection, error)
+	GetLabelCorrections(ctx context.Context, modelType, userID string, videoID *string) ([]data.LabelCorrection, error)
+	SaveFatigueEvents(ctx context.Context, events []data.FatigueEvent) ([]data.FatigueEvent, error)
+	GetFatigueEvents(ctx context.Context, filter data.FatigueEventsFilter, afterID int64, limit uint64) ([]data.FatigueEvent, error)
+	GetFatigueEventsSummary(ctx context.Context, filter data.FatigueEventsFilter) ([]data.FatigueEventsSummary, error)
 }
 
 type CoreHandler struct {
 	dataRepository    DataRepository
 	storageHandlerURL string
+	// serviceToken - общий токен внутренних сервисов, без него недоступны выгрузки данных всех пользователей
+	serviceToken string
 
 	transactor postgresql.Transactor
 	validator  *validator.Validate
@@ -34,12 +51,14 @@ type CoreHandler struct {
 func NewCoreHandler(
 	dataRepository DataRepository,
 	storageHandlerURL string,
+	serviceToken string,
 	transactor postgresql.Transactor,
 	validator *validator.Validate,
 	logger *zap.Logger,
 ) *CoreHandler {
 	return &CoreHandler{
 		storageHandlerURL: storageHandlerURL,
+		serviceToken:      serviceToken,
 		validator:         validator,
 		dataRepository:    dataRepository,
 		transactor:        transactor,
@@ -88,6 +107,26 @@ func ErrorMiddleware(handler ErrorHandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ServiceTokenHeader - заголовок, в котором внутренние сервисы передают общий токен
+const ServiceTokenHeader = "X-Service-Token"
+
+// RequireServiceToken - пропускает только запросы внутренних сервисов с общим токеном в заголовке X-Service-Token
+func (c *CoreHandler) RequireServiceToken(handler ErrorHandlerFunc) ErrorHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		op := "handlers.CoreHandler.RequireServiceToken"
+
+		token := r.Header.Get(ServiceTokenHeader)
+		if token == "" {
+			return app_errors.ErrUnauthorized.WrapError(op, "empty service token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.serviceToken)) != 1 {
+			return app_errors.ErrWrongToken.WrapError(op, "wrong service token")
+		}
+
+		return handler(w, r)
+	}
+}
+
 func (c *CoreHandler) Router() chi.Router {
 	router := chi.NewRouter()
 
@@ -95,8 +134,49 @@ func (c *CoreHandler) Router() chi.Router {
 	router.Use(logger.WithLogger(c.logger))
 
 	router.Route("/api/v1", func(router chi.Router) {
+		router.Route("/{model_type}", func(router chi.Router) {
+			router.Post("/save_features", ErrorMiddleware(c.SaveFeatures))
+			router.Get("/features", ErrorMiddleware(c.RequireServiceToken(c.GetFeatures)))
+			router.Get("/stats", ErrorMiddleware(c.GetModelDatasetStats))
+
+			router.Route("/label_corrections", func(router chi.Router) {
+				router.Post("/", ErrorMiddleware(c.CorrectModelLabels))
+				router.Get("/", ErrorMiddleware(c.GetModelLabelCorrections))
+			})
+		})
+
+		// статические маршруты /schemas и /fatigue_events перекрывают /{model_type},
+		// поэтому такие типы моделей запрещены (reservedModelTypes)
+		router.Route("/schemas", func(router chi.Router) {
+			router.Get("/", ErrorMiddleware(c.GetFeatureSchemas))
+			router.Get("/{model_type}", ErrorMiddleware(c.GetFeatureSchema))
+			// изменение схемы выполняет DDL, поэтому доступно только внутренним сервисам
+			router.Put("/{model_type}", ErrorMiddleware(c.RequireServiceToken(c.ApplyFeatureSchema)))
+		})
+
+		router.Route("/fatigue_events", func(router chi.Router) {
+			// события принимаются только через user_data_service, который подставляет пользователя из токена доступа
+			router.Post("/", ErrorMiddleware(c.RequireServiceToken(c.SaveFatigueEvents)))
+			router.Get("/", ErrorMiddleware(c.GetFatigueEvents))
+			router.Get("/summary", ErrorMiddleware(c.GetFatigueEventsSummary))
+		})
+
+		// маршрут /face_model перекрывает /{model_type}, поэтому маршруты признаков лица объявлены в нем отдельно
 		router.Route("/face_model", func(router chi.Router) {
 			router.Post("/save_features", ErrorMiddleware(c.SaveVideoFeatures))
+			router.Get("/features", ErrorMiddleware(c.RequireServiceToken(c.GetVideoFeatures)))
+			router.Get("/stats", ErrorMiddleware(c.GetDatasetStats))
+
+			router.Route("/sessions", func(router chi.Router) {
+				router.Post("/", ErrorMiddleware(c.OpenVideoSession))
+				router.Get("/", ErrorMiddleware(c.GetVideoSessions))
+				router.Post("/{video_id}/close", ErrorMiddleware(c.CloseVideoSession))
+			})
+
+			router.Route("/label_corrections", func(router chi.Router) {
+				router.Post("/", ErrorMiddleware(c.CorrectLabels))
+				router.Get("/", ErrorMiddleware(c.GetLabelCorrections))
+			})
 		})
 	})
 
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	customTools "github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// reservedModelTypes - имена статических маршрутов /api/v1, которые перекрывают маршруты /{model_type}:
+// у типа модели с таким именем не были бы доступны загрузка признаков, статистика и исправление меток
+var reservedModelTypes = map[string]struct{}{
+	"schemas":        {},
+	"fatigue_events": {},
+}
+
+type ApplyFeatureSchemaRequest struct {
+	Columns []data.SchemaColumn `json:"columns" validate:"required,min=1,dive"`
+}
+
+// GetFeatureSchemas godoc
+//
+//	@Summary	Возвращает схемы признаков всех типов моделей
+//	@ID			get feature schemas
+//	@Tags		Schemas
+//	@Success	200	{array}		data.FeatureSchema
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/schemas [get]
+func (c *CoreHandler) GetFeatureSchemas(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetFeatureSchemas"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	schemas, err := c.dataRepository.GetFeatureSchemas(r.Context())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, schemas, http.StatusOK, l)
+	return nil
+}
+
+// GetFeatureSchema godoc
+//
+//	@Summary	Возвращает схему признаков типа модели
+//	@ID			get feature schema
+//	@Tags		Schemas
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Success	200			{object}	data.FeatureSchema
+//	@Failure	404			{object}	app_errors.AppError
+//	@Router		/schemas/{model_type} [get]
+func (c *CoreHandler) GetFeatureSchema(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetFeatureSchema"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	schema, err := c.dataRepository.GetFeatureSchema(r.Context(), chi.URLParam(r, "model_type"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, schema, http.StatusOK, l)
+	return nil
+}
+
+// ApplyFeatureSchema godoc
+//
+//	@Summary		Создает таблицу признаков для нового типа модели или добавляет новые признаки в существующую
+//	@Description	Вызывается справочником типов моделей model_handler_service при создании типа и изменении его схемы.
+//	@Description	Выполняет DDL, поэтому требует токен внутренних сервисов X-Service-Token
+//	@ID				apply feature schema
+//	@Tags			Schemas
+//	@Param			X-Service-Token	header		string						true	"Токен внутренних сервисов"
+//	@Param			model_type		path		string						true	"Тип модели"
+//	@Param			schema			body		ApplyFeatureSchemaRequest	true	"Признаки модели"
+//	@Success		200				{object}	data.FeatureSchema
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/schemas/{model_type} [put]
+func (c *CoreHandler) ApplyFeatureSchema(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.ApplyFeatureSchema"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	modelType := chi.URLParam(r, "model_type")
+	if _, ok := reservedModelTypes[modelType]; ok {
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("model_type %s is reserved", modelType))
+	}
+
+	// десереализуем данные из тела запроса
+	var req ApplyFeatureSchemaRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	var schema *data.FeatureSchema
+	// создание таблицы и запись схемы делаем в одной транзакции
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		schema, err = c.dataRepository.ApplyFeatureSchema(txCtx, modelType, req.Columns)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// возвращаем актуальную схему со статусом 200
+	api.WriteSuccess(r.Context(), w, schema, http.StatusOK, l)
+	return nil
+}
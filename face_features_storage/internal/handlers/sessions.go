@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	customTools "github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/tools"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+const (
+	SessionStatusOpen   = "open"
+	SessionStatusClosed = "closed"
+)
+
+type OpenVideoSessionRequest struct {
+	VideoID          string   `json:"video_id" validate:"required,uuid"`
+	UserID           string   `json:"user_id" validate:"required"`
+	FPS              *float64 `json:"fps" validate:"omitempty,gt=0"`
+	DeviceInfo       *string  `json:"device_info" validate:"omitempty,max=256"`
+	ClientAppVersion *string  `json:"client_app_version" validate:"omitempty,max=32"`
+}
+
+type CloseVideoSessionRequest struct {
+	UserID     string `json:"user_id" validate:"required"`
+	FrameCount *int   `json:"frame_count" validate:"omitempty,gte=0"`
+}
+
+// OpenVideoSession godoc
+//
+//	@Summary	Открывает сессию записи видео
+//	@ID			open video session
+//	@Tags		Sessions
+//	@Param		session_data	body		OpenVideoSessionRequest	true	"Данные сессии"
+//	@Success	201				{object}	data.VideoSession
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	409				{object}	app_errors.AppError
+//	@Router		/face_model/sessions [post]
+func (c *CoreHandler) OpenVideoSession(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.OpenVideoSession"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// десереализуем данные из тела запроса
+	var req OpenVideoSessionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// создаем новую сессию в БД
+	session, err := c.dataRepository.OpenVideoSession(r.Context(), data.VideoSession{
+		VideoID:          req.VideoID,
+		UserID:           req.UserID,
+		FPS:              req.FPS,
+		DeviceInfo:       req.DeviceInfo,
+		ClientAppVersion: req.ClientAppVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем созданную сессию со статусом 201
+	api.WriteSuccess(r.Context(), w, session, http.StatusCreated, l)
+	return nil
+}
+
+// CloseVideoSession godoc
+//
+//	@Summary	Закрывает сессию записи видео
+//	@ID			close video session
+//	@Tags		Sessions
+//	@Param		video_id		path		string						true	"ID видео"
+//	@Param		session_data	body		CloseVideoSessionRequest	true	"Данные закрытия сессии"
+//	@Success	200				{object}	data.VideoSession
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/face_model/sessions/{video_id}/close [post]
+func (c *CoreHandler) CloseVideoSession(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CloseVideoSession"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// десереализуем данные из тела запроса
+	var req CloseVideoSessionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// закрываем открытую сессию пользователя
+	session, err := c.dataRepository.CloseVideoSession(r.Context(), chi.URLParam(r, "video_id"), req.UserID, req.FrameCount)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем закрытую сессию со статусом 200
+	api.WriteSuccess(r.Context(), w, session, http.StatusOK, l)
+	return nil
+}
+
+// GetVideoSessions godoc
+//
+//	@Summary	Возвращает сессии записи видео пользователя
+//	@ID			get video sessions
+//	@Tags		Sessions
+//	@Param		user_id	query		string	true	"ID пользователя"
+//	@Param		status	query		string	false	"Статус сессии"	Enums(open, closed)
+//	@Success	200		{array}		data.VideoSession
+//	@Failure	400		{object}	app_errors.AppError
+//	@Router		/face_model/sessions [get]
+func (c *CoreHandler) GetVideoSessions(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetVideoSessions"
+	// берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// берем id пользователя из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+
+	// определяем фильтр по статусу сессии
+	var isOpen *bool
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case SessionStatusOpen:
+		isOpen = tools.PBool(true)
+	case SessionStatusClosed:
+		isOpen = tools.PBool(false)
+	default:
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("unknown status: %s", status))
+	}
+
+	// находим сессии пользователя
+	sessions, err := c.dataRepository.GetVideoSessions(r.Context(), userID, isOpen)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, sessions, http.StatusOK, l)
+	return nil
+}
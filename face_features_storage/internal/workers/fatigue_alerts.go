@@ -0,0 +1,208 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// fatigueAlertEventType - тип события вебхука, которым рассылаются события усталости
+	fatigueAlertEventType = "fatigue.alert"
+	// serviceTokenHeader - заголовок, в котором сервис работы с моделями ожидает токен внутренних сервисов
+	serviceTokenHeader = "X-Service-Token"
+	// maxResponseErrorLength - сколько байт тела неуспешного ответа выводить в лог
+	maxResponseErrorLength = 512
+)
+
+type FatigueAlertsRepository interface {
+	ClaimFatigueAlerts(ctx context.Context, limit int, lease time.Duration) ([]data.ClaimedFatigueAlert, error)
+	SetFatigueAlertsSent(ctx context.Context, eventIDs []int64) error
+	SetFatigueAlertsNextAttempt(ctx context.Context, eventIDs []int64, nextAttemptAt time.Time) error
+}
+
+type webhookEvent struct {
+	EventType string            `json:"event_type"`
+	UserID    string            `json:"user_id"`
+	Data      data.FatigueEvent `json:"data"`
+}
+
+type publishWebhookEventsRequest struct {
+	Events []webhookEvent `json:"events"`
+}
+
+// FatigueAlertsRetryPolicy - повторы отправки с экспоненциальной задержкой: BaseDelay, 2*BaseDelay, 4*BaseDelay...
+// но не больше MaxDelay. Предупреждения повторяются до успешной отправки
+type FatigueAlertsRetryPolicy struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Delay - задержка перед следующей попыткой после attempt неудачных попыток
+func (p FatigueAlertsRetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// FatigueAlerts - передает сохраненные события усталости в сервис работы с моделями для рассылки в вебхуки.
+// Несколько воркеров могут работать одновременно, события между ними делятся через SKIP LOCKED
+type FatigueAlerts struct {
+	fatigueAlertsRepository FatigueAlertsRepository
+	client                  *http.Client
+
+	webhookEventsURL string
+	serviceToken     string
+
+	retryPolicy  FatigueAlertsRetryPolicy
+	batchSize    int
+	pollInterval time.Duration
+	// lease - время, на которое события скрываются от других воркеров, должно превышать таймаут запроса
+	lease time.Duration
+
+	logger *zap.Logger
+}
+
+func NewFatigueAlerts(
+	fatigueAlertsRepository FatigueAlertsRepository,
+	webhookEventsURL string,
+	serviceToken string,
+	retryPolicy FatigueAlertsRetryPolicy,
+	timeout time.Duration,
+	batchSize int,
+	pollInterval time.Duration,
+	logger *zap.Logger,
+) *FatigueAlerts {
+	return &FatigueAlerts{
+		fatigueAlertsRepository: fatigueAlertsRepository,
+		client:                  &http.Client{Timeout: timeout},
+		webhookEventsURL:        webhookEventsURL,
+		serviceToken:            serviceToken,
+		retryPolicy:             retryPolicy,
+		batchSize:               batchSize,
+		pollInterval:            pollInterval,
+		lease:                   2 * timeout,
+		logger:                  logger,
+	}
+}
+
+// Run - отправляет предупреждения до отмены контекста
+func (f FatigueAlerts) Run(ctx context.Context) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.FatigueAlerts.Run"
+
+	// Кладем логгер в контекст
+	ctx = logger.ContextWithLogger(ctx, f.logger)
+
+	for {
+		alerts, err := f.fatigueAlertsRepository.ClaimFatigueAlerts(ctx, f.batchSize, f.lease)
+		if err != nil {
+			f.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+
+		if len(alerts) > 0 {
+			f.deliver(ctx, alerts)
+		}
+
+		// Полный пакет означает, что в очереди могут остаться события, поэтому забираем следующий без ожидания
+		wait := f.pollInterval
+		if err == nil && len(alerts) == f.batchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliver - отправляет пакет предупреждений и записывает результат отправки
+func (f FatigueAlerts) deliver(ctx context.Context, alerts []data.ClaimedFatigueAlert) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.FatigueAlerts.deliver"
+
+	eventIDs := make([]int64, 0, len(alerts))
+	attempt := 0
+	for _, alert := range alerts {
+		eventIDs = append(eventIDs, alert.EventID)
+		attempt = max(attempt, alert.AlertAttempts)
+	}
+
+	l := f.logger.With(zap.Int("count", len(alerts)), zap.Int("attempt", attempt))
+
+	// Результат записываем и при остановке воркера, иначе отправленные предупреждения будут отправлены повторно
+	resultCtx := context.WithoutCancel(ctx)
+
+	err := f.send(ctx, alerts)
+	if err != nil {
+		nextAttemptAt := time.Now().Add(f.retryPolicy.Delay(attempt))
+		l.Warn(fmt.Sprintf("%s: alerts will be retried at %s: %s", op, nextAttemptAt.Format(time.RFC3339), err.Error()))
+
+		err = f.fatigueAlertsRepository.SetFatigueAlertsNextAttempt(resultCtx, eventIDs, nextAttemptAt)
+		if err != nil {
+			l.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+		return
+	}
+
+	err = f.fatigueAlertsRepository.SetFatigueAlertsSent(resultCtx, eventIDs)
+	if err != nil {
+		l.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		return
+	}
+
+	l.Info(fmt.Sprintf("%s: alerts sent", op))
+}
+
+// send - отправляет события усталости одним пакетом в сервис работы с моделями
+func (f FatigueAlerts) send(ctx context.Context, alerts []data.ClaimedFatigueAlert) error {
+	req := publishWebhookEventsRequest{
+		Events: make([]webhookEvent, 0, len(alerts)),
+	}
+	for _, alert := range alerts {
+		req.Events = append(req.Events, webhookEvent{
+			EventType: fatigueAlertEventType,
+			UserID:    alert.UserID,
+			Data:      alert.FatigueEvent,
+		})
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, f.webhookEventsURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(serviceTokenHeader, f.serviceToken)
+
+	resp, err := f.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseErrorLength))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/pkg/postgresql"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type PartitionRepository interface {
+	GetFeatureSchemas(ctx context.Context) ([]data.FeatureSchema, error)
+	GetFeaturesPartitions(ctx context.Context, table string) ([]data.FeaturesPartition, error)
+	DropFeaturesPartition(ctx context.Context, partition data.FeaturesPartition) error
+	CountPartitionUsersFeatures(ctx context.Context, partition data.FeaturesPartition, userIDs []string) (uint64, error)
+	DeletePartitionFeaturesExceptUsers(ctx context.Context, partition data.FeaturesPartition, userIDs []string) (int64, error)
+}
+
+type Retention struct {
+	partitionRepository PartitionRepository
+	transactor          postgresql.Transactor
+
+	maxAgeMonths       int
+	skipUntrainedUsers bool
+	untrainedUsersURL  string
+
+	logger *zap.Logger
+}
+
+func NewRetention(
+	partitionRepository PartitionRepository,
+	transactor postgresql.Transactor,
+	maxAgeMonths int,
+	skipUntrainedUsers bool,
+	untrainedUsersURL string,
+	logger *zap.Logger,
+) *Retention {
+	return &Retention{
+		partitionRepository: partitionRepository,
+		transactor:          transactor,
+		maxAgeMonths:        maxAgeMonths,
+		skipUntrainedUsers:  skipUntrainedUsers,
+		untrainedUsersURL:   untrainedUsersURL,
+		logger:              logger,
+	}
+}
+
+// Run - удаляет секции признаков всех схем старше maxAgeMonths месяцев. Если секция содержит признаки
+// пользователей с необученными моделями, то из нее удаляются только признаки остальных пользователей
+func (r Retention) Run(ctx context.Context) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.Retention.Run"
+
+	// Кладем логгер в контекст
+	ctx = logger.ContextWithLogger(ctx, r.logger)
+
+	// Секции, верхняя граница которых не позже данной даты, считаются устаревшими
+	cutoff := data.MonthStart(time.Now()).AddDate(0, -r.maxAgeMonths, 0)
+
+	schemas, err := r.partitionRepository.GetFeatureSchemas(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, schema := range schemas {
+		err = r.cleanSchema(ctx, schema, cutoff)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	r.logger.With(zap.Time("cutoff", cutoff)).Info(fmt.Sprintf("%s: retention finished", op))
+
+	return nil
+}
+
+// cleanSchema - удаляет устаревшие секции таблицы признаков схемы
+func (r Retention) cleanSchema(ctx context.Context, schema data.FeatureSchema, cutoff time.Time) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.Retention.cleanSchema"
+
+	partitions, err := r.partitionRepository.GetFeaturesPartitions(ctx, schema.TableName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	outdated := make([]data.FeaturesPartition, 0, len(partitions))
+	for _, partition := range partitions {
+		if !partition.End().After(cutoff) {
+			outdated = append(outdated, partition)
+		}
+	}
+	if len(outdated) == 0 {
+		return nil
+	}
+
+	// Модели обучаются по типам, поэтому необученные пользователи запрашиваются для типа модели схемы
+	var untrainedUsers []string
+	if r.skipUntrainedUsers {
+		untrainedUsers, err = r.getUntrainedUsers(schema.ModelType)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	for _, partition := range outdated {
+		// Каждую секцию обрабатываем в отдельной транзакции
+		txErr := r.transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+			count, err := r.partitionRepository.CountPartitionUsersFeatures(txCtx, partition, untrainedUsers)
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				return r.partitionRepository.DropFeaturesPartition(txCtx, partition)
+			}
+
+			_, err = r.partitionRepository.DeletePartitionFeaturesExceptUsers(txCtx, partition, untrainedUsers)
+			return err
+		})
+		if txErr != nil {
+			return fmt.Errorf("%s: %w", op, txErr)
+		}
+	}
+
+	r.logger.With(zap.String("model_type", schema.ModelType), zap.Int("partitions", len(outdated))).
+		Info(fmt.Sprintf("%s: clean outdated partitions", op))
+
+	return nil
+}
+
+// getUntrainedUsers - запрашивает у сервиса работы с моделями пользователей с необученными моделями типа modelType
+func (r Retention) getUntrainedUsers(modelType string) ([]string, error) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.Retention.getUntrainedUsers"
+
+	u, err := url.Parse(r.untrainedUsersURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	query := u.Query()
+	query.Set("model_type", modelType)
+	u.RawQuery = query.Encode()
+
+	// Отправляем запрос
+	client := &http.Client{}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// Закрываем тело ответа при выходе из функции
+	defer resp.Body.Close()
+
+	// В случае ошибочного статуса ответа возвращаем ошибку
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: error from model_handler service", op)
+	}
+
+	var result struct {
+		Content []string `json:"content"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result.Content, nil
+}
@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/face_features_storage/internal/domains/data"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+// fakePartitionRepository - секции таблицы признаков в памяти. untrainedFeatures - количество признаков
+// пользователей с необученными моделями в секции
+type fakePartitionRepository struct {
+	partitions        []data.FeaturesPartition
+	untrainedFeatures map[string]uint64
+
+	dropped []string
+	deleted map[string][]string
+}
+
+func (r *fakePartitionRepository) GetFeatureSchemas(context.Context) ([]data.FeatureSchema, error) {
+	return []data.FeatureSchema{{ModelType: data.FaceModel, TableName: data.FeaturesTable}}, nil
+}
+
+func (r *fakePartitionRepository) GetFeaturesPartitions(context.Context, string) ([]data.FeaturesPartition, error) {
+	return r.partitions, nil
+}
+
+func (r *fakePartitionRepository) DropFeaturesPartition(_ context.Context, partition data.FeaturesPartition) error {
+	r.dropped = append(r.dropped, partition.Name)
+	return nil
+}
+
+func (r *fakePartitionRepository) CountPartitionUsersFeatures(_ context.Context, partition data.FeaturesPartition, userIDs []string) (uint64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	return r.untrainedFeatures[partition.Name], nil
+}
+
+func (r *fakePartitionRepository) DeletePartitionFeaturesExceptUsers(_ context.Context, partition data.FeaturesPartition, userIDs []string) (int64, error) {
+	r.deleted[partition.Name] = userIDs
+	return 1, nil
+}
+
+func newFakePartitionRepository(now time.Time) *fakePartitionRepository {
+	r := &fakePartitionRepository{
+		untrainedFeatures: make(map[string]uint64),
+		deleted:           make(map[string][]string),
+	}
+	for _, monthsAgo := range []int{14, 13, 1, 0} {
+		month := data.MonthStart(now).AddDate(0, -monthsAgo, 0)
+		r.partitions = append(r.partitions, data.FeaturesPartition{
+			Name:  data.FeaturesPartitionName(data.FeaturesTable, month),
+			Month: month,
+		})
+	}
+	return r
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	oldest := data.FeaturesPartitionName(data.FeaturesTable, data.MonthStart(now).AddDate(0, -14, 0))
+	old := data.FeaturesPartitionName(data.FeaturesTable, data.MonthStart(now).AddDate(0, -13, 0))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("model_type") != data.FaceModel {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"content":["untrained"]}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name               string
+		skipUntrainedUsers bool
+		wantDropped        []string
+		wantDeleted        []string
+	}{
+		{
+			// в самой старой секции признаков необученных пользователей нет, в следующей есть
+			name:               "keep features of untrained users",
+			skipUntrainedUsers: true,
+			wantDropped:        []string{oldest},
+			wantDeleted:        []string{old},
+		},
+		{
+			name:               "drop all outdated partitions",
+			skipUntrainedUsers: false,
+			wantDropped:        []string{oldest, old},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakePartitionRepository(now)
+			repo.untrainedFeatures[old] = 10
+
+			retention := NewRetention(repo, fakeTransactor{}, 12, tt.skipUntrainedUsers, server.URL, zap.NewNop())
+			err := retention.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if fmt.Sprint(repo.dropped) != fmt.Sprint(tt.wantDropped) {
+				t.Errorf("dropped = %v, want %v", repo.dropped, tt.wantDropped)
+			}
+			if len(repo.deleted) != len(tt.wantDeleted) {
+				t.Fatalf("deleted from = %v, want %v", repo.deleted, tt.wantDeleted)
+			}
+			for _, name := range tt.wantDeleted {
+				// из секции удаляются признаки всех, кроме необученных пользователей
+				if users := repo.deleted[name]; fmt.Sprint(users) != "[untrained]" {
+					t.Errorf("partition %s kept users %v, want [untrained]", name, users)
+				}
+			}
+		})
+	}
+}
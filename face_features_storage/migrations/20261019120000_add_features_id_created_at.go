@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddFeaturesIDCreatedAt, downAddFeaturesIDCreatedAt)
+}
+
+func upAddFeaturesIDCreatedAt(ctx context.Context, tx *sql.Tx) error {
+	// feature_id используется как курсор для keyset пагинации,
+	// created_at - для фильтрации признаков по времени загрузки
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE video_features
+	    ADD COLUMN feature_id BIGINT GENERATED ALWAYS AS IDENTITY,
+	    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddFeaturesIDCreatedAt(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE video_features
+	    DROP COLUMN feature_id,
+	    DROP COLUMN created_at;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
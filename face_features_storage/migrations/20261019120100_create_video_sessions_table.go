@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateVideoSessionsTable, downCreateVideoSessionsTable)
+}
+
+func upCreateVideoSessionsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE video_sessions
+	(
+	    video_id CHAR(36) PRIMARY KEY,
+	    user_id CHAR(36) NOT NULL,
+
+	    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    ended_at TIMESTAMPTZ,
+
+	    fps DOUBLE PRECISION,
+	    device_info VARCHAR(256),
+	    client_app_version VARCHAR(32),
+	    frame_count INT NOT NULL DEFAULT(0)
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX video_sessions_user_id_idx ON video_sessions (user_id, started_at);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateVideoSessionsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE video_sessions;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upPartitionVideoFeatures, downPartitionVideoFeatures)
+}
+
+const videoFeaturesColumns = `video_id, frame_count, eye, mouth, perimeter_eye, perimeter_mouth,
+	    x_angle, y_angle, label, user_id, feature_id, created_at`
+
+func upPartitionVideoFeatures(ctx context.Context, tx *sql.Tx) error {
+	// старая таблица переименовывается вместе с sequence identity колонки,
+	// чтобы освободить имена для секционированной таблицы
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE video_features RENAME TO video_features_legacy;
+	ALTER SEQUENCE video_features_feature_id_seq RENAME TO video_features_legacy_feature_id_seq;`)
+	if err != nil {
+		return err
+	}
+
+	// identity колонки не поддерживаются секционированными таблицами, поэтому используется bigserial
+	_, err = tx.ExecContext(ctx, `
+	CREATE TABLE video_features
+	(
+	    video_id CHAR(36),
+
+	    frame_count INT,
+	    eye DOUBLE PRECISION,
+	    mouth DOUBLE PRECISION,
+	    perimeter_eye DOUBLE PRECISION,
+	    perimeter_mouth DOUBLE PRECISION,
+	    x_angle DOUBLE PRECISION,
+	    y_angle DOUBLE PRECISION,
+
+	    label INT,
+	    user_id CHAR(36),
+
+	    feature_id BIGSERIAL,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	) PARTITION BY RANGE (created_at);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE INDEX video_features_user_id_video_id_idx ON video_features (user_id, video_id);
+	CREATE INDEX video_features_feature_id_idx ON video_features (feature_id);`)
+	if err != nil {
+		return err
+	}
+
+	// создаем месячные секции для всех существующих данных и текущего месяца
+	_, err = tx.ExecContext(ctx, `
+	DO $$
+	DECLARE
+	    month_start TIMESTAMP;
+	BEGIN
+	    FOR month_start IN
+	        SELECT date_trunc('month', created_at AT TIME ZONE 'UTC') FROM video_features_legacy
+	        UNION
+	        SELECT date_trunc('month', now() AT TIME ZONE 'UTC')
+	    LOOP
+	        EXECUTE format(
+	            'CREATE TABLE %I PARTITION OF video_features FOR VALUES FROM (%L) TO (%L)',
+	            'video_features_y' || to_char(month_start, 'YYYY') || 'm' || to_char(month_start, 'MM'),
+	            month_start AT TIME ZONE 'UTC',
+	            (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC'
+	        );
+	    END LOOP;
+	END $$;`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO video_features (`+videoFeaturesColumns+`)
+	SELECT `+videoFeaturesColumns+` FROM video_features_legacy;
+
+	SELECT setval('video_features_feature_id_seq', COALESCE((SELECT max(feature_id) FROM video_features), 0) + 1, false);
+
+	DROP TABLE video_features_legacy;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downPartitionVideoFeatures(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE video_features RENAME TO video_features_partitioned;
+	ALTER SEQUENCE video_features_feature_id_seq RENAME TO video_features_partitioned_feature_id_seq;`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE TABLE video_features
+	(
+	    video_id CHAR(36),
+
+	    frame_count INT,
+	    eye DOUBLE PRECISION,
+	    mouth DOUBLE PRECISION,
+	    perimeter_eye DOUBLE PRECISION,
+	    perimeter_mouth DOUBLE PRECISION,
+	    x_angle DOUBLE PRECISION,
+	    y_angle DOUBLE PRECISION,
+
+	    label INT,
+	    user_id CHAR(36),
+
+	    feature_id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO video_features (`+videoFeaturesColumns+`)
+	SELECT `+videoFeaturesColumns+` FROM video_features_partitioned;
+
+	SELECT setval(pg_get_serial_sequence('video_features', 'feature_id'),
+	    COALESCE((SELECT max(feature_id) FROM video_features), 0) + 1, false);
+
+	ALTER TABLE video_features ALTER COLUMN feature_id SET GENERATED ALWAYS;
+
+	DROP TABLE video_features_partitioned;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
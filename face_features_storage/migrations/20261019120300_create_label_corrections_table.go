@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateLabelCorrectionsTable, downCreateLabelCorrectionsTable)
+}
+
+func upCreateLabelCorrectionsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE label_corrections
+	(
+	    correction_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	    video_id CHAR(36) NOT NULL,
+	    user_id CHAR(36) NOT NULL,
+
+	    frame_from INT,
+	    frame_to INT,
+	    old_labels INT[] NOT NULL DEFAULT('{}'),
+	    new_label INT NOT NULL,
+	    features_count INT NOT NULL DEFAULT(0),
+	    reason VARCHAR(256),
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX label_corrections_user_id_video_id_idx ON label_corrections (user_id, video_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateLabelCorrectionsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE label_corrections;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
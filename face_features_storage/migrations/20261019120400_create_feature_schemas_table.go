@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateFeatureSchemasTable, downCreateFeatureSchemasTable)
+}
+
+func upCreateFeatureSchemasTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE feature_schemas
+	(
+	    model_type VARCHAR(64) PRIMARY KEY,
+	    table_name VARCHAR(63) NOT NULL UNIQUE,
+	    columns JSONB NOT NULL,
+	    version INT NOT NULL DEFAULT(1),
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// схема признаков лица описывает уже существующую таблицу video_features
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO feature_schemas (model_type, table_name, columns) VALUES (
+	    'face_model',
+	    'video_features',
+	    '[
+	        {"name": "frame_count", "type": "int"},
+	        {"name": "eye", "type": "float"},
+	        {"name": "mouth", "type": "float"},
+	        {"name": "perimeter_eye", "type": "float"},
+	        {"name": "perimeter_mouth", "type": "float"},
+	        {"name": "x_angle", "type": "float"},
+	        {"name": "y_angle", "type": "float"}
+	    ]'
+	);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateFeatureSchemasTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE feature_schemas;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
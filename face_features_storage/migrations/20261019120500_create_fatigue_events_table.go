@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateFatigueEventsTable, downCreateFatigueEventsTable)
+}
+
+func upCreateFatigueEventsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE fatigue_events
+	(
+	    event_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	    user_id CHAR(36) NOT NULL,
+	    session_id VARCHAR(64) NOT NULL,
+
+	    model_type VARCHAR(64) NOT NULL,
+	    model_version VARCHAR(512) NOT NULL,
+	    confidence DOUBLE PRECISION NOT NULL CHECK (confidence >= 0 AND confidence <= 1),
+
+	    occurred_at TIMESTAMPTZ NOT NULL,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX fatigue_events_user_id_occurred_at_idx ON fatigue_events (user_id, occurred_at);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX fatigue_events_session_id_idx ON fatigue_events (session_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateFatigueEventsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE fatigue_events;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
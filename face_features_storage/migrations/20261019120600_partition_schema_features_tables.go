@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upPartitionSchemaFeaturesTables, downPartitionSchemaFeaturesTables)
+}
+
+// upPartitionSchemaFeaturesTables - таблицы признаков, созданные по схемам до секционирования, переводятся
+// на месячные секции, как и video_features, чтобы на них распространялось удаление устаревших признаков.
+// Sequence feature_id переходит к новой таблице, поэтому нумерация признаков продолжается
+func upPartitionSchemaFeaturesTables(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	DO $$
+	DECLARE
+	    schema_table TEXT;
+	    legacy_table TEXT;
+	    month_start TIMESTAMP;
+	BEGIN
+	    FOR schema_table IN
+	        SELECT feature_schemas.table_name FROM feature_schemas
+	        JOIN pg_class ON pg_class.relname = feature_schemas.table_name AND pg_class.relkind = 'r'
+	    LOOP
+	        -- имя секции длиннее 63 символов Postgres обрежет, и секции разных месяцев совпадут
+	        IF length(schema_table) > 54 THEN
+	            RAISE EXCEPTION 'features table name % is too long for partitioning', schema_table;
+	        END IF;
+
+	        legacy_table := schema_table || '_legacy';
+	        EXECUTE format('ALTER TABLE %I RENAME TO %I', schema_table, legacy_table);
+	        EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)',
+	            schema_table, legacy_table);
+
+	        -- создаем месячные секции для всех существующих данных и текущего месяца
+	        FOR month_start IN EXECUTE format(
+	            'SELECT date_trunc(''month'', created_at AT TIME ZONE ''UTC'') FROM %I
+	             UNION
+	             SELECT date_trunc(''month'', now() AT TIME ZONE ''UTC'')', legacy_table)
+	        LOOP
+	            EXECUTE format(
+	                'CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+	                schema_table || '_y' || to_char(month_start, 'YYYY') || 'm' || to_char(month_start, 'MM'),
+	                schema_table,
+	                month_start AT TIME ZONE 'UTC',
+	                (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC'
+	            );
+	        END LOOP;
+
+	        EXECUTE format('INSERT INTO %I SELECT * FROM %I', schema_table, legacy_table);
+	        EXECUTE format('ALTER SEQUENCE %s OWNED BY %I.feature_id',
+	            pg_get_serial_sequence(legacy_table, 'feature_id'), schema_table);
+	        EXECUTE format('DROP TABLE %I', legacy_table);
+
+	        EXECUTE format('CREATE INDEX %I ON %I (user_id, video_id)', schema_table || '_user_id_video_id_idx', schema_table);
+	        EXECUTE format('CREATE INDEX %I ON %I (feature_id)', schema_table || '_feature_id_idx', schema_table);
+	    END LOOP;
+	END $$;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downPartitionSchemaFeaturesTables(ctx context.Context, tx *sql.Tx) error {
+	// video_features секционирована отдельной миграцией и остается секционированной
+	_, err := tx.ExecContext(ctx, `
+	DO $$
+	DECLARE
+	    schema_table TEXT;
+	    partitioned_table TEXT;
+	BEGIN
+	    FOR schema_table IN
+	        SELECT feature_schemas.table_name FROM feature_schemas
+	        JOIN pg_class ON pg_class.relname = feature_schemas.table_name AND pg_class.relkind = 'p'
+	        WHERE feature_schemas.table_name <> 'video_features'
+	    LOOP
+	        partitioned_table := schema_table || '_partitioned';
+	        EXECUTE format('ALTER TABLE %I RENAME TO %I', schema_table, partitioned_table);
+	        EXECUTE format('ALTER INDEX %I RENAME TO %I',
+	            schema_table || '_user_id_video_id_idx', partitioned_table || '_user_id_video_id_idx');
+	        EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS)', schema_table, partitioned_table);
+
+	        EXECUTE format('INSERT INTO %I SELECT * FROM %I', schema_table, partitioned_table);
+	        EXECUTE format('ALTER SEQUENCE %s OWNED BY %I.feature_id',
+	            pg_get_serial_sequence(partitioned_table, 'feature_id'), schema_table);
+	        EXECUTE format('DROP TABLE %I', partitioned_table);
+
+	        EXECUTE format('ALTER TABLE %I ADD PRIMARY KEY (feature_id)', schema_table);
+	        EXECUTE format('CREATE INDEX %I ON %I (user_id, video_id)', schema_table || '_user_id_video_id_idx', schema_table);
+	    END LOOP;
+	END $$;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddLabelCorrectionsModelType, downAddLabelCorrectionsModelType)
+}
+
+// upAddLabelCorrectionsModelType - метки исправляются в таблице признаков любого типа модели,
+// поэтому в журнале исправлений хранится тип модели. Прежние исправления относились к признакам лица
+func upAddLabelCorrectionsModelType(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE label_corrections ADD COLUMN model_type VARCHAR(64) NOT NULL DEFAULT('face_model');
+	ALTER TABLE label_corrections ALTER COLUMN model_type DROP DEFAULT;
+
+	DROP INDEX label_corrections_user_id_video_id_idx;
+	CREATE INDEX label_corrections_model_type_user_id_video_id_idx ON label_corrections (model_type, user_id, video_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddLabelCorrectionsModelType(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	DROP INDEX label_corrections_model_type_user_id_video_id_idx;
+	DELETE FROM label_corrections WHERE model_type <> 'face_model';
+	ALTER TABLE label_corrections DROP COLUMN model_type;
+
+	CREATE INDEX label_corrections_user_id_video_id_idx ON label_corrections (user_id, video_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddFatigueEventsAlertOutbox, downAddFatigueEventsAlertOutbox)
+}
+
+// upAddFatigueEventsAlertOutbox - таблица событий усталости служит очередью предупреждений для вебхуков:
+// событие с пустым alert_sent_at еще не передано в сервис работы с моделями. Прежние события
+// отправлялись при сохранении, поэтому считаются отправленными
+func upAddFatigueEventsAlertOutbox(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE fatigue_events
+	    ADD COLUMN alert_sent_at TIMESTAMPTZ,
+	    ADD COLUMN alert_attempts INT NOT NULL DEFAULT 0,
+	    ADD COLUMN alert_next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+	UPDATE fatigue_events SET alert_sent_at = created_at;
+
+	CREATE INDEX fatigue_events_pending_alerts_idx ON fatigue_events (alert_next_attempt_at)
+	    WHERE alert_sent_at IS NULL;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddFatigueEventsAlertOutbox(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	DROP INDEX fatigue_events_pending_alerts_idx;
+
+	ALTER TABLE fatigue_events
+	    DROP COLUMN alert_sent_at,
+	    DROP COLUMN alert_attempts,
+	    DROP COLUMN alert_next_attempt_at;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
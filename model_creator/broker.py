@@ -1,16 +1,186 @@
 import pika
+import psycopg2
 import json
-from model_creator import create_xgb
+import time
+import requests
+from model_creator import create_xgb, evaluate_xgb
 import logging
 
+ATTEMPT_HEADER = 'x-attempt'
 
+RESULT_STATUS_SUCCESS = 'success'
+RESULT_STATUS_FAILURE = 'failure'
+
+# задача обучения глобальной модели на признаках всех пользователей
+JOB_SCOPE_GLOBAL = 'global'
+
+# задача сравнения двух версий модели на отложенных видео
+JOB_TYPE_EVALUATE = 'evaluate'
+# задача первого обучения персональной модели, ссылка на модель в ней задается только для теплого старта
+JOB_TYPE_TRAIN = 'train'
+
+DEAD_LETTER_EXCHANGE_SUFFIX = '.dlx'
+DEAD_LETTER_QUEUE_SUFFIX = '.dead'
+RETRY_QUEUE_SUFFIX = '.retry'
+
+# CLAIM_QUERY - забирает доступное сообщение очереди, пропуская строки, заблокированные другими потребителями.
+# Запрос должен совпадать с используемым model_handler_service (pkg/broker/postgres_broker.go)
+CLAIM_QUERY = """
+UPDATE broker_messages SET locked_until = now() + make_interval(secs => %s)
+WHERE message_id IN (
+    SELECT message_id FROM broker_messages
+    WHERE queue = %s AND NOT dead AND available_at <= now()
+      AND (locked_until IS NULL OR locked_until < now())
+    ORDER BY priority DESC, message_id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING message_id, body, attempt"""
+
+
+# Broker - обработка задач тренера, не зависящая от транспорта сообщений
 class Broker:
-    def __init__(self, model_storage_url, queue_name, repository, rabbitmq_host='localhost', rabbitmq_port=5672,
-                 rabbitmq_user='user',
-                 rabbitmq_pass='password'):
+    def __init__(self, model_storage_url, queue_name, repository, max_attempts=3, results_queue='model_results',
+                 model_uploads_url=None):
         self.queue_name = queue_name
+        self.results_queue = results_queue
+        self.max_attempts = max_attempts
         self.repository = repository
         self.model_storage_url = model_storage_url
+        self.model_uploads_url = model_uploads_url
+
+    def connect(self):
+        raise NotImplementedError
+
+    def publish_result(self, result):
+        raise NotImplementedError
+
+    def start_consuming(self):
+        raise NotImplementedError
+
+    def close_connection(self):
+        raise NotImplementedError
+
+    def handle(self, delivery):
+        msg = {}
+        try:
+            msg = json.loads(delivery.body)
+
+            user_id = msg['user_id']
+            model_type = msg['model_type']
+
+            if msg.get('type') == JOB_TYPE_EVALUATE:
+                self.evaluate(delivery, msg)
+                return
+
+            if msg.get('scope') == JOB_SCOPE_GLOBAL:
+                df = self.repository.get_all_features(model_type)
+            else:
+                df = self.repository.get_features_by_user_id(model_type, user_id)
+
+            # отложенные для сравнения версий видео не участвуют в обучении, иначе версия оценивалась бы
+            # на собственных обучающих данных
+            exclude_video_ids = set(msg.get('exclude_video_ids') or [])
+            if exclude_video_ids:
+                df = df[~df['video_id'].isin(exclude_video_ids)]
+
+            features_count = len(df)
+
+            logging.info(f"Get {features_count} records with user_id: {user_id}")
+            # с модели по ссылке продолжается только первое обучение с теплым стартом. Задачи дообучения
+            # тоже содержат ссылку на модель, но переобучаются с нуля на всех признаках пользователя,
+            # иначе каждое дообучение добавляло бы к модели новые деревья
+            base_model_url = None
+            if msg.get('type') == JOB_TYPE_TRAIN:
+                base_model_url = msg.get('model_url')
+
+            s3_key, metrics = create_xgb(df, self.model_storage_url, user_id, model_type, features_count,
+                                         base_model_url=base_model_url,
+                                         uploads_url=self.model_uploads_url)
+            logging.info(f"Create xgb model for user_id: {user_id}")
+
+            self.publish_result({
+                'job_id': msg.get('job_id'),
+                'user_id': user_id,
+                'model_type': model_type,
+                'status': RESULT_STATUS_SUCCESS,
+                's3_key': s3_key,
+                'features_count': features_count,
+                'metrics': metrics,
+            })
+            delivery.ack()
+        except Exception as e:
+            logging.error(f"Error processing message: {e}")
+
+            # сетевые ошибки временные, ошибки в данных или обучении повторять бессмысленно
+            attempt = delivery.attempt + 1
+            retryable = isinstance(e, requests.RequestException) and attempt < self.max_attempts
+
+            if msg.get('job_id'):
+                self.publish_result({
+                    'job_id': msg.get('job_id'),
+                    'user_id': msg.get('user_id'),
+                    'model_type': msg.get('model_type'),
+                    'status': RESULT_STATUS_FAILURE,
+                    'error': str(e),
+                    'retryable': retryable,
+                })
+
+            if retryable:
+                delivery.retry()
+            else:
+                delivery.nack()
+
+    def evaluate(self, delivery, msg):
+        user_id = msg['user_id']
+        video_ids = msg['video_ids']
+
+        df = self.repository.get_features_by_video_ids(msg['model_type'], user_id, video_ids)
+        logging.info(f"Get {len(df)} held-out records with user_id: {user_id}")
+
+        metrics = evaluate_xgb(df, msg['baseline_model_url'], msg['candidate_model_url'])
+        logging.info(f"Evaluate models for user_id: {user_id}")
+
+        self.publish_result({
+            'job_id': msg.get('job_id'),
+            'user_id': user_id,
+            'model_type': msg['model_type'],
+            'status': RESULT_STATUS_SUCCESS,
+            'metrics': metrics,
+        })
+        delivery.ack()
+
+
+class RabbitMQDelivery:
+    def __init__(self, broker, ch, method, properties, body):
+        self.broker = broker
+        self.ch = ch
+        self.method = method
+        self.properties = properties
+        self.body = body
+        self.attempt = int((properties.headers or {}).get(ATTEMPT_HEADER, 0))
+
+    def ack(self):
+        self.ch.basic_ack(delivery_tag=self.method.delivery_tag)
+
+    def nack(self):
+        # отклоненное сообщение попадает в очередь недоставленных
+        self.ch.basic_nack(delivery_tag=self.method.delivery_tag, requeue=False)
+
+    def retry(self):
+        self.broker.retry(self.ch, self.method, self.properties, self.body)
+
+
+class RabbitMQBroker(Broker):
+    def __init__(self, model_storage_url, queue_name, repository, rabbitmq_host='localhost', rabbitmq_port=5672,
+                 rabbitmq_user='user',
+                 rabbitmq_pass='password', rabbitmq_durable=True, retry_delay_ms=30000, max_attempts=3,
+                 max_priority=10, results_queue='model_results', model_uploads_url=None):
+        super().__init__(model_storage_url, queue_name, repository, max_attempts=max_attempts,
+                         results_queue=results_queue, model_uploads_url=model_uploads_url)
+        self.rabbitmq_durable = rabbitmq_durable
+        self.retry_delay_ms = retry_delay_ms
+        self.max_priority = max_priority
         self.rabbitmq_host = rabbitmq_host
         self.rabbitmq_port = rabbitmq_port
         self.rabbitmq_user = rabbitmq_user
@@ -21,33 +191,139 @@ class Broker:
         parameters = pika.ConnectionParameters(self.rabbitmq_host, self.rabbitmq_port, '/', credentials)
         self.connection = pika.BlockingConnection(parameters)
         self.channel = self.connection.channel()
-        self.channel.queue_declare(queue=self.queue_name)
+        self.declare_topology(self.queue_name)
+        self.declare_topology(self.results_queue)
+
+    def declare_topology(self, queue):
+        # топология должна совпадать с объявляемой model_handler_service (pkg/rabbitmq),
+        # иначе брокер отклонит повторное объявление очередей
+        dlx = queue + DEAD_LETTER_EXCHANGE_SUFFIX
+        dead_queue = queue + DEAD_LETTER_QUEUE_SUFFIX
+        retry_queue = queue + RETRY_QUEUE_SUFFIX
+
+        self.channel.exchange_declare(exchange=dlx, exchange_type='direct', durable=self.rabbitmq_durable)
+        self.channel.queue_declare(queue=dead_queue, durable=self.rabbitmq_durable)
+        self.channel.queue_bind(queue=dead_queue, exchange=dlx, routing_key=queue)
+        self.channel.queue_declare(queue=retry_queue, durable=self.rabbitmq_durable, arguments={
+            'x-message-ttl': self.retry_delay_ms,
+            'x-dead-letter-exchange': '',
+            'x-dead-letter-routing-key': queue,
+        })
+        arguments = {'x-dead-letter-exchange': dlx}
+        if self.max_priority > 0:
+            arguments['x-max-priority'] = self.max_priority
+        self.channel.queue_declare(queue=queue, durable=self.rabbitmq_durable, arguments=arguments)
+
+    def publish_result(self, result):
+        # результат обучения читает results-consumer сервиса работы с моделями
+        self.channel.basic_publish(exchange='', routing_key=self.results_queue, body=json.dumps(result),
+                                   properties=pika.BasicProperties(content_type='application/json',
+                                                                   delivery_mode=2 if self.rabbitmq_durable else 1))
+
+    def retry(self, ch, method, properties, body):
+        headers = dict(properties.headers or {})
+        headers.pop('x-death', None)
+        attempt = int(headers.get(ATTEMPT_HEADER, 0)) + 1
+
+        # попытки исчерпаны - сообщение уходит в очередь недоставленных
+        if attempt >= self.max_attempts:
+            ch.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
+            return
+
+        headers[ATTEMPT_HEADER] = attempt
+        ch.basic_publish(exchange='', routing_key=self.queue_name + RETRY_QUEUE_SUFFIX, body=body,
+                         properties=pika.BasicProperties(content_type=properties.content_type, headers=headers,
+                                                         delivery_mode=properties.delivery_mode,
+                                                         priority=properties.priority))
+        ch.basic_ack(delivery_tag=method.delivery_tag)
 
     def callback(self, ch, method, properties, body):
-        try:
-            msg = json.loads(body)
+        self.handle(RabbitMQDelivery(self, ch, method, properties, body))
 
-            user_id = msg['user_id']
-            model_type = msg['model_type']
+    def start_consuming(self):
+        self.channel.basic_qos(prefetch_count=1)
+        self.channel.basic_consume(queue=self.queue_name, on_message_callback=self.callback, auto_ack=False)
+        print(' [*] Waiting for messages. To exit press CTRL+C')
+        self.channel.start_consuming()
 
-            df = self.repository.get_features_by_user_id(user_id)
+    def close_connection(self):
+        self.connection.close()
 
-            features_count = len(df)
 
-            logging.info(f"Get {features_count} records with user_id: {user_id}")
-            create_xgb(df, self.model_storage_url, user_id, model_type, features_count)
-            logging.info(f"Create xgb model for user_id: {user_id}")
+class PostgresDelivery:
+    def __init__(self, broker, message_id, body, attempt):
+        self.broker = broker
+        self.message_id = message_id
+        self.body = body
+        self.attempt = attempt
 
+    def ack(self):
+        self.broker.execute("DELETE FROM broker_messages WHERE message_id = %s", (self.message_id,))
 
+    def nack(self):
+        # отклоненное сообщение остается в таблице с признаком dead
+        self.broker.execute("UPDATE broker_messages SET dead = true, locked_until = NULL WHERE message_id = %s",
+                            (self.message_id,))
 
+    def retry(self):
+        attempt = self.attempt + 1
 
-        except Exception as e:
-            logging.error("Error processing message:", e)
+        # попытки исчерпаны - сообщение становится недоставленным
+        if attempt >= self.broker.max_attempts:
+            self.nack()
+            return
+
+        self.broker.execute("UPDATE broker_messages SET attempt = %s, "
+                            "available_at = now() + make_interval(secs => %s), locked_until = NULL "
+                            "WHERE message_id = %s",
+                            (attempt, self.broker.retry_delay_ms / 1000, self.message_id))
+
+
+# PostgresBroker - очереди в таблице broker_messages базы model_handler_service (BROKER_TYPE_MT=postgres),
+# позволяют запускать систему без RabbitMQ
+class PostgresBroker(Broker):
+    def __init__(self, model_storage_url, queue_name, repository, database_url, retry_delay_ms=30000,
+                 max_attempts=3, results_queue='model_results', model_uploads_url=None, poll_interval_s=1.0,
+                 visibility_timeout_s=3600):
+        super().__init__(model_storage_url, queue_name, repository, max_attempts=max_attempts,
+                         results_queue=results_queue, model_uploads_url=model_uploads_url)
+        self.database_url = database_url
+        self.retry_delay_ms = retry_delay_ms
+        self.poll_interval_s = poll_interval_s
+        # обучение может быть долгим: до подтверждения сообщение скрыто от других тренеров
+        self.visibility_timeout_s = visibility_timeout_s
+
+    def connect(self):
+        self.connection = psycopg2.connect(self.database_url)
+        self.connection.autocommit = True
+
+    def execute(self, query, params):
+        with self.connection.cursor() as cursor:
+            cursor.execute(query, params)
+
+    def claim(self):
+        with self.connection.cursor() as cursor:
+            cursor.execute(CLAIM_QUERY, (self.visibility_timeout_s, self.queue_name))
+            row = cursor.fetchone()
+
+        if row is None:
+            return None
+        message_id, body, attempt = row
+        return PostgresDelivery(self, message_id, bytes(body), attempt)
+
+    def publish_result(self, result):
+        # результат обучения читает results-consumer сервиса работы с моделями
+        self.execute("INSERT INTO broker_messages (queue, body) VALUES (%s, %s)",
+                     (self.results_queue, psycopg2.Binary(json.dumps(result).encode())))
 
     def start_consuming(self):
-        self.channel.basic_consume(queue=self.queue_name, on_message_callback=self.callback, auto_ack=True)
         print(' [*] Waiting for messages. To exit press CTRL+C')
-        self.channel.start_consuming()
+        while True:
+            delivery = self.claim()
+            if delivery is None:
+                time.sleep(self.poll_interval_s)
+                continue
+            self.handle(delivery)
 
     def close_connection(self):
         self.connection.close()
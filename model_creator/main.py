@@ -1,29 +1,56 @@
-from broker import Broker
+from broker import RabbitMQBroker, PostgresBroker
 from repository import Repository
 import os
 from custom_logger.logger import setup_logger
 import logging
 
+BROKER_TYPE_RABBITMQ = 'rabbitmq'
+BROKER_TYPE_POSTGRES = 'postgres'
+
+# транспорт задач должен совпадать с BROKER_TYPE_MT сервиса работы с моделями
+BROKER_TYPE = os.environ.get('BROKER_TYPE', BROKER_TYPE_RABBITMQ)
+# база сервиса работы с моделями с таблицей broker_messages, используется при BROKER_TYPE=postgres
+BROKER_DATABASE_URL = os.environ.get('BROKER_DATABASE_URL')
+BROKER_POLL_INTERVAL_S = float(os.environ.get('BROKER_POLL_INTERVAL_S', '1'))
+BROKER_VISIBILITY_TIMEOUT_S = int(os.environ.get('BROKER_VISIBILITY_TIMEOUT_S', '3600'))
+
 RABBITMQ_HOST = os.environ.get('RABBITMQ_HOST')
 RABBITMQ_QUEUE = os.environ.get('RABBITMQ_QUEUE')
+RABBITMQ_DURABLE = os.environ.get('RABBITMQ_DURABLE', 'true').lower() == 'true'
+RABBITMQ_RETRY_DELAY_MS = int(os.environ.get('RABBITMQ_RETRY_DELAY_MS', '30000'))
+RABBITMQ_MAX_ATTEMPTS = int(os.environ.get('RABBITMQ_MAX_ATTEMPTS', '3'))
+RABBITMQ_MAX_PRIORITY = int(os.environ.get('RABBITMQ_MAX_PRIORITY', '10'))
+RABBITMQ_RESULTS_QUEUE = os.environ.get('RABBITMQ_RESULTS_QUEUE', 'model_results')
 
-POSTGRESQL_HOST = os.environ.get('DB_HOST')
-POSTGRESQL_DBNAME = os.environ.get('DB_NAME')
-POSTGRESQL_USER = os.environ.get('DB_USERNAME')
-POSTGRESQL_PASSWORD = os.environ.get('DB_PASSWORD')
-POSTGRESQL_PORT = os.environ.get('DB_PORT')
+FEATURES_STORAGE_URL = os.environ.get('FEATURES_STORAGE_URL')
+# общий токен внутренних сервисов для выгрузки признаков
+SERVICE_TOKEN = os.environ.get('SERVICE_TOKEN')
 
 MODEL_STORAGE_URL = os.environ.get('MODEL_STORAGE_URL')
+# если задан, то модели загружаются напрямую в s3 по ссылкам сервиса работы с моделями
+MODEL_UPLOADS_URL = os.environ.get('MODEL_UPLOADS_URL')
 
 if __name__ == '__main__':
     setup_logger()
     logging.info("start model creator service")
 
-    engine_str = f'postgresql://{POSTGRESQL_USER}:{POSTGRESQL_PASSWORD}@{POSTGRESQL_HOST}:{POSTGRESQL_PORT}/{POSTGRESQL_DBNAME}'
-    repository = Repository(engine_str)
+    repository = Repository(FEATURES_STORAGE_URL, SERVICE_TOKEN)
 
-    consumer = Broker(model_storage_url=MODEL_STORAGE_URL, queue_name=RABBITMQ_QUEUE, repository=repository,
-                      rabbitmq_host=RABBITMQ_HOST)
+    if BROKER_TYPE == BROKER_TYPE_POSTGRES:
+        consumer = PostgresBroker(model_storage_url=MODEL_STORAGE_URL, queue_name=RABBITMQ_QUEUE,
+                                  repository=repository, database_url=BROKER_DATABASE_URL,
+                                  retry_delay_ms=RABBITMQ_RETRY_DELAY_MS, max_attempts=RABBITMQ_MAX_ATTEMPTS,
+                                  results_queue=RABBITMQ_RESULTS_QUEUE, model_uploads_url=MODEL_UPLOADS_URL,
+                                  poll_interval_s=BROKER_POLL_INTERVAL_S,
+                                  visibility_timeout_s=BROKER_VISIBILITY_TIMEOUT_S)
+    elif BROKER_TYPE == BROKER_TYPE_RABBITMQ:
+        consumer = RabbitMQBroker(model_storage_url=MODEL_STORAGE_URL, queue_name=RABBITMQ_QUEUE,
+                                  repository=repository, rabbitmq_host=RABBITMQ_HOST,
+                                  rabbitmq_durable=RABBITMQ_DURABLE, retry_delay_ms=RABBITMQ_RETRY_DELAY_MS,
+                                  max_attempts=RABBITMQ_MAX_ATTEMPTS, max_priority=RABBITMQ_MAX_PRIORITY,
+                                  results_queue=RABBITMQ_RESULTS_QUEUE, model_uploads_url=MODEL_UPLOADS_URL)
+    else:
+        raise ValueError(f"unknown broker type: {BROKER_TYPE}")
 
     consumer.connect()
     consumer.start_consuming()
@@ -2,16 +2,18 @@ import numpy as np
 import pandas as pd
 from sklearn.preprocessing import StandardScaler
 from sklearn.model_selection import train_test_split, cross_val_score
+from sklearn.metrics import accuracy_score, f1_score, log_loss, precision_score, recall_score, roc_auc_score
 import xgboost
 import logging
 import os
+import hashlib
 import requests
 
-# create_xgb - функция создания модели градиентного бустинга
-def create_xgb(data, url, user_id, model_type, features_count):
-    # Удаляем ненужные в обучении колонки
+# prepare_dataset - функция выделения из выгрузки признаков и соответствующих им классов
+def prepare_dataset(data):
+    # Удаляем ненужные в обучении колонки, номер кадра есть только в схемах признаков видео
     data = data.drop(columns=['video_id'])
-    data = data.drop(columns=['frame_count'])
+    data = data.drop(columns=['frame_count'], errors='ignore')
     data = data.drop(columns=['user_id'])
 
     # берем числовые колонки
@@ -20,8 +22,13 @@ def create_xgb(data, url, user_id, model_type, features_count):
     df = data[num_cols]
 
     # определяем дата-фрейм признаков и соответствующих им классов
-    X = df
-    Y = data["label"]
+    return df, data["label"]
+
+# create_xgb - функция создания модели градиентного бустинга.
+# Если задан base_model_url, то обучение продолжается с предыдущей или глобальной модели (теплый старт).
+# Если задан uploads_url, то модель загружается напрямую в s3 по выданной ссылке, иначе отправляется на url
+def create_xgb(data, url, user_id, model_type, features_count, base_model_url=None, uploads_url=None):
+    X, Y = prepare_dataset(data)
 
     # разделяем данные на тестовые и тренировочные
     X_train, X_test, y_train, y_test = train_test_split(X, Y, test_size=0.2, random_state=42)
@@ -30,20 +37,89 @@ def create_xgb(data, url, user_id, model_type, features_count):
     xgb_best_params = {'colsample_bytree': 0.8, 'eta': 0.1, 'max_depth': 9, 'n_estimators': 800, 'subsample': 0.7}
     # объявляем и обучаем модель
     xgb = xgboost.XGBClassifier(**xgb_best_params)
-    xgb.fit(X_train, y_train)
+    if base_model_url:
+        base_model_path = './models/base.ubj'
+        download_model(base_model_url, base_model_path)
+        try:
+            xgb.fit(X_train, y_train, xgb_model=base_model_path)
+        finally:
+            delete_file(base_model_path)
+    else:
+        xgb.fit(X_train, y_train)
 
     cv_scores = cross_val_score(xgb, X_test, y_test, cv=5)
 
     # Вывод результатов
-    logging.info("Cross-validation scores:", cv_scores)
-    logging.info("Average accuracy:", cv_scores.mean())
+    logging.info(f"Cross-validation scores: {cv_scores}")
+    logging.info(f"Average accuracy: {cv_scores.mean()}")
+
+    metrics = {
+        'cv_accuracy_mean': float(cv_scores.mean()),
+        'cv_accuracy_std': float(cv_scores.std()),
+    }
 
-    file_path = './models/tmp.xgb'
+    # формат файла xgboost выбирает по расширению: .ubj - UBJSON, который читает инференс сервиса работы с моделями,
+    # а прочие расширения, кроме .json, xgboost 2.0 сохраняет в устаревшем бинарном формате
+    file_path = './models/tmp.ubj'
     xgb.save_model(file_path)
-    # Отправялем модель в сервис работы с моделями
-    send_model(file_path, url, user_id, model_type, features_count)
-    # Удаляем модель
-    delete_file(file_path)
+    try:
+        if uploads_url:
+            s3_key = upload_model(file_path, uploads_url, user_id, model_type, features_count)
+        else:
+            # Отправялем модель в сервис работы с моделями
+            s3_key = send_model(file_path, url, user_id, model_type, features_count)
+    finally:
+        # Удаляем модель
+        delete_file(file_path)
+
+    return s3_key, metrics
+
+# evaluate_xgb - функция сравнения двух версий модели на отложенных данных, возвращает метрики каждой версии
+def evaluate_xgb(data, baseline_model_url, candidate_model_url):
+    X, Y = prepare_dataset(data)
+
+    return {
+        'baseline': evaluate_model(X, Y, baseline_model_url, './models/baseline.ubj'),
+        'candidate': evaluate_model(X, Y, candidate_model_url, './models/candidate.ubj'),
+    }
+
+# evaluate_model - функция расчета метрик одной версии модели
+def evaluate_model(X, Y, model_url, file_path):
+    download_model(model_url, file_path)
+    try:
+        xgb = xgboost.XGBClassifier()
+        xgb.load_model(file_path)
+    finally:
+        delete_file(file_path)
+
+    # класс 1 - усталость
+    proba = xgb.predict_proba(X)[:, 1]
+    predicted = (proba >= 0.5).astype(int)
+
+    metrics = {
+        'samples': int(len(Y)),
+        'accuracy': float(accuracy_score(Y, predicted)),
+        'precision': float(precision_score(Y, predicted, zero_division=0)),
+        'recall': float(recall_score(Y, predicted, zero_division=0)),
+        'f1': float(f1_score(Y, predicted, zero_division=0)),
+        'log_loss': float(log_loss(Y, proba, labels=[0, 1])),
+    }
+    # ROC AUC определен, только если в отложенных данных есть оба класса
+    if Y.nunique() == 2:
+        metrics['roc_auc'] = float(roc_auc_score(Y, proba))
+
+    logging.info(f"Метрики модели: {metrics}")
+
+    return metrics
+
+# download_model - функция скачивания модели по предподписанной ссылке
+def download_model(model_url, file_path):
+    response = requests.get(model_url)
+    # ошибку хранилища пробрасываем выше, чтобы задача попала на повтор
+    response.raise_for_status()
+    with open(file_path, 'wb') as file:
+        file.write(response.content)
+    logging.info(f"Базовая модель скачана: {file_path}")
 
 # delete_file - функция удаления файла
 def delete_file(file_path):
@@ -57,25 +133,59 @@ def delete_file(file_path):
     except Exception as e:
         logging.error(f"Произошла ошибка при удалении файла: {str(e)}")
 
-# send_model - функция отправления файла модели
+# send_model - функция отправления файла модели, возвращает s3 ключ сохраненной модели
 def send_model(file_path, url, user_id, model_type, features_count):
-    try:
-        # открываем файл обученной модели
-        with open(file_path, 'rb') as file:
-            # задаем строковые поля формы
-            data = {
-                'user_id': user_id,
-                'model_type': model_type,
-                'features_count': str(features_count)
-            }
-            # задаем файл модели в поле file
-            files = {'file': file}
-            # отправляем http-запросом модель и другие данные
-            response = requests.post(url, data=data, files=files)
-            #  логируем успешность выполненного запроса
-            if str(response.status_code).startswith('2'):
-                logging.info(f"Файл успешно отправлен по HTTP: {file_path}")
-            else:
-                logging.warning(f"Произошла ошибка при отправке файла: {response.status_code}")
-    except Exception as e:
-        logging.error(f"Произошла ошибка: {str(e)}")
+    # открываем файл обученной модели
+    with open(file_path, 'rb') as file:
+        # задаем строковые поля формы
+        data = {
+            'user_id': user_id,
+            'model_type': model_type,
+            'features_count': str(features_count)
+        }
+        # задаем файл модели в поле file
+        files = {'file': file}
+        # отправляем http-запросом модель и другие данные
+        response = requests.post(url, data=data, files=files)
+        # ошибку сервиса пробрасываем выше, чтобы задача попала на повтор
+        response.raise_for_status()
+        logging.info(f"Файл успешно отправлен по HTTP: {file_path}")
+
+        return response.json()['content']['s3_key']
+
+# upload_model - функция загрузки файла модели напрямую в s3 по предподписанной ссылке,
+# возвращает s3 ключ сохраненной модели
+def upload_model(file_path, uploads_url, user_id, model_type, features_count):
+    # считаем контрольную сумму, сервис сверяет с ней загруженный файл при подтверждении загрузки
+    sha256 = hashlib.sha256()
+    with open(file_path, 'rb') as file:
+        for chunk in iter(lambda: file.read(1024 * 1024), b''):
+            sha256.update(chunk)
+
+    # запрашиваем ссылку на загрузку
+    response = requests.post(uploads_url, json={
+        'user_id': user_id,
+        'model_type': model_type,
+        'filename': os.path.basename(file_path),
+        'sha256': sha256.hexdigest(),
+    })
+    # ошибку сервиса пробрасываем выше, чтобы задача попала на повтор
+    response.raise_for_status()
+    upload = response.json()['content']
+
+    # загружаем модель вместе с подписанными заголовками
+    with open(file_path, 'rb') as file:
+        response = requests.put(upload['upload_url'], data=file, headers=upload['headers'])
+        response.raise_for_status()
+
+    # подтверждаем загрузку, после этого модель становится текущей моделью пользователя
+    response = requests.post(uploads_url.rstrip('/') + '/complete', json={
+        'user_id': user_id,
+        'model_type': model_type,
+        's3_key': upload['s3_key'],
+        'features_count': features_count,
+    })
+    response.raise_for_status()
+    logging.info(f"Файл успешно загружен в s3: {file_path}")
+
+    return upload['s3_key']
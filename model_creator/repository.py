@@ -1,28 +1,37 @@
-import sqlalchemy as db
+import io
+from datetime import datetime, timezone
+
 import pandas as pd
-from contextlib import contextmanager
+import requests
+
+SERVICE_TOKEN_HEADER = 'X-Service-Token'
 
 
 class Repository:
-    def __init__(self, db_uri):
-        self.engine = db.create_engine(db_uri)
-
-    @contextmanager
-    def connect(self):
-        connection = self.engine.connect()
-        try:
-            yield connection
-        finally:
-            connection.close()
-
-    def get_features_by_user_id(self, user_id):
-        with self.connect() as connection:
-            query = "SELECT * FROM video_features WHERE user_id = %s"
-            records_df = pd.read_sql_query(query, connection, params=(user_id,))
-            return records_df
-
-    def get_all_features(self):
-        with self.connect() as connection:
-            query = "SELECT * FROM video_features"
-            records_df = pd.read_sql_query(query, connection)
-            return records_df
+    # features_url - адрес выгрузки признаков, {model_type} заменяется на тип модели из задачи
+    def __init__(self, features_url, service_token):
+        self.features_url = features_url
+        self.service_token = service_token
+
+    def get_features(self, model_type, params):
+        # выгружаем признаки типа модели из сервиса хранения признаков в формате csv
+        response = requests.get(self.features_url.replace('{model_type}', model_type),
+                                params={**params, 'format': 'csv'},
+                                headers={SERVICE_TOKEN_HEADER: self.service_token})
+        response.raise_for_status()
+        return pd.read_csv(io.BytesIO(response.content))
+
+    def get_features_by_user_id(self, model_type, user_id):
+        return self.get_features(model_type, {'user_id': user_id})
+
+    def get_all_features(self, model_type):
+        # выгрузка без фильтров запрещена, поэтому берем признаки всех пользователей, загруженные до начала обучения
+        return self.get_features(model_type, {'to': datetime.now(timezone.utc).isoformat(timespec='seconds')})
+
+    def get_features_by_video_ids(self, model_type, user_id, video_ids):
+        # отложенные видео выгружаем по одному, так как сервис фильтрует по одному video_id
+        frames = [self.get_features(model_type, {'user_id': user_id, 'video_id': video_id}) for video_id in video_ids]
+        df = pd.concat(frames, ignore_index=True)
+        if df.empty:
+            raise ValueError(f"no features found for video_ids: {video_ids}")
+        return df
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/dead_letters"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/gc_models"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/migrate_queues"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/model_trainer"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/results_consumer"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/serve"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/cmd/commands/webhook_dispatcher"
 	_ "github.com/garet2gis/fatigue-detection-system/model_handler_service/docs"
 	_ "github.com/garet2gis/fatigue-detection-system/model_handler_service/migrations"
 
@@ -28,6 +33,39 @@ func main() {
 				Name:   "model-trainer",
 				Action: model_trainer.Action,
 			},
+			{
+				Name:   "results-consumer",
+				Action: results_consumer.Action,
+			},
+			{
+				Name:   "webhook-dispatcher",
+				Action: webhook_dispatcher.Action,
+			},
+			{
+				Name:   "gc-models",
+				Flags:  gc_models.Flags,
+				Action: gc_models.Action,
+			},
+			{
+				Name:   "migrate-queues",
+				Flags:  migrate_queues.Flags,
+				Action: migrate_queues.Action,
+			},
+			{
+				Name: "dead-letters",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Flags:  dead_letters.Flags,
+						Action: dead_letters.ListAction,
+					},
+					{
+						Name:   "replay",
+						Flags:  dead_letters.Flags,
+						Action: dead_letters.ReplayAction,
+					},
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,99 @@
+package dead_letters
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+var Flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "queue",
+		Usage: "очередь задач, недоставленные сообщения которой обрабатываются",
+		Value: "face_model",
+	},
+	&cli.IntFlag{
+		Name:  "limit",
+		Usage: "максимальное количество обрабатываемых сообщений",
+		Value: 100,
+	},
+}
+
+type deadLetterView struct {
+	Attempt int             `json:"attempt"`
+	Reason  string          `json:"reason,omitempty"`
+	Time    *time.Time      `json:"time,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// ListAction - выводит недоставленные задачи очереди в формате JSON lines, не удаляя их из очереди
+func ListAction(c *cli.Context) error {
+	op := "dead_letters.ListAction"
+
+	cfg := config.GetConfigDeadLetters()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	rabbit, err := rabbitmq.NewRabbitMQConnection(cfg.ToRabbitMQConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer rabbit.Close()
+
+	deadLetters, err := rabbit.PeekDeadLetters(c.String("queue"), c.Int("limit"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, deadLetter := range deadLetters {
+		view := deadLetterView{
+			Attempt: deadLetter.Attempt,
+			Reason:  deadLetter.Reason,
+			Time:    deadLetter.Time,
+			Body:    deadLetter.Body,
+		}
+		// тело задачи, не являющееся JSON, выводим строкой
+		if !json.Valid(deadLetter.Body) {
+			view.Body, _ = json.Marshal(string(deadLetter.Body))
+		}
+
+		err = enc.Encode(view)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// ReplayAction - возвращает недоставленные задачи в основную очередь со сброшенным счетчиком попыток
+func ReplayAction(c *cli.Context) error {
+	op := "dead_letters.ReplayAction"
+
+	cfg := config.GetConfigDeadLetters()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	rabbit, err := rabbitmq.NewRabbitMQConnection(cfg.ToRabbitMQConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer rabbit.Close()
+
+	replayed, err := rabbit.ReplayDeadLetters(c.String("queue"), c.Int("limit"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.With(zap.String("queue", c.String("queue")), zap.Int("count", replayed)).
+		Info(fmt.Sprintf("%s: replay dead letters", op))
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package gc_models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+var Flags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "только вывести объекты, которые будут удалены",
+	},
+	&cli.StringFlag{
+		Name:  "prefix",
+		Usage: "префикс ключей проверяемых объектов, например <тип модели>/<id пользователя>/, по умолчанию - <тип модели>/ каждого известного типа",
+	},
+	&cli.Uint64Flag{
+		Name:  "keep-versions",
+		Usage: "количество последних обученных версий каждой модели, которые сохраняются вместе с текущей",
+		Value: 3,
+	},
+	&cli.DurationFlag{
+		Name:  "min-age",
+		Usage: "объекты моложе этого возраста не удаляются: их загрузка может быть еще не подтверждена",
+		Value: 24 * time.Hour,
+	},
+}
+
+type orphanView struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Action - удаляет из s3 файлы моделей, на которые не ссылаются ни модели, ни сохраняемые версии,
+// например, старые версии после дообучения и файлы, загруженные в транзакции, которая не завершилась.
+// Удаляемые объекты выводятся в формате JSON lines
+func Action(c *cli.Context) error {
+	op := "gc_models.Action"
+
+	cfg := config.GetConfigGCModels()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	ctx := logger.ContextWithLogger(context.Background(), l)
+
+	s3Client, err := s3_client.NewS3Client(ctx, cfg.ToS3Config())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+
+	dbClient, err := postgresql.NewClient(ctx, cfg.ToDBConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer dbClient.Close()
+
+	repository := data.NewRepository(dbClient)
+
+	// Модели хранятся в s3 по пути <тип модели>/<id пользователя>/, поэтому без явного префикса проверяются
+	// только каталоги известных типов моделей, а остальные объекты бакета не затрагиваются
+	prefixes := []string{c.String("prefix")}
+	if c.String("prefix") == "" {
+		modelTypes, err := repository.GetModelTypes(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		prefixes = make([]string, 0, len(modelTypes))
+		for _, modelType := range modelTypes {
+			prefixes = append(prefixes, modelType.ModelType+"/")
+		}
+	}
+
+	// Сначала берем список объектов, потом ссылки на них: файл, загруженный между этими шагами,
+	// не попадет в список, а ссылка, появившаяся между ними, только убережет объект от удаления
+	objects := make([]s3_client.ObjectInfo, 0)
+	for _, prefix := range prefixes {
+		prefixObjects, err := s3Client.ListObjectsInfo(ctx, prefix)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		objects = append(objects, prefixObjects...)
+	}
+
+	retained, err := repository.GetRetainedS3Keys(ctx, c.Uint64("keep-versions"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	createdBefore := time.Now().Add(-c.Duration("min-age"))
+
+	enc := json.NewEncoder(os.Stdout)
+	orphans := make([]string, 0)
+	var orphansSize int64
+	for _, object := range objects {
+		if _, ok := retained[object.Key]; ok || object.LastModified.After(createdBefore) {
+			continue
+		}
+
+		err = enc.Encode(orphanView{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		orphans = append(orphans, object.Key)
+		orphansSize += object.Size
+	}
+
+	l = l.With(
+		zap.Int("objects", len(objects)),
+		zap.Int("orphans", len(orphans)),
+		zap.Int64("orphans_size", orphansSize),
+	)
+
+	if c.Bool("dry-run") {
+		l.Info(fmt.Sprintf("%s: dry run, nothing deleted", op))
+		return nil
+	}
+
+	err = s3Client.DeleteObjects(ctx, orphans)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.Info(fmt.Sprintf("%s: delete orphaned models", op))
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package migrate_queues
+
+import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+)
+
+var Flags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:  "queue",
+		Usage: "очередь, которая приводится к текущим параметрам, по умолчанию очередь задач face_model и очередь результатов",
+	},
+}
+
+// Action - пересоздает очереди, объявленные ранее с другими параметрами (без durable или x-max-priority),
+// сохраняя их сообщения. Запускается при обновлении до остановленных тренеров, model-trainer и results-consumer
+func Action(c *cli.Context) error {
+	op := "migrate_queues.Action"
+
+	// очередям нужны только настройки брокера, как и разбору недоставленных сообщений
+	cfg := config.GetConfigDeadLetters()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	rabbit, err := rabbitmq.NewRabbitMQConnection(cfg.ToRabbitMQConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer rabbit.Close()
+
+	queues := c.StringSlice("queue")
+	if len(queues) == 0 {
+		queues = []string{"face_model", cfg.RabbitResultsQueue}
+	}
+
+	for _, queue := range queues {
+		moved, err := rabbit.MigrateQueue(queue)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		l.With(zap.String("queue", queue), zap.Int("moved", moved)).
+			Info(fmt.Sprintf("%s: migrate queue", op))
+	}
+
+	return nil
+}
@@ -5,9 +5,9 @@ import (
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
-	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
 	"github.com/go-co-op/gocron"
 	"github.com/urfave/cli/v2"
@@ -24,18 +24,26 @@ func Action(_ *cli.Context) error {
 		l.Fatal(err.Error())
 	}
 
-	rabbit, err := rabbitmq.NewRabbitMQConnection(cfg.RabbitURL, cfg.RabbitPoolSize)
+	messageBroker, err := broker.New(cfg.ToBrokerConfig(), dbClient, l)
 	if err != nil {
 		l.Fatal(err.Error())
 	}
-	defer rabbit.Close()
+	defer messageBroker.Close()
 
-	var queues []string
-	for queue := range cfg.ModelTrainThresholds {
-		queues = append(queues, queue)
+	repository := data.NewRepository(dbClient)
+
+	// Заполняем таблицу порогов из файла конфигурации, пороги, измененные через API, не перезаписываются
+	thresholds := make([]data.TrainThreshold, 0, len(cfg.ModelTrainThresholds))
+	for _, threshold := range cfg.ModelTrainThresholds {
+		thresholds = append(thresholds, threshold)
+	}
+	err = repository.SeedTrainThresholds(logger.ContextWithLogger(context.Background(), l), thresholds)
+	if err != nil {
+		l.Fatal(err.Error())
 	}
 
-	err = rabbit.InitQueues(queues)
+	// Очередь результатов объявляем заранее, чтобы результаты тренеров не терялись до запуска results-consumer
+	err = messageBroker.InitQueues([]string{cfg.RabbitResultsQueue})
 	if err != nil {
 		l.Fatal(err.Error())
 	}
@@ -46,12 +54,12 @@ func Action(_ *cli.Context) error {
 	}
 
 	scheduler := gocron.NewScheduler(time.UTC)
-	trainer := workers.NewModelTrainer(data.NewRepository(dbClient), s3Client, dbClient, rabbit, scheduler, cfg.ModelTrainThresholds, l)
+	trainer := workers.NewModelTrainer(repository, s3Client, cfg.PresignJobExpiry, cfg.RabbitMaxPriority, dbClient, messageBroker, scheduler, l)
 	if err != nil {
 		l.Fatal(err.Error())
 	}
 
-	trainer.StartTrainModels(cfg.CRON)
+	trainer.StartTrainModels(cfg.CRON, cfg.GlobalCRON)
 
 	return nil
 }
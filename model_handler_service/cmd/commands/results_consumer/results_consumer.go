@@ -0,0 +1,39 @@
+package results_consumer
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/urfave/cli/v2"
+	"os/signal"
+	"syscall"
+)
+
+func Action(_ *cli.Context) error {
+	cfg := config.GetConfigResultsConsumer()
+
+	l := logger.NewLogger(cfg.ToLoggerConfig())
+
+	dbClient, err := postgresql.NewClient(context.Background(), cfg.ToDBConfig())
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer dbClient.Close()
+
+	messageBroker, err := broker.New(cfg.ToBrokerConfig(), dbClient, l)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	defer messageBroker.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	consumer := workers.NewResultsConsumer(data.NewRepository(dbClient), dbClient, messageBroker, cfg.RabbitResultsQueue, l)
+
+	return consumer.Run(ctx)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/config"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/inference"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
@@ -27,9 +28,15 @@ func Action(_ *cli.Context) error {
 	if err != nil {
 		l.Fatal(err.Error())
 	}
+	predictor, err := inference.NewPredictor(cfg.ToInferenceConfig(), s3Client)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+
 	validate := validator.New()
 
-	coreHandler := handlers.NewCoreHandler(s3Client, data.NewRepository(dbClient), dbClient, validate, l)
+	coreHandler := handlers.NewCoreHandler(s3Client, data.NewRepository(dbClient), dbClient, predictor, validate,
+		cfg.ToPresignExpiry(), cfg.MaxPriority, cfg.ServiceToken, cfg.SchemasHandler, l)
 
 	app := server.NewServer(cfg.ToAppConfig(), coreHandler.Router(), l)
 
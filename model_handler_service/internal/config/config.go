@@ -1,11 +1,17 @@
 package config
 
 import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/inference"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/server"
 	"log"
+	"time"
 
 	"sync"
 
@@ -34,6 +40,86 @@ type S3Config struct {
 
 	AccessKeyID     string `env:"ACCESS_KEY_ID" env-required:"true"`
 	SecretAccessKey string `env:"SECRET_ACCESS_KEY" env-required:"true"`
+
+	// UploadPartSizeMB - размер части составной загрузки, файлы меньше одной части загружаются одним запросом
+	UploadPartSizeMB  int64 `env:"S3_UPLOAD_PART_SIZE_MB" env-default:"8"`
+	UploadConcurrency int   `env:"S3_UPLOAD_CONCURRENCY" env-default:"4"`
+
+	// PresignDownloadExpiry - время жизни ссылок на скачивание моделей, выдаваемых desktop-приложению
+	PresignDownloadExpiry time.Duration `env:"S3_PRESIGN_DOWNLOAD_EXPIRY" env-default:"1h"`
+	// PresignJobExpiry - время жизни ссылок в задачах тренера, задача может ждать в очереди несколько часов
+	PresignJobExpiry time.Duration `env:"S3_PRESIGN_JOB_EXPIRY" env-default:"24h"`
+	// PresignUploadExpiry - время жизни ссылок на загрузку обученной модели, тренер запрашивает их после обучения
+	PresignUploadExpiry time.Duration `env:"S3_PRESIGN_UPLOAD_EXPIRY" env-default:"15m"`
+}
+
+type RabbitMQConfig struct {
+	RabbitURL            string        `env:"RABBIT_URL_MT"`
+	RabbitPoolSize       int           `env:"RABBIT_POOL_SIZE_MT"  env-default:"10"`
+	RabbitDurable        bool          `env:"RABBIT_DURABLE_MT"  env-default:"true"`
+	RabbitConfirmTimeout time.Duration `env:"RABBIT_CONFIRM_TIMEOUT_MT"  env-default:"5s"`
+	RabbitPrefetch       int           `env:"RABBIT_PREFETCH_MT"  env-default:"1"`
+	RabbitRetryDelay     time.Duration `env:"RABBIT_RETRY_DELAY_MT"  env-default:"30s"`
+	RabbitMaxAttempts    int           `env:"RABBIT_MAX_ATTEMPTS_MT"  env-default:"3"`
+	RabbitMaxPriority    uint8         `env:"RABBIT_MAX_PRIORITY_MT"  env-default:"10"`
+	RabbitResultsQueue   string        `env:"RABBIT_RESULTS_QUEUE_MT"  env-default:"model_results"`
+}
+
+func (c RabbitMQConfig) Validate() error {
+	op := "config.RabbitMQConfig.Validate"
+
+	if c.RabbitURL == "" {
+		return fmt.Errorf("%s: field RabbitURL is required but the value is not provided (RABBIT_URL_MT)", op)
+	}
+
+	return nil
+}
+
+func (c RabbitMQConfig) ToRabbitMQConfig() rabbitmq.ConfigRabbitMQ {
+	return rabbitmq.ConfigRabbitMQ{
+		URI:            c.RabbitURL,
+		PoolSize:       c.RabbitPoolSize,
+		Durable:        c.RabbitDurable,
+		ConfirmTimeout: c.RabbitConfirmTimeout,
+		Prefetch:       c.RabbitPrefetch,
+		RetryDelay:     c.RabbitRetryDelay,
+		MaxAttempts:    c.RabbitMaxAttempts,
+		MaxPriority:    c.RabbitMaxPriority,
+	}
+}
+
+// BrokerConfig - выбор брокера сообщений. Очереди Postgres используют
+// настройки повторов и prefetch из RabbitMQConfig
+type BrokerConfig struct {
+	RabbitMQConfig
+
+	BrokerType              string        `env:"BROKER_TYPE_MT"  env-default:"rabbitmq"`
+	BrokerPollInterval      time.Duration `env:"BROKER_POLL_INTERVAL_MT"  env-default:"1s"`
+	BrokerVisibilityTimeout time.Duration `env:"BROKER_VISIBILITY_TIMEOUT_MT"  env-default:"5m"`
+}
+
+// Validate - проверяет выбор брокера: адрес RabbitMQ обязателен только при BROKER_TYPE_MT=rabbitmq
+func (c BrokerConfig) Validate() error {
+	op := "config.BrokerConfig.Validate"
+
+	switch c.BrokerType {
+	case broker.TypeRabbitMQ:
+		return c.RabbitMQConfig.Validate()
+	case broker.TypePostgres:
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown broker type %q, expected %s or %s",
+			op, c.BrokerType, broker.TypeRabbitMQ, broker.TypePostgres)
+	}
+}
+
+func (c BrokerConfig) ToBrokerConfig() broker.Config {
+	return broker.Config{
+		Type:              c.BrokerType,
+		RabbitMQ:          c.ToRabbitMQConfig(),
+		PollInterval:      c.BrokerPollInterval,
+		VisibilityTimeout: c.BrokerVisibilityTimeout,
+	}
 }
 
 type LoggerConfig struct {
@@ -53,12 +139,35 @@ type SwaggerConfig struct {
 	SwaggerURL      string `env:"SWAGGER_URL"`
 }
 
+// PredictConfig - настройки серверного инференса моделей
+type PredictConfig struct {
+	ModelCacheSize     int `env:"PREDICT_MODEL_CACHE_SIZE" env-default:"100"`
+	SmoothingWindow    int `env:"PREDICT_SMOOTHING_WINDOW" env-default:"16"`
+	MaxSmoothingWindow int `env:"PREDICT_MAX_SMOOTHING_WINDOW" env-default:"256"`
+}
+
+func (c PredictConfig) ToInferenceConfig() inference.Config {
+	return inference.Config{
+		ModelCacheSize:     c.ModelCacheSize,
+		SmoothingWindow:    c.SmoothingWindow,
+		MaxSmoothingWindow: c.MaxSmoothingWindow,
+	}
+}
+
 type Config struct {
 	DBConfig
 	LoggerConfig
 	HTTPConfig
 	S3Config
 	SwaggerConfig
+	PredictConfig
+
+	// MaxPriority - x-max-priority очередей задач, общий с model-trainer, ограничивает приоритеты порогов обучения
+	MaxPriority uint8 `env:"RABBIT_MAX_PRIORITY_MT"  env-default:"10"`
+	// ServiceToken - общий токен внутренних сервисов, передается в заголовке X-Service-Token
+	ServiceToken string `env:"SERVICE_TOKEN"  env-required:"true"`
+	// SchemasHandler - схемы признаков face_features_storage, справочник типов моделей применяет к ним свои схемы
+	SchemasHandler string `env:"SCHEMAS_HANDLER_URL" env-default:"http://0.0.0.0:3392/api/v1/schemas"`
 }
 
 var instance *Config
@@ -111,6 +220,13 @@ func (c Config) ToAppConfig() server.AppConfig {
 	}
 }
 
+func (c Config) ToPresignExpiry() handlers.PresignExpiry {
+	return handlers.PresignExpiry{
+		Download: c.S3Config.PresignDownloadExpiry,
+		Upload:   c.S3Config.PresignUploadExpiry,
+	}
+}
+
 func (c Config) ToS3Config() s3_client.ConfigS3 {
 	return s3_client.ConfigS3{
 		Region:            c.S3Config.Region,
@@ -120,5 +236,7 @@ func (c Config) ToS3Config() s3_client.ConfigS3 {
 		Bucket:            c.S3Config.BucketName,
 		AccessKeyID:       c.S3Config.AccessKeyID,
 		SecretAccessKey:   c.S3Config.SecretAccessKey,
+		UploadPartSize:    c.S3Config.UploadPartSizeMB * 1024 * 1024,
+		UploadConcurrency: c.S3Config.UploadConcurrency,
 	}
 }
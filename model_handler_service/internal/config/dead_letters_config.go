@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+)
+
+type DeadLettersConfig struct {
+	LoggerConfig
+	RabbitMQConfig
+}
+
+func (c DeadLettersConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+var instanceDeadLetters *DeadLettersConfig
+var onceDeadLetters sync.Once
+
+func GetConfigDeadLetters() *DeadLettersConfig {
+	onceDeadLetters.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceDeadLetters = &DeadLettersConfig{}
+		if err := cleanenv.ReadEnv(instanceDeadLetters); err != nil {
+			help, _ := cleanenv.GetDescription(instanceDeadLetters, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+		if err := instanceDeadLetters.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	return instanceDeadLetters
+}
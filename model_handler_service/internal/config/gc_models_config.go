@@ -0,0 +1,68 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+)
+
+type GCModelsConfig struct {
+	DBConfig
+	LoggerConfig
+	S3Config
+}
+
+func (c GCModelsConfig) ToDBConfig() postgresql.DBConfig {
+	return postgresql.DBConfig{
+		Port:                  c.DBPort,
+		Host:                  c.DBHost,
+		Name:                  c.DBName,
+		Password:              c.DBPassword,
+		Username:              c.DBUsername,
+		MaxConnectionAttempts: c.MaxConnectionAttempts,
+		AutoMigrate:           c.AutoMigrate,
+		MigrationsDir:         c.MigrationsDir,
+	}
+}
+
+func (c GCModelsConfig) ToS3Config() s3_client.ConfigS3 {
+	return s3_client.ConfigS3{
+		Region:            c.S3Config.Region,
+		S3Host:            c.S3Config.S3Host,
+		PartitionID:       c.S3Config.PartitionID,
+		HostnameImmutable: c.S3Config.HostnameImmutable,
+		Bucket:            c.S3Config.BucketName,
+		AccessKeyID:       c.S3Config.AccessKeyID,
+		SecretAccessKey:   c.S3Config.SecretAccessKey,
+		UploadPartSize:    c.S3Config.UploadPartSizeMB * 1024 * 1024,
+		UploadConcurrency: c.S3Config.UploadConcurrency,
+	}
+}
+
+func (c GCModelsConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+var instanceGCModels *GCModelsConfig
+var onceGCModels sync.Once
+
+func GetConfigGCModels() *GCModelsConfig {
+	onceGCModels.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceGCModels = &GCModelsConfig{}
+		if err := cleanenv.ReadEnv(instanceGCModels); err != nil {
+			help, _ := cleanenv.GetDescription(instanceGCModels, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+	})
+
+	return instanceGCModels
+}
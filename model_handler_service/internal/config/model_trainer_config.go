@@ -3,7 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
@@ -18,12 +18,15 @@ type ModelTrainerConfig struct {
 	LoggerConfig
 	S3Config
 
-	RabbitURL      string `env:"RABBIT_URL_MT"  env-required:"true"`
-	RabbitPoolSize int    `env:"RABBIT_POOL_SIZE_MT"  env-default:"10"`
-	CRON           string `env:"CRON_MT"  env-default:"*/5 * * * * *"`
+	BrokerConfig
 
+	CRON string `env:"CRON_MT"  env-default:"*/5 * * * * *"`
+	// GlobalCRON - расписание обучения глобальных моделей, пустое значение отключает их обучение
+	GlobalCRON string `env:"GLOBAL_CRON_MT"  env-default:"0 0 3 * * *"`
+
+	// PathToTrainThresholds - файл с начальными порогами обучения, которыми заполняется таблица train_thresholds
 	PathToTrainThresholds string `env:"PATH_TO_TRAIN_THRESHOLDS"  env-default:"thresholds.json"`
-	ModelTrainThresholds  map[string]workers.ModelTrainThreshold
+	ModelTrainThresholds  map[string]data.TrainThreshold
 }
 
 func (c ModelTrainerConfig) ToDBConfig() postgresql.DBConfig {
@@ -48,6 +51,8 @@ func (c ModelTrainerConfig) ToS3Config() s3_client.ConfigS3 {
 		Bucket:            c.S3Config.BucketName,
 		AccessKeyID:       c.S3Config.AccessKeyID,
 		SecretAccessKey:   c.S3Config.SecretAccessKey,
+		UploadPartSize:    c.S3Config.UploadPartSizeMB * 1024 * 1024,
+		UploadConcurrency: c.S3Config.UploadConcurrency,
 	}
 }
 
@@ -71,6 +76,9 @@ func GetConfigModelTrainer() *ModelTrainerConfig {
 			log.Print(help)
 			log.Fatal(err)
 		}
+		if err := instanceModelTrainer.Validate(); err != nil {
+			log.Fatal(err)
+		}
 		var err error
 		instanceModelTrainer.ModelTrainThresholds, err = parseTrainThresholdConfig(instanceModelTrainer.PathToTrainThresholds)
 		if err != nil {
@@ -81,7 +89,7 @@ func GetConfigModelTrainer() *ModelTrainerConfig {
 	return instanceModelTrainer
 }
 
-func parseTrainThresholdConfig(path string) (map[string]workers.ModelTrainThreshold, error) {
+func parseTrainThresholdConfig(path string) (map[string]data.TrainThreshold, error) {
 	op := "config.parseTrainThresholdConfig"
 	file, err := os.Open(path)
 	if err != nil {
@@ -97,11 +105,16 @@ func parseTrainThresholdConfig(path string) (map[string]workers.ModelTrainThresh
 
 	dec := json.NewDecoder(file)
 
-	trainThresholds := make(map[string]workers.ModelTrainThreshold)
+	trainThresholds := make(map[string]data.TrainThreshold)
 	err = dec.Decode(&trainThresholds)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	for modelType, threshold := range trainThresholds {
+		threshold.ModelType = modelType
+		trainThresholds[modelType] = threshold
+	}
+
 	return trainThresholds, nil
 }
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+)
+
+type ResultsConsumerConfig struct {
+	DBConfig
+	LoggerConfig
+	BrokerConfig
+}
+
+func (c ResultsConsumerConfig) ToDBConfig() postgresql.DBConfig {
+	return postgresql.DBConfig{
+		Port:                  c.DBPort,
+		Host:                  c.DBHost,
+		Name:                  c.DBName,
+		Password:              c.DBPassword,
+		Username:              c.DBUsername,
+		MaxConnectionAttempts: c.MaxConnectionAttempts,
+		AutoMigrate:           c.AutoMigrate,
+		MigrationsDir:         c.MigrationsDir,
+	}
+}
+
+func (c ResultsConsumerConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+var instanceResultsConsumer *ResultsConsumerConfig
+var onceResultsConsumer sync.Once
+
+func GetConfigResultsConsumer() *ResultsConsumerConfig {
+	onceResultsConsumer.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceResultsConsumer = &ResultsConsumerConfig{}
+		if err := cleanenv.ReadEnv(instanceResultsConsumer); err != nil {
+			help, _ := cleanenv.GetDescription(instanceResultsConsumer, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+		if err := instanceResultsConsumer.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	return instanceResultsConsumer
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/workers"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/ilyakaznacheev/cleanenv"
+	"log"
+	"sync"
+	"time"
+)
+
+type WebhookDispatcherConfig struct {
+	DBConfig
+	LoggerConfig
+
+	WebhookTimeout        time.Duration `env:"WEBHOOK_TIMEOUT"  env-default:"10s"`
+	WebhookBatchSize      int           `env:"WEBHOOK_BATCH_SIZE"  env-default:"50"`
+	WebhookPollInterval   time.Duration `env:"WEBHOOK_POLL_INTERVAL"  env-default:"1s"`
+	WebhookMaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS"  env-default:"8"`
+	WebhookRetryBaseDelay time.Duration `env:"WEBHOOK_RETRY_BASE_DELAY"  env-default:"10s"`
+	WebhookRetryMaxDelay  time.Duration `env:"WEBHOOK_RETRY_MAX_DELAY"  env-default:"1h"`
+}
+
+func (c WebhookDispatcherConfig) ToDBConfig() postgresql.DBConfig {
+	return postgresql.DBConfig{
+		Port:                  c.DBPort,
+		Host:                  c.DBHost,
+		Name:                  c.DBName,
+		Password:              c.DBPassword,
+		Username:              c.DBUsername,
+		MaxConnectionAttempts: c.MaxConnectionAttempts,
+		AutoMigrate:           c.AutoMigrate,
+		MigrationsDir:         c.MigrationsDir,
+	}
+}
+
+func (c WebhookDispatcherConfig) ToLoggerConfig() logger.LoggerConfig {
+	return logger.LoggerConfig{
+		IsProduction: c.IsProduction,
+	}
+}
+
+func (c WebhookDispatcherConfig) ToRetryPolicy() workers.WebhookRetryPolicy {
+	return workers.WebhookRetryPolicy{
+		MaxAttempts: c.WebhookMaxAttempts,
+		BaseDelay:   c.WebhookRetryBaseDelay,
+		MaxDelay:    c.WebhookRetryMaxDelay,
+	}
+}
+
+var instanceWebhookDispatcher *WebhookDispatcherConfig
+var onceWebhookDispatcher sync.Once
+
+func GetConfigWebhookDispatcher() *WebhookDispatcherConfig {
+	onceWebhookDispatcher.Do(func() {
+		log.Print("Read application configuration")
+
+		instanceWebhookDispatcher = &WebhookDispatcherConfig{}
+		if err := cleanenv.ReadEnv(instanceWebhookDispatcher); err != nil {
+			help, _ := cleanenv.GetDescription(instanceWebhookDispatcher, nil)
+
+			log.Print(help)
+			log.Fatal(err)
+		}
+	})
+
+	return instanceWebhookDispatcher
+}
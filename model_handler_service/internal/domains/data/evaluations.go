@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	ModelEvaluationsTable = "model_evaluations"
+)
+
+const (
+	// EvaluationStatusPending - сравнение создано и ждет отправки тренеру
+	EvaluationStatusPending   = "pending"
+	EvaluationStatusQueued    = "queued"
+	EvaluationStatusSucceeded = "succeeded"
+	EvaluationStatusFailed    = "failed"
+)
+
+// ModelEvaluation - сравнение двух версий модели пользователя на отложенных видео, не участвовавших в обучении.
+// Baseline - версия, с которой сравнивают (например, модель до дообучения), Candidate - проверяемая версия
+type ModelEvaluation struct {
+	EvaluationID     string          `db:"evaluation_id" json:"evaluation_id"`
+	UserID           string          `db:"user_id" json:"user_id"`
+	ModelType        string          `db:"model_type" json:"model_type"`
+	BaselineS3Key    string          `db:"baseline_s3_key" json:"baseline_s3_key"`
+	CandidateS3Key   string          `db:"candidate_s3_key" json:"candidate_s3_key"`
+	VideoIDs         []string        `db:"video_ids" json:"video_ids"`
+	Status           string          `db:"status" json:"status"`
+	JobID            *string         `db:"job_id" json:"job_id"`
+	BaselineMetrics  json.RawMessage `db:"baseline_metrics" json:"baseline_metrics" swaggertype:"object"`
+	CandidateMetrics json.RawMessage `db:"candidate_metrics" json:"candidate_metrics" swaggertype:"object"`
+	Error            *string         `db:"error" json:"error"`
+	CreatedAt        time.Time       `db:"created_at" json:"created_at"`
+	FinishedAt       *time.Time      `db:"finished_at" json:"finished_at"`
+}
+
+// ModelEvaluationResult - результат задачи сравнения, полученный от тренера
+type ModelEvaluationResult struct {
+	JobID            string
+	Status           string
+	BaselineMetrics  json.RawMessage
+	CandidateMetrics json.RawMessage
+	Error            *string
+}
+
+var modelEvaluationColumns = []string{
+	"evaluation_id",
+	"user_id",
+	"model_type",
+	"baseline_s3_key",
+	"candidate_s3_key",
+	"video_ids",
+	"status",
+	"job_id",
+	"baseline_metrics",
+	"candidate_metrics",
+	"error",
+	"created_at",
+	"finished_at",
+}
+
+func (r *Repository) CreateModelEvaluation(ctx context.Context, evaluation ModelEvaluation) (*ModelEvaluation, error) {
+	op := "data.Repository.CreateModelEvaluation"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Insert(ModelEvaluationsTable).
+		SetMap(sq.Eq{
+			"evaluation_id":    evaluation.EvaluationID,
+			"user_id":          evaluation.UserID,
+			"model_type":       evaluation.ModelType,
+			"baseline_s3_key":  evaluation.BaselineS3Key,
+			"candidate_s3_key": evaluation.CandidateS3Key,
+			"video_ids":        evaluation.VideoIDs,
+		}).
+		Suffix("RETURNING " + strings.Join(modelEvaluationColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res ModelEvaluation
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return nil, app_errors.ErrValidationError.WrapError(op, "unknown model type")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("evaluation_id", evaluation.EvaluationID),
+		zap.String("user_id", evaluation.UserID),
+		zap.String("model_type", evaluation.ModelType),
+	).Info(fmt.Sprintf("%s: create model evaluation", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetModelEvaluation(ctx context.Context, evaluationID string) (*ModelEvaluation, error) {
+	op := "data.Repository.GetModelEvaluation"
+
+	q, i, err := r.queryBuilder.
+		Select(modelEvaluationColumns...).
+		From(ModelEvaluationsTable).
+		Where(sq.Eq{"evaluation_id": evaluationID}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res ModelEvaluation
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+func (r *Repository) GetModelEvaluations(ctx context.Context, userID string, modelType *string) ([]ModelEvaluation, error) {
+	op := "data.Repository.GetModelEvaluations"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(modelEvaluationColumns...).
+		From(ModelEvaluationsTable).
+		Where(sq.Eq{"user_id": userID})
+
+	if modelType != nil {
+		qb = qb.Where(sq.Eq{"model_type": *modelType})
+	}
+
+	q, i, err := qb.
+		OrderBy("created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]ModelEvaluation, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("user_id", userID), zap.Int("count", len(res))).
+		Info(fmt.Sprintf("%s: get model evaluations", op))
+
+	return res, nil
+}
+
+// ViewPendingModelEvaluations - возвращает сравнения, еще не отправленные тренеру, в порядке создания
+func (r *Repository) ViewPendingModelEvaluations(ctx context.Context) ([]ModelEvaluation, error) {
+	op := "data.Repository.ViewPendingModelEvaluations"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Select(modelEvaluationColumns...).
+		From(ModelEvaluationsTable).
+		Where(sq.Eq{"status": EvaluationStatusPending}).
+		OrderBy("created_at").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]ModelEvaluation, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: find pending model evaluations", op))
+
+	return res, nil
+}
+
+// SetModelEvaluationQueued - запоминает задачу, с которой сравнение отправлено тренеру
+func (r *Repository) SetModelEvaluationQueued(ctx context.Context, evaluationID, jobID string) error {
+	op := "data.Repository.SetModelEvaluationQueued"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(ModelEvaluationsTable).
+		Set("status", EvaluationStatusQueued).
+		Set("job_id", jobID).
+		Where(sq.Eq{"evaluation_id": evaluationID}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("evaluation_id", evaluationID), zap.String("job_id", jobID)).
+		Info(fmt.Sprintf("%s: model evaluation sent to trainer", op))
+
+	return nil
+}
+
+// SetModelEvaluationResult - записывает метрики обеих версий модели по задаче сравнения
+func (r *Repository) SetModelEvaluationResult(ctx context.Context, result ModelEvaluationResult) error {
+	op := "data.Repository.SetModelEvaluationResult"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Update(ModelEvaluationsTable).
+		Set("status", result.Status).
+		Set("error", result.Error).
+		Set("finished_at", sq.Expr("now()")).
+		Where(sq.Eq{"job_id": result.JobID})
+
+	if len(result.BaselineMetrics) > 0 {
+		qb = qb.Set("baseline_metrics", result.BaselineMetrics)
+	}
+	if len(result.CandidateMetrics) > 0 {
+		qb = qb.Set("candidate_metrics", result.CandidateMetrics)
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return app_errors.ErrNotFound.WrapError(op, "model evaluation not found")
+	}
+
+	l.With(zap.String("job_id", result.JobID), zap.String("status", result.Status)).
+		Info(fmt.Sprintf("%s: set model evaluation result", op))
+
+	return nil
+}
+
+// GetPreviousModelS3Key - возвращает s3 ключ версии модели, обученной перед версией s3Key, по журналу задач
+func (r *Repository) GetPreviousModelS3Key(ctx context.Context, userID, modelType, s3Key string) (string, error) {
+	op := "data.Repository.GetPreviousModelS3Key"
+
+	q, i, err := r.queryBuilder.
+		Select("s3_key").
+		From(TrainJobsTable).
+		Where(sq.Eq{
+			"user_id":    userID,
+			"model_type": modelType,
+			"status":     JobStatusSucceeded,
+			"job_type":   []string{JobTypeTrain, JobTypeTune},
+		}).
+		Where(sq.NotEq{"s3_key": nil}).
+		Where(sq.NotEq{"s3_key": s3Key}).
+		Where(sq.Expr(`created_at < COALESCE(
+			(SELECT max(created_at) FROM `+TrainJobsTable+` WHERE s3_key = ?), now())`, s3Key)).
+		OrderBy("created_at DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return "", app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res string
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", app_errors.ErrNotFound.WrapError(op, "previous model version not found")
+		}
+		return "", app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
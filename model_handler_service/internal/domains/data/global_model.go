@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GlobalModelUserID - зарезервированный id, под которым в таблице models хранится глобальная модель,
+// обученная на признаках всех пользователей. Выдается пользователям, у которых еще нет своей модели
+const GlobalModelUserID = "00000000-0000-0000-0000-000000000000"
+
+const (
+	// JobScopeGlobal - задача обучения глобальной модели на признаках всех пользователей
+	JobScopeGlobal = "global"
+)
+
+// GetGlobalModel - возвращает глобальную модель определенного типа, создавая ее запись при первом обращении
+func (r *Repository) GetGlobalModel(ctx context.Context, modelType string) (*MLModel, error) {
+	op := "data.Repository.GetGlobalModel"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	// пустое обновление нужно, чтобы RETURNING вернул уже существующую запись
+	q, i, err := r.queryBuilder.
+		Insert(ModelsTable).
+		Columns("user_id", "model_type").
+		Values(GlobalModelUserID, modelType).
+		Suffix(`ON CONFLICT (user_id, model_type) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING user_id, features_count, train_status, s3_key, model_type, last_trained_at`).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res MLModel
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", modelType)).Info(fmt.Sprintf("%s: get global model", op))
+
+	return &res, nil
+}
+
+// GetTotalFeaturesCount - возвращает количество признаков всех пользователей для модели определенного типа
+func (r *Repository) GetTotalFeaturesCount(ctx context.Context, modelType string) (uint64, error) {
+	op := "data.Repository.GetTotalFeaturesCount"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Select("COALESCE(sum(features_count), 0)::BIGINT").
+		From(ModelsTable).
+		Where(sq.Eq{"model_type": modelType}).
+		Where(sq.NotEq{"user_id": GlobalModelUserID}).
+		ToSql()
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res uint64
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", modelType), zap.Uint64("features_count", res)).
+		Info(fmt.Sprintf("%s: get total features count", op))
+
+	return res, nil
+}
+
+// SetGlobalModelFeaturesCount - запоминает количество признаков всех пользователей на момент отправки
+// глобальной модели на обучение, от него отсчитывается прирост признаков для следующего обучения
+func (r *Repository) SetGlobalModelFeaturesCount(ctx context.Context, modelType string, featuresCount uint64) error {
+	op := "data.Repository.SetGlobalModelFeaturesCount"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(ModelsTable).
+		Set("features_count", featuresCount).
+		Where(sq.Eq{"user_id": GlobalModelUserID, "model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", modelType), zap.Uint64("features_count", featuresCount)).
+		Info(fmt.Sprintf("%s: set global model features count", op))
+
+	return nil
+}
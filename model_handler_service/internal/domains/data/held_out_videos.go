@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	HeldOutVideosTable = "held_out_videos"
+)
+
+// HeldOutVideo - видео пользователя, отложенное для сравнения версий модели. Признаки видео не участвуют в обучении
+// моделей, задачи которых созданы после CreatedAt, поэтому на нем объективно сравниваются только такие модели
+type HeldOutVideo struct {
+	UserID    string    `db:"user_id" json:"user_id"`
+	ModelType string    `db:"model_type" json:"model_type"`
+	VideoID   string    `db:"video_id" json:"video_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+var heldOutVideoColumns = []string{
+	"user_id",
+	"model_type",
+	"video_id",
+	"created_at",
+}
+
+// AddHeldOutVideos - откладывает видео пользователя, уже отложенные видео сохраняют прежнее время
+func (r *Repository) AddHeldOutVideos(ctx context.Context, userID, modelType string, videoIDs []string) error {
+	op := "data.Repository.AddHeldOutVideos"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Insert(HeldOutVideosTable).
+		Columns("user_id", "model_type", "video_id")
+	for _, videoID := range videoIDs {
+		qb = qb.Values(userID, modelType, videoID)
+	}
+
+	q, i, err := qb.
+		Suffix("ON CONFLICT (user_id, model_type, video_id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return app_errors.ErrValidationError.WrapError(op, "unknown model type")
+		}
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("user_id", userID),
+		zap.String("model_type", modelType),
+		zap.Int("count", len(videoIDs)),
+	).Info(fmt.Sprintf("%s: add held-out videos", op))
+
+	return nil
+}
+
+// GetHeldOutVideos - возвращает отложенные видео пользователя в порядке их откладывания
+func (r *Repository) GetHeldOutVideos(ctx context.Context, userID, modelType string) ([]HeldOutVideo, error) {
+	op := "data.Repository.GetHeldOutVideos"
+
+	q, i, err := r.queryBuilder.
+		Select(heldOutVideoColumns...).
+		From(HeldOutVideosTable).
+		Where(sq.Eq{"user_id": userID, "model_type": modelType}).
+		OrderBy("created_at", "video_id").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]HeldOutVideo, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+// GetHeldOutVideoIDs - возвращает id отложенных видео пользователя, а при пустом userID - всех пользователей
+// для обучения глобальной модели
+func (r *Repository) GetHeldOutVideoIDs(ctx context.Context, modelType string, userID *string) ([]string, error) {
+	op := "data.Repository.GetHeldOutVideoIDs"
+
+	qb := r.queryBuilder.
+		Select("video_id").
+		From(HeldOutVideosTable).
+		Where(sq.Eq{"model_type": modelType})
+	if userID != nil {
+		qb = qb.Where(sq.Eq{"user_id": *userID})
+	}
+
+	q, i, err := qb.OrderBy("video_id").ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]string, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+// GetModelTrainQueuedAt - возвращает время создания задачи, обучившей версию модели s3Key
+func (r *Repository) GetModelTrainQueuedAt(ctx context.Context, s3Key string) (time.Time, error) {
+	op := "data.Repository.GetModelTrainQueuedAt"
+
+	q, i, err := r.queryBuilder.
+		Select("created_at").
+		From(TrainJobsTable).
+		Where(sq.Eq{
+			"s3_key":   s3Key,
+			"status":   JobStatusSucceeded,
+			"job_type": []string{JobTypeTrain, JobTypeTune},
+		}).
+		OrderBy("created_at").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return time.Time{}, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res time.Time
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, app_errors.ErrNotFound.WrapError(op, fmt.Sprintf("train job of model %s not found", s3Key))
+		}
+		return time.Time{}, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
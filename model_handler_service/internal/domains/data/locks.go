@@ -0,0 +1,23 @@
+package data
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+)
+
+// TrainDispatchLockKey - ключ advisory-блокировки рассылки задач обучения
+const TrainDispatchLockKey int64 = 7_203_915_001
+
+// TryLockTrainDispatch - пытается взять advisory-блокировку рассылки задач обучения до конца текущей транзакции.
+// Возвращает false, если блокировку удерживает другая реплика
+func (r *Repository) TryLockTrainDispatch(ctx context.Context) (bool, error) {
+	op := "data.Repository.TryLockTrainDispatch"
+
+	var locked bool
+	err := r.db.Client(ctx).ExecQueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", TrainDispatchLockKey).Scan(&locked)
+	if err != nil {
+		return false, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return locked, nil
+}
@@ -1,9 +1,14 @@
 package data
 
+import "time"
+
 type MLModel struct {
 	UserID           string  `db:"user_id"`
 	ModelFeatures    uint64  `db:"features_count"`
 	ModelTrainStatus string  `db:"train_status"`
 	ModelType        string  `db:"model_type"`
 	S3Key            *string `db:"s3_key"`
+	// Checksum - SHA-256 файла модели в hex, nil для моделей, сохраненных до появления контрольных сумм
+	Checksum      *string    `db:"s3_checksum"`
+	LastTrainedAt *time.Time `db:"last_trained_at"`
 }
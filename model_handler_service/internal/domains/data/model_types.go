@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	ModelTypesTable = "model_types"
+)
+
+// foreignKeyViolationCode - код ошибки Postgres при нарушении внешнего ключа
+const foreignKeyViolationCode = "23503"
+
+// FeatureColumn - признак, который клиент передает для обучения модели
+type FeatureColumn struct {
+	Name string `json:"name" validate:"required,max=63"`
+	Type string `json:"type" validate:"required,oneof=int float"`
+}
+
+// ModelType - тип модели из справочника вместе со схемой его признаков. Справочник - единственное место,
+// где изменяются типы и схемы: схема применяется к таблицам признаков face_features_storage (/schemas/{model_type})
+// и сохраняется здесь в том виде, в котором ее вернул face_features_storage
+type ModelType struct {
+	ModelType      string          `db:"model_type" json:"model_type"`
+	Description    string          `db:"description" json:"description"`
+	FeaturesSchema []FeatureColumn `db:"features_schema" json:"features_schema"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
+
+var modelTypeColumns = []string{
+	"model_type",
+	"description",
+	"features_schema",
+	"created_at",
+}
+
+func (r *Repository) GetModelTypes(ctx context.Context) ([]ModelType, error) {
+	op := "data.Repository.GetModelTypes"
+
+	q, i, err := r.queryBuilder.
+		Select(modelTypeColumns...).
+		From(ModelTypesTable).
+		OrderBy("model_type").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]ModelType, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+func (r *Repository) GetModelType(ctx context.Context, modelType string) (*ModelType, error) {
+	op := "data.Repository.GetModelType"
+
+	q, i, err := r.queryBuilder.
+		Select(modelTypeColumns...).
+		From(ModelTypesTable).
+		Where(sq.Eq{"model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res ModelType
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+// CreateModelType - добавляет тип модели в справочник. Если тип уже есть, возвращает ErrAlreadyExists
+func (r *Repository) CreateModelType(ctx context.Context, modelType ModelType) (*ModelType, error) {
+	op := "data.Repository.CreateModelType"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Insert(ModelTypesTable).
+		Columns("model_type", "description", "features_schema").
+		Values(modelType.ModelType, modelType.Description, modelType.FeaturesSchema).
+		Suffix("ON CONFLICT (model_type) DO NOTHING RETURNING " + strings.Join(modelTypeColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res ModelType
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrAlreadyExists.WrapError(op, "model type already exists")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", modelType.ModelType)).Info(fmt.Sprintf("%s: create model type", op))
+
+	return &res, nil
+}
+
+// SetModelTypeFeaturesSchema - заменяет схему признаков типа модели
+func (r *Repository) SetModelTypeFeaturesSchema(ctx context.Context, modelType string, featuresSchema []FeatureColumn) (*ModelType, error) {
+	op := "data.Repository.SetModelTypeFeaturesSchema"
+
+	q, i, err := r.queryBuilder.
+		Update(ModelTypesTable).
+		Set("features_schema", featuresSchema).
+		Where(sq.Eq{"model_type": modelType}).
+		Suffix("RETURNING " + strings.Join(modelTypeColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res ModelType
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, "model type not found")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+// DeleteModelType - удаляет тип модели из справочника. Тип, на который ссылаются модели или пороги, удалить нельзя
+func (r *Repository) DeleteModelType(ctx context.Context, modelType string) error {
+	op := "data.Repository.DeleteModelType"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Delete(ModelTypesTable).
+		Where(sq.Eq{"model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return app_errors.ErrValidationError.WrapError(op, "model type is in use")
+		}
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return app_errors.ErrNotFound.WrapError(op, "model type not found")
+	}
+
+	l.With(zap.String("model_type", modelType)).Info(fmt.Sprintf("%s: delete model type", op))
+
+	return nil
+}
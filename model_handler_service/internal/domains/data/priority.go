@@ -0,0 +1,72 @@
+package data
+
+import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"time"
+)
+
+// PriorityRules - правила расчета приоритета задач обучения для моделей одного типа
+type PriorityRules struct {
+	// Train - приоритет первого обучения модели нового пользователя
+	Train uint8 `json:"train"`
+	// Tune - базовый приоритет дообучения
+	Tune uint8 `json:"tune"`
+	// StalenessStepHours - каждые StalenessStepHours часов с последнего обучения повышают приоритет дообучения на 1
+	StalenessStepHours uint64 `json:"staleness_step_hours"`
+	// MaxStalenessBoost - максимальное повышение приоритета дообучения за давность обучения
+	MaxStalenessBoost uint8 `json:"max_staleness_boost"`
+}
+
+// Validate - проверяет, что базовые приоритеты не превышают x-max-priority очередей.
+// Нулевой maxPriority означает очереди без приоритетов, и приоритеты не ограничиваются
+func (p PriorityRules) Validate(maxPriority uint8) error {
+	op := "data.PriorityRules.Validate"
+
+	if maxPriority == 0 {
+		return nil
+	}
+	if p.Train > maxPriority {
+		return app_errors.ErrValidationError.WrapError(op,
+			fmt.Sprintf("train priority %d exceeds max priority %d", p.Train, maxPriority))
+	}
+	if p.Tune > maxPriority {
+		return app_errors.ErrValidationError.WrapError(op,
+			fmt.Sprintf("tune priority %d exceeds max priority %d", p.Tune, maxPriority))
+	}
+
+	return nil
+}
+
+// TrainPriority - приоритет задачи первого обучения, не выше maxPriority
+func (p PriorityRules) TrainPriority(maxPriority uint8) uint8 {
+	return clampPriority(uint64(p.Train), maxPriority)
+}
+
+// TunePriority - приоритет задачи дообучения с учетом давности последнего обучения, не выше maxPriority:
+// брокер все равно не различает приоритеты выше x-max-priority очереди
+func (p PriorityRules) TunePriority(lastTrainedAt *time.Time, now time.Time, maxPriority uint8) uint8 {
+	if lastTrainedAt == nil || p.StalenessStepHours == 0 {
+		return clampPriority(uint64(p.Tune), maxPriority)
+	}
+
+	boost := uint64(now.Sub(*lastTrainedAt).Hours()) / p.StalenessStepHours
+	if boost > uint64(p.MaxStalenessBoost) {
+		boost = uint64(p.MaxStalenessBoost)
+	}
+
+	// складываем в uint64, чтобы сумма не переполнила uint8
+	return clampPriority(uint64(p.Tune)+boost, maxPriority)
+}
+
+// clampPriority - ограничивает приоритет значением maxPriority, нулевой maxPriority ограничивает только размером uint8
+func clampPriority(priority uint64, maxPriority uint8) uint8 {
+	limit := uint64(maxPriority)
+	if limit == 0 {
+		limit = 255
+	}
+	if priority > limit {
+		return uint8(limit)
+	}
+	return uint8(priority)
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTunePriorityIsClampedToMaxPriority(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-1000 * time.Hour)
+
+	tests := []struct {
+		name          string
+		rules         PriorityRules
+		lastTrainedAt *time.Time
+		maxPriority   uint8
+		want          uint8
+	}{
+		{
+			name:        "never trained",
+			rules:       PriorityRules{Tune: 3, StalenessStepHours: 1, MaxStalenessBoost: 5},
+			maxPriority: 10,
+			want:        3,
+		},
+		{
+			name:          "boost within max priority",
+			rules:         PriorityRules{Tune: 3, StalenessStepHours: 1, MaxStalenessBoost: 5},
+			lastTrainedAt: &longAgo,
+			maxPriority:   10,
+			want:          8,
+		},
+		{
+			name:          "boost above max priority",
+			rules:         PriorityRules{Tune: 8, StalenessStepHours: 1, MaxStalenessBoost: 5},
+			lastTrainedAt: &longAgo,
+			maxPriority:   10,
+			want:          10,
+		},
+		{
+			name:          "sum does not wrap around uint8",
+			rules:         PriorityRules{Tune: 250, StalenessStepHours: 1, MaxStalenessBoost: 200},
+			lastTrainedAt: &longAgo,
+			maxPriority:   0,
+			want:          255,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.TunePriority(tt.lastTrainedAt, now, tt.maxPriority)
+			if got != tt.want {
+				t.Errorf("TunePriority() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityRulesValidate(t *testing.T) {
+	if err := (PriorityRules{Train: 10, Tune: 5, MaxStalenessBoost: 20}).Validate(10); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	if err := (PriorityRules{Train: 11}).Validate(10); err == nil {
+		t.Error("Validate() = nil for train priority above max")
+	}
+	if err := (PriorityRules{Tune: 11}).Validate(10); err == nil {
+		t.Error("Validate() = nil for tune priority above max")
+	}
+	if err := (PriorityRules{Train: 200, Tune: 200}).Validate(0); err != nil {
+		t.Errorf("Validate() = %v, want nil for queues without priorities", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
+	"time"
 )
 
 const (
@@ -23,10 +24,6 @@ const (
 	StatusTrained        = "train"
 )
 
-const (
-	FaceModel = "face_model"
-)
-
 type Repository struct {
 	db           postgresql.DB
 	queryBuilder sq.StatementBuilderType
@@ -123,6 +120,31 @@ func (r *Repository) SetModelS3Key(ctx context.Context, s3Key, modelType, userID
 	return nil
 }
 
+// SetModelChecksum - задает SHA-256 файла модели, сохраненного по текущему s3 ключу
+func (r *Repository) SetModelChecksum(ctx context.Context, checksum, modelType, userID string) error {
+	op := "data.Repository.SetModelChecksum"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(ModelsTable).
+		Set("s3_checksum", checksum).
+		Where(sq.Eq{"user_id": userID, "model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("s3_checksum", checksum), zap.String("user_id", userID), zap.String("model_type", modelType)).
+		Info(fmt.Sprintf("%s: set model checksum", op))
+
+	return nil
+}
+
 func (r *Repository) CreateModel(ctx context.Context, userID, modelType string) error {
 	op := "data.Repository.CreateModel"
 	l := logger.EntryWithRequestIDFromContext(ctx)
@@ -164,6 +186,8 @@ func (r *Repository) GetModelByUserID(ctx context.Context, userID, modelType str
 			"train_status",
 			"s3_key",
 			"model_type",
+			"last_trained_at",
+			"s3_checksum",
 		).
 		From(ModelsTable).
 		Where(sq.Eq{"user_id": userID, "model_type": modelType}).
@@ -200,6 +224,8 @@ func (r *Repository) GetModelsByUserID(ctx context.Context, userID string) ([]ML
 			"train_status",
 			"s3_key",
 			"model_type",
+			"last_trained_at",
+			"s3_checksum",
 		).
 		From(ModelsTable).
 		Where(sq.Eq{"user_id": userID}).
@@ -230,12 +256,18 @@ func (r *Repository) ViewNotLearnedModels(ctx context.Context, modelType string,
 			"train_status",
 			"s3_key",
 			"model_type",
+			"last_trained_at",
 		).
 		From(ModelsTable).
-		Where(
-			sq.Eq{"train_status": StatusNotTrain, "model_type": modelType},
-		).
-		Where(sq.GtOrEq{"features_count": trainThreshold}).
+		Where(sq.Eq{"model_type": modelType}).
+		Where(sq.NotEq{"user_id": GlobalModelUserID}).
+		Where(sq.Or{
+			sq.And{
+				sq.Eq{"train_status": StatusNotTrain},
+				sq.GtOrEq{"features_count": trainThreshold},
+			},
+			sq.Eq{"train_status": StatusTrained, "needs_retrain": true},
+		}).
 		ToSql()
 	if err != nil {
 		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
@@ -263,6 +295,7 @@ func (r *Repository) ViewNotFineTunedFaceModels(ctx context.Context, modelType s
 			"train_status",
 			"s3_key",
 			"model_type",
+			"last_trained_at",
 		).
 		From(ModelsTable).
 		Where(
@@ -270,6 +303,7 @@ func (r *Repository) ViewNotFineTunedFaceModels(ctx context.Context, modelType s
 				"model_type": modelType,
 			},
 		).
+		Where(sq.NotEq{"user_id": GlobalModelUserID}).
 		Where(sq.GtOrEq{"features_count - features_count_used": tuneThreshold}).
 		ToSql()
 	if err != nil {
@@ -312,3 +346,88 @@ func (r *Repository) SetModelStatus(ctx context.Context, status, modelType strin
 
 	return nil
 }
+
+// SetLastTrainedAt - задает время последнего успешного обучения модели
+func (r *Repository) SetLastTrainedAt(ctx context.Context, userID, modelType string, trainedAt time.Time) error {
+	op := "data.Repository.SetLastTrainedAt"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(ModelsTable).
+		Set("last_trained_at", trainedAt).
+		Where(sq.Eq{"user_id": userID, "model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("user_id", userID),
+		zap.String("model_type", modelType),
+		zap.Time("last_trained_at", trainedAt),
+	).Info(fmt.Sprintf("%s: set last_trained_at to model", op))
+
+	return nil
+}
+
+// SetNeedsRetrain - задает флаг необходимости переобучения модели с нуля
+func (r *Repository) SetNeedsRetrain(ctx context.Context, userID, modelType string, needsRetrain bool) error {
+	op := "data.Repository.SetNeedsRetrain"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(ModelsTable).
+		Set("needs_retrain", needsRetrain).
+		Where(sq.Eq{"user_id": userID, "model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("user_id", userID),
+		zap.String("model_type", modelType),
+		zap.Bool("needs_retrain", needsRetrain),
+	).Info(fmt.Sprintf("%s: set needs_retrain to model", op))
+
+	return nil
+}
+
+// ViewUntrainedUserIDs - возвращает пользователей, модели которых определенного типа еще ни разу не были обучены
+func (r *Repository) ViewUntrainedUserIDs(ctx context.Context, modelType string) ([]string, error) {
+	op := "data.Repository.ViewUntrainedUserIDs"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Select("user_id").
+		From(ModelsTable).
+		Where(sq.Eq{
+			"model_type":   modelType,
+			"train_status": []string{StatusNotTrain, StatusInTrainProcess},
+		}).
+		Where(sq.NotEq{"user_id": GlobalModelUserID}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]string, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: find untrained users", op))
+
+	return res, nil
+}
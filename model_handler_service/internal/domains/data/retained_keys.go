@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetRetainedS3Keys - возвращает s3 ключи, которые нельзя удалять: текущие модели пользователей и глобальные модели,
+// keepVersions последних успешно обученных версий каждой модели по журналу задач
+// и версии, участвующие в еще не выполненных сравнениях
+func (r *Repository) GetRetainedS3Keys(ctx context.Context, keepVersions uint64) (map[string]struct{}, error) {
+	op := "data.Repository.GetRetainedS3Keys"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	versions := r.queryBuilder.
+		Select("s3_key", "row_number() OVER (PARTITION BY user_id, model_type ORDER BY created_at DESC) AS version").
+		From(TrainJobsTable).
+		Where(sq.Eq{
+			"status":   JobStatusSucceeded,
+			"job_type": []string{JobTypeTrain, JobTypeTune},
+		}).
+		Where(sq.NotEq{"s3_key": nil})
+
+	queries := []sq.SelectBuilder{
+		r.queryBuilder.
+			Select("s3_key").
+			From(ModelsTable).
+			Where(sq.NotEq{"s3_key": nil}),
+		r.queryBuilder.
+			Select("s3_key").
+			FromSelect(versions, "versions").
+			Where(sq.LtOrEq{"version": keepVersions}),
+		r.queryBuilder.
+			Select("baseline_s3_key").
+			From(ModelEvaluationsTable).
+			Where(sq.Eq{"status": []string{EvaluationStatusPending, EvaluationStatusQueued}}),
+		r.queryBuilder.
+			Select("candidate_s3_key").
+			From(ModelEvaluationsTable).
+			Where(sq.Eq{"status": []string{EvaluationStatusPending, EvaluationStatusQueued}}),
+	}
+
+	res := make(map[string]struct{})
+	for _, qb := range queries {
+		q, i, err := qb.ToSql()
+		if err != nil {
+			return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+		}
+
+		keys := make([]string, 0)
+		err = r.db.Client(ctx).Select(ctx, &keys, q, i...)
+		if err != nil {
+			return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+		}
+
+		for _, key := range keys {
+			res[key] = struct{}{}
+		}
+	}
+
+	l.With(zap.Int("count", len(res))).Info(fmt.Sprintf("%s: get retained s3 keys", op))
+
+	return res, nil
+}
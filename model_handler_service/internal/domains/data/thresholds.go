@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	TrainThresholdsTable = "train_thresholds"
+)
+
+// TrainThreshold - пороги количества признаков для обучения и дообучения моделей одного типа
+type TrainThreshold struct {
+	ModelType      string        `db:"model_type" json:"model_type"`
+	TrainThreshold uint64        `db:"train_threshold" json:"train_threshold"`
+	TuneThreshold  uint64        `db:"tune_threshold" json:"tune_threshold"`
+	Priority       PriorityRules `db:"priority" json:"priority"`
+	// WarmStart - первое обучение модели пользователя начинается с глобальной модели, а не с нуля
+	WarmStart bool      `db:"warm_start" json:"warm_start"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+var trainThresholdColumns = []string{
+	"model_type",
+	"train_threshold",
+	"tune_threshold",
+	"priority",
+	"warm_start",
+	"updated_at",
+}
+
+func (r *Repository) GetTrainThresholds(ctx context.Context) ([]TrainThreshold, error) {
+	op := "data.Repository.GetTrainThresholds"
+
+	q, i, err := r.queryBuilder.
+		Select(trainThresholdColumns...).
+		From(TrainThresholdsTable).
+		OrderBy("model_type").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]TrainThreshold, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+func (r *Repository) GetTrainThreshold(ctx context.Context, modelType string) (*TrainThreshold, error) {
+	op := "data.Repository.GetTrainThreshold"
+
+	q, i, err := r.queryBuilder.
+		Select(trainThresholdColumns...).
+		From(TrainThresholdsTable).
+		Where(sq.Eq{"model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res TrainThreshold
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return &res, nil
+}
+
+// CreateTrainThreshold - добавляет пороги для нового типа модели. Если пороги уже заданы, возвращает ErrAlreadyExists
+func (r *Repository) CreateTrainThreshold(ctx context.Context, threshold TrainThreshold) (*TrainThreshold, error) {
+	op := "data.Repository.CreateTrainThreshold"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Insert(TrainThresholdsTable).
+		Columns("model_type", "train_threshold", "tune_threshold", "priority", "warm_start").
+		Values(threshold.ModelType, threshold.TrainThreshold, threshold.TuneThreshold, threshold.Priority, threshold.WarmStart).
+		Suffix("ON CONFLICT (model_type) DO NOTHING RETURNING " + strings.Join(trainThresholdColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res TrainThreshold
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrAlreadyExists.WrapError(op, "train threshold already exists")
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return nil, app_errors.ErrValidationError.WrapError(op, "unknown model type")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("model_type", threshold.ModelType)).Info(fmt.Sprintf("%s: create train threshold", op))
+
+	return &res, nil
+}
+
+func (r *Repository) UpdateTrainThreshold(ctx context.Context, threshold TrainThreshold) (*TrainThreshold, error) {
+	op := "data.Repository.UpdateTrainThreshold"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(TrainThresholdsTable).
+		Set("train_threshold", threshold.TrainThreshold).
+		Set("tune_threshold", threshold.TuneThreshold).
+		Set("priority", threshold.Priority).
+		Set("warm_start", threshold.WarmStart).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"model_type": threshold.ModelType}).
+		Suffix("RETURNING " + strings.Join(trainThresholdColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res TrainThreshold
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("model_type", threshold.ModelType),
+		zap.Uint64("train_threshold", threshold.TrainThreshold),
+		zap.Uint64("tune_threshold", threshold.TuneThreshold),
+		zap.Bool("warm_start", threshold.WarmStart),
+	).Info(fmt.Sprintf("%s: update train threshold", op))
+
+	return &res, nil
+}
+
+func (r *Repository) DeleteTrainThreshold(ctx context.Context, modelType string) error {
+	op := "data.Repository.DeleteTrainThreshold"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Delete(TrainThresholdsTable).
+		Where(sq.Eq{"model_type": modelType}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return app_errors.ErrNotFound.WrapError(op, "train threshold not found")
+	}
+
+	l.With(zap.String("model_type", modelType)).Info(fmt.Sprintf("%s: delete train threshold", op))
+
+	return nil
+}
+
+// SeedTrainThresholds - добавляет пороги из файла конфигурации для типов моделей, у которых их еще нет.
+// Пороги, измененные через API, не перезаписываются
+func (r *Repository) SeedTrainThresholds(ctx context.Context, thresholds []TrainThreshold) error {
+	op := "data.Repository.SeedTrainThresholds"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	qb := r.queryBuilder.
+		Insert(TrainThresholdsTable).
+		Columns("model_type", "train_threshold", "tune_threshold", "priority", "warm_start")
+	for _, threshold := range thresholds {
+		qb = qb.Values(threshold.ModelType, threshold.TrainThreshold, threshold.TuneThreshold, threshold.Priority, threshold.WarmStart)
+	}
+
+	q, i, err := qb.
+		Suffix("ON CONFLICT (model_type) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int64("count", tag.RowsAffected())).Info(fmt.Sprintf("%s: seed train thresholds", op))
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	TrainJobsTable = "train_jobs"
+)
+
+const (
+	JobTypeTrain    = "train"
+	JobTypeTune     = "tune"
+	JobTypeEvaluate = "evaluate"
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRetrying  = "retrying"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// TrainJob - запись журнала задач обучения моделей
+type TrainJob struct {
+	JobID      string          `db:"job_id" json:"job_id"`
+	UserID     string          `db:"user_id" json:"user_id"`
+	ModelType  string          `db:"model_type" json:"model_type"`
+	JobType    string          `db:"job_type" json:"job_type"`
+	Priority   int             `db:"priority" json:"priority"`
+	Status     string          `db:"status" json:"status"`
+	Attempts   int             `db:"attempts" json:"attempts"`
+	Error      *string         `db:"error" json:"error"`
+	S3Key      *string         `db:"s3_key" json:"s3_key"`
+	Metrics    json.RawMessage `db:"metrics" json:"metrics" swaggertype:"object"`
+	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+	FinishedAt *time.Time      `db:"finished_at" json:"finished_at"`
+}
+
+var trainJobColumns = []string{
+	"job_id",
+	"user_id",
+	"model_type",
+	"job_type",
+	"priority",
+	"status",
+	"attempts",
+	"error",
+	"s3_key",
+	"metrics",
+	"created_at",
+	"finished_at",
+}
+
+func (r *Repository) CreateTrainJob(ctx context.Context, job TrainJob) error {
+	op := "data.Repository.CreateTrainJob"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	setMap := sq.Eq{
+		"job_id":     job.JobID,
+		"user_id":    job.UserID,
+		"model_type": job.ModelType,
+		"job_type":   job.JobType,
+		"priority":   job.Priority,
+	}
+
+	q, i, err := r.queryBuilder.
+		Insert(TrainJobsTable).
+		SetMap(setMap).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("job_id", job.JobID),
+		zap.String("user_id", job.UserID),
+		zap.String("job_type", job.JobType),
+	).Info(fmt.Sprintf("%s: create train job", op))
+
+	return nil
+}
+
+// UpdateTrainJob - записывает в журнал результат выполнения задачи и возвращает обновленную задачу.
+// Для завершенных задач дополнительно проставляется время завершения
+func (r *Repository) UpdateTrainJob(ctx context.Context, job TrainJob) (*TrainJob, error) {
+	op := "data.Repository.UpdateTrainJob"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Update(TrainJobsTable).
+		Set("status", job.Status).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("error", job.Error).
+		Where(sq.Eq{"job_id": job.JobID})
+
+	if job.S3Key != nil {
+		qb = qb.Set("s3_key", job.S3Key)
+	}
+	if len(job.Metrics) > 0 {
+		qb = qb.Set("metrics", job.Metrics)
+	}
+	if job.Status == JobStatusSucceeded || job.Status == JobStatusFailed {
+		qb = qb.Set("finished_at", sq.Expr("now()"))
+	}
+
+	q, i, err := qb.
+		Suffix("RETURNING " + strings.Join(trainJobColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res TrainJob
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, err.Error())
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("job_id", job.JobID), zap.String("status", job.Status)).
+		Info(fmt.Sprintf("%s: update train job", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetTrainJobs(ctx context.Context, userID string, modelType *string) ([]TrainJob, error) {
+	op := "data.Repository.GetTrainJobs"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	qb := r.queryBuilder.
+		Select(trainJobColumns...).
+		From(TrainJobsTable).
+		Where(sq.Eq{"user_id": userID})
+
+	if modelType != nil {
+		qb = qb.Where(sq.Eq{"model_type": *modelType})
+	}
+
+	q, i, err := qb.
+		OrderBy("created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]TrainJob, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("user_id", userID), zap.Int("count", len(res))).
+		Info(fmt.Sprintf("%s: get train jobs", op))
+
+	return res, nil
+}
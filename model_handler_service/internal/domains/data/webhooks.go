@@ -0,0 +1,476 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	OrganizationUsersTable = "organization_users"
+	WebhookEndpointsTable  = "webhook_endpoints"
+	WebhookDeliveriesTable = "webhook_deliveries"
+)
+
+// Типы событий, на которые можно подписать вебхук
+const (
+	WebhookEventModelTrained   = "model.trained"
+	WebhookEventTrainingFailed = "model.training_failed"
+	WebhookEventFatigueAlert   = "fatigue.alert"
+)
+
+var WebhookEventTypes = []string{
+	WebhookEventModelTrained,
+	WebhookEventTrainingFailed,
+	WebhookEventFatigueAlert,
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+// enqueueWebhookQuery - создает доставку события во все активные вебхуки организации пользователя,
+// подписанные на тип события
+const enqueueWebhookQuery = `
+INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+SELECT e.endpoint_id, $1, $2::varchar, $3::jsonb
+FROM webhook_endpoints e
+JOIN organization_users ou ON ou.organization_id = e.organization_id
+WHERE ou.user_id = $4 AND e.is_active AND $2::varchar = ANY(e.event_types)`
+
+// claimWebhookDeliveriesQuery - забирает доставки, время попытки которых наступило, пропуская строки,
+// заблокированные другими диспетчерами. До окончания аренды доставка скрыта от остальных диспетчеров,
+// а если диспетчер упал, не записав результат, она будет отправлена повторно
+const claimWebhookDeliveriesQuery = `
+UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $1), attempts = d.attempts + 1
+FROM webhook_endpoints e
+WHERE e.endpoint_id = d.endpoint_id AND d.delivery_id IN (
+    SELECT delivery_id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.delivery_id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.attempts, e.url, e.secret`
+
+// WebhookEndpoint - адрес организации, на который отправляются события. Secret используется для подписи тела запроса
+type WebhookEndpoint struct {
+	EndpointID     string    `db:"endpoint_id" json:"endpoint_id"`
+	OrganizationID string    `db:"organization_id" json:"organization_id"`
+	URL            string    `db:"url" json:"url"`
+	Secret         string    `db:"secret" json:"-"`
+	EventTypes     []string  `db:"event_types" json:"event_types"`
+	IsActive       bool      `db:"is_active" json:"is_active"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// WebhookEvent - событие, которое отправляется подписчикам. Сериализованное событие является телом запроса вебхука
+type WebhookEvent struct {
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	UserID     string    `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// ModelTrainedEventData - данные события model.trained
+type ModelTrainedEventData struct {
+	ModelType     string `json:"model_type"`
+	S3Key         string `json:"s3_key"`
+	FeaturesCount int    `json:"features_count"`
+}
+
+// TrainingFailedEventData - данные события model.training_failed
+type TrainingFailedEventData struct {
+	ModelType string  `json:"model_type"`
+	JobID     string  `json:"job_id"`
+	Error     *string `json:"error"`
+}
+
+func NewWebhookEvent(eventType, userID string, eventData any) WebhookEvent {
+	return WebhookEvent{
+		EventID:    uuid.New().String(),
+		EventType:  eventType,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC(),
+		Data:       eventData,
+	}
+}
+
+// WebhookDelivery - запись журнала доставки события в вебхук
+type WebhookDelivery struct {
+	DeliveryID     int64           `db:"delivery_id" json:"delivery_id"`
+	EndpointID     string          `db:"endpoint_id" json:"endpoint_id"`
+	EventID        string          `db:"event_id" json:"event_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload" swaggertype:"object"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+}
+
+// ClaimedWebhookDelivery - доставка, захваченная диспетчером, вместе с адресом и секретом вебхука
+type ClaimedWebhookDelivery struct {
+	DeliveryID int64           `db:"delivery_id"`
+	EndpointID string          `db:"endpoint_id"`
+	EventID    string          `db:"event_id"`
+	EventType  string          `db:"event_type"`
+	Payload    json.RawMessage `db:"payload"`
+	Attempts   int             `db:"attempts"`
+	URL        string          `db:"url"`
+	Secret     string          `db:"secret"`
+}
+
+// WebhookDeliveryResult - результат попытки доставки. NextAttemptAt задается для статуса pending
+type WebhookDeliveryResult struct {
+	DeliveryID    int64
+	Status        string
+	StatusCode    *int
+	Error         *string
+	NextAttemptAt *time.Time
+}
+
+// WebhookDeliveriesFilter - фильтр журнала доставок, nil поля не участвуют в фильтрации
+type WebhookDeliveriesFilter struct {
+	EndpointID *string
+	EventID    *string
+	Status     *string
+}
+
+var webhookEndpointColumns = []string{
+	"endpoint_id",
+	"organization_id",
+	"url",
+	"secret",
+	"event_types",
+	"is_active",
+	"created_at",
+}
+
+var webhookDeliveryColumns = []string{
+	"delivery_id",
+	"endpoint_id",
+	"event_id",
+	"event_type",
+	"payload",
+	"status",
+	"attempts",
+	"next_attempt_at",
+	"last_status_code",
+	"last_error",
+	"created_at",
+	"delivered_at",
+}
+
+// SetUserOrganization - привязывает пользователя к организации, события пользователя уходят в вебхуки этой организации
+func (r *Repository) SetUserOrganization(ctx context.Context, userID, organizationID string) error {
+	op := "data.Repository.SetUserOrganization"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Insert(OrganizationUsersTable).
+		Columns("user_id", "organization_id").
+		Values(userID, organizationID).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET organization_id = EXCLUDED.organization_id").
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("user_id", userID), zap.String("organization_id", organizationID)).
+		Info(fmt.Sprintf("%s: set user organization", op))
+
+	return nil
+}
+
+func (r *Repository) DeleteUserOrganization(ctx context.Context, userID string) error {
+	op := "data.Repository.DeleteUserOrganization"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Delete(OrganizationUsersTable).
+		Where(sq.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return app_errors.ErrNotFound.WrapError(op, "user is not in organization")
+	}
+
+	l.With(zap.String("user_id", userID)).Info(fmt.Sprintf("%s: delete user organization", op))
+
+	return nil
+}
+
+func (r *Repository) CreateWebhookEndpoint(ctx context.Context, endpoint WebhookEndpoint) (*WebhookEndpoint, error) {
+	op := "data.Repository.CreateWebhookEndpoint"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Insert(WebhookEndpointsTable).
+		Columns("endpoint_id", "organization_id", "url", "secret", "event_types").
+		Values(endpoint.EndpointID, endpoint.OrganizationID, endpoint.URL, endpoint.Secret, endpoint.EventTypes).
+		Suffix("RETURNING " + strings.Join(webhookEndpointColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res WebhookEndpoint
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("endpoint_id", res.EndpointID), zap.String("organization_id", res.OrganizationID)).
+		Info(fmt.Sprintf("%s: create webhook endpoint", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetWebhookEndpoints(ctx context.Context, organizationID *string) ([]WebhookEndpoint, error) {
+	op := "data.Repository.GetWebhookEndpoints"
+
+	qb := r.queryBuilder.
+		Select(webhookEndpointColumns...).
+		From(WebhookEndpointsTable)
+
+	if organizationID != nil {
+		qb = qb.Where(sq.Eq{"organization_id": *organizationID})
+	}
+
+	q, i, err := qb.
+		OrderBy("created_at").
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]WebhookEndpoint, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+// SetWebhookEndpointActive - приостанавливает или возобновляет отправку событий в вебхук
+func (r *Repository) SetWebhookEndpointActive(ctx context.Context, endpointID string, isActive bool) (*WebhookEndpoint, error) {
+	op := "data.Repository.SetWebhookEndpointActive"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(WebhookEndpointsTable).
+		Set("is_active", isActive).
+		Where(sq.Eq{"endpoint_id": endpointID}).
+		Suffix("RETURNING " + strings.Join(webhookEndpointColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res WebhookEndpoint
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, "webhook endpoint not found")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.String("endpoint_id", endpointID), zap.Bool("is_active", isActive)).
+		Info(fmt.Sprintf("%s: set webhook endpoint active", op))
+
+	return &res, nil
+}
+
+// DeleteWebhookEndpoint - удаляет вебхук вместе с журналом его доставок
+func (r *Repository) DeleteWebhookEndpoint(ctx context.Context, endpointID string) error {
+	op := "data.Repository.DeleteWebhookEndpoint"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Delete(WebhookEndpointsTable).
+		Where(sq.Eq{"endpoint_id": endpointID}).
+		ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return app_errors.ErrNotFound.WrapError(op, "webhook endpoint not found")
+	}
+
+	l.With(zap.String("endpoint_id", endpointID)).Info(fmt.Sprintf("%s: delete webhook endpoint", op))
+
+	return nil
+}
+
+// EnqueueWebhookEvent - ставит событие в очередь доставки подписанным вебхукам и возвращает количество доставок.
+// Вызывается в транзакции изменения, породившего событие, поэтому событие не теряется и не отправляется при откате
+func (r *Repository) EnqueueWebhookEvent(ctx context.Context, event WebhookEvent) (int64, error) {
+	op := "data.Repository.EnqueueWebhookEvent"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := r.db.Client(ctx).Exec(ctx, enqueueWebhookQuery, event.EventID, event.EventType, payload, event.UserID)
+	if err != nil {
+		return 0, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(
+		zap.String("event_id", event.EventID),
+		zap.String("event_type", event.EventType),
+		zap.String("user_id", event.UserID),
+		zap.Int64("deliveries", tag.RowsAffected()),
+	).Info(fmt.Sprintf("%s: enqueue webhook event", op))
+
+	return tag.RowsAffected(), nil
+}
+
+// ClaimWebhookDeliveries - захватывает до limit доставок на время lease
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]ClaimedWebhookDelivery, error) {
+	op := "data.Repository.ClaimWebhookDeliveries"
+
+	res := make([]ClaimedWebhookDelivery, 0)
+	err := r.db.Client(ctx).Select(ctx, &res, claimWebhookDeliveriesQuery, lease.Seconds(), limit)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
+
+// SetWebhookDeliveryResult - записывает в журнал результат попытки доставки
+func (r *Repository) SetWebhookDeliveryResult(ctx context.Context, result WebhookDeliveryResult) error {
+	op := "data.Repository.SetWebhookDeliveryResult"
+
+	qb := r.queryBuilder.
+		Update(WebhookDeliveriesTable).
+		Set("status", result.Status).
+		Set("last_status_code", result.StatusCode).
+		Set("last_error", result.Error).
+		Where(sq.Eq{"delivery_id": result.DeliveryID})
+
+	if result.NextAttemptAt != nil {
+		qb = qb.Set("next_attempt_at", *result.NextAttemptAt)
+	}
+	if result.Status == DeliveryStatusDelivered {
+		qb = qb.Set("delivered_at", sq.Expr("now()"))
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	_, err = r.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return nil
+}
+
+// RetryWebhookDelivery - возвращает доставку, исчерпавшую попытки, в очередь с новым счетчиком попыток
+func (r *Repository) RetryWebhookDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, error) {
+	op := "data.Repository.RetryWebhookDelivery"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	q, i, err := r.queryBuilder.
+		Update(WebhookDeliveriesTable).
+		Set("status", DeliveryStatusPending).
+		Set("attempts", 0).
+		Set("next_attempt_at", sq.Expr("now()")).
+		Where(sq.Eq{"delivery_id": deliveryID, "status": DeliveryStatusFailed}).
+		Suffix("RETURNING " + strings.Join(webhookDeliveryColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	var res WebhookDelivery
+	err = r.db.Client(ctx).Get(ctx, &res, q, i...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, app_errors.ErrNotFound.WrapError(op, "failed delivery not found")
+		}
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	l.With(zap.Int64("delivery_id", deliveryID)).Info(fmt.Sprintf("%s: retry webhook delivery", op))
+
+	return &res, nil
+}
+
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, filter WebhookDeliveriesFilter, beforeID int64, limit uint64) ([]WebhookDelivery, error) {
+	op := "data.Repository.GetWebhookDeliveries"
+
+	qb := r.queryBuilder.
+		Select(webhookDeliveryColumns...).
+		From(WebhookDeliveriesTable)
+
+	if beforeID > 0 {
+		qb = qb.Where(sq.Lt{"delivery_id": beforeID})
+	}
+	if filter.EndpointID != nil {
+		qb = qb.Where(sq.Eq{"endpoint_id": *filter.EndpointID})
+	}
+	if filter.EventID != nil {
+		qb = qb.Where(sq.Eq{"event_id": *filter.EventID})
+	}
+	if filter.Status != nil {
+		qb = qb.Where(sq.Eq{"status": *filter.Status})
+	}
+
+	q, i, err := qb.
+		OrderBy("delivery_id DESC").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	res := make([]WebhookDelivery, 0)
+	err = r.db.Client(ctx).Select(ctx, &res, q, i...)
+	if err != nil {
+		return nil, app_errors.ErrSQLExec.WrapError(op, err.Error())
+	}
+
+	return res, nil
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// CreateModelEvaluation godoc
+//
+//	@Summary		Создает сравнение двух версий модели пользователя на отложенных видео
+//	@Description	Сравнение отправляется тренеру вместе с очередной рассылкой задач обучения, метрики обеих версий
+//	@Description	появляются в сравнении после выполнения задачи. Видео из video_ids должны быть отложены через /held_out_videos
+//	@Description	до создания задач обучения обеих версий, иначе версии могли обучаться на этих видео и сравнение отклоняется
+//	@ID				create model evaluation
+//	@Tags			Evaluations
+//	@Param			X-Service-Token	header		string									true	"Токен внутренних сервисов"
+//	@Param			evaluation		body		fixtures.CreateModelEvaluationRequest	true	"Сравниваемые версии и отложенные видео"
+//	@Success		201				{object}	data.ModelEvaluation
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Failure		404				{object}	app_errors.AppError
+//	@Router			/evaluations [post]
+func (c *CoreHandler) CreateModelEvaluation(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CreateModelEvaluation"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CreateModelEvaluationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// По умолчанию проверяется текущая версия модели пользователя
+	candidateS3Key := req.CandidateS3Key
+	if candidateS3Key == "" {
+		model, err := c.featureRepository.GetModelByUserID(r.Context(), req.UserID, req.ModelType)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if model.S3Key == nil {
+			return app_errors.ErrNotFound.WrapError(op, "model is not trained yet")
+		}
+		candidateS3Key = *model.S3Key
+	}
+
+	// По умолчанию версия сравнивается с предыдущей, например, модель после дообучения с моделью до него
+	baselineS3Key := req.BaselineS3Key
+	if baselineS3Key == "" {
+		baselineS3Key, err = c.featureRepository.GetPreviousModelS3Key(r.Context(), req.UserID, req.ModelType, candidateS3Key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	for _, s3Key := range []string{baselineS3Key, candidateS3Key} {
+		if !isEvaluableModel(s3Key, req.UserID, req.ModelType) {
+			return app_errors.ErrValidationError.WrapError(op,
+				fmt.Sprintf("model %s does not belong to user or global model", s3Key))
+		}
+	}
+	if baselineS3Key == candidateS3Key {
+		return app_errors.ErrValidationError.WrapError(op, "baseline and candidate are the same model")
+	}
+
+	// Ключи из запроса и старые версии могли быть удалены сборщиком gc-models, тогда тренер не скачает модель
+	for _, s3Key := range []string{baselineS3Key, candidateS3Key} {
+		exists, err := c.modelSaver.FileExists(r.Context(), s3Key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return app_errors.ErrNotFound.WrapError(op, fmt.Sprintf("model %s not found in s3", s3Key))
+		}
+	}
+
+	err = c.validateHeldOutVideos(r.Context(), req.UserID, req.ModelType, req.VideoIDs, baselineS3Key, candidateS3Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	evaluationID, err := uuid.NewUUID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	evaluation, err := c.featureRepository.CreateModelEvaluation(r.Context(), data.ModelEvaluation{
+		EvaluationID:   evaluationID.String(),
+		UserID:         req.UserID,
+		ModelType:      req.ModelType,
+		BaselineS3Key:  baselineS3Key,
+		CandidateS3Key: candidateS3Key,
+		VideoIDs:       req.VideoIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем созданное сравнение со статусом 201
+	api.WriteSuccess(r.Context(), w, evaluation, http.StatusCreated, l)
+	return nil
+}
+
+// AddHeldOutVideos godoc
+//
+//	@Summary		Откладывает видео пользователя для сравнения версий модели
+//	@Description	Признаки отложенных видео не передаются тренеру в задачах обучения, дообучения и обучения глобальной модели,
+//	@Description	созданных после этого запроса. Повторно отложенные видео сохраняют прежнее время
+//	@ID				add held out videos
+//	@Tags			Evaluations
+//	@Param			X-Service-Token	header		string								true	"Токен внутренних сервисов"
+//	@Param			videos			body		fixtures.AddHeldOutVideosRequest	true	"Откладываемые видео"
+//	@Success		201				{array}		data.HeldOutVideo
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/held_out_videos [post]
+func (c *CoreHandler) AddHeldOutVideos(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.AddHeldOutVideos"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.AddHeldOutVideosRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	err = c.featureRepository.AddHeldOutVideos(r.Context(), req.UserID, req.ModelType, req.VideoIDs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	heldOutVideos, err := c.featureRepository.GetHeldOutVideos(r.Context(), req.UserID, req.ModelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем отложенные видео пользователя со статусом 201
+	api.WriteSuccess(r.Context(), w, heldOutVideos, http.StatusCreated, l)
+	return nil
+}
+
+// GetHeldOutVideos godoc
+//
+//	@Summary	Возвращает отложенные видео пользователя
+//	@ID			get held out videos
+//	@Tags		Evaluations
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Param		model_type	query		string	true	"Тип модели"
+//	@Success	200			{array}		data.HeldOutVideo
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/held_out_videos [get]
+func (c *CoreHandler) GetHeldOutVideos(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetHeldOutVideos"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Берем id пользователя и тип модели из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+	modelType := r.URL.Query().Get("model_type")
+	if modelType == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty model_type")
+	}
+
+	heldOutVideos, err := c.featureRepository.GetHeldOutVideos(r.Context(), userID, modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, heldOutVideos, http.StatusOK, l)
+	return nil
+}
+
+// GetModelEvaluations godoc
+//
+//	@Summary	Возвращает сравнения версий моделей пользователя, начиная с последних
+//	@ID			get model evaluations
+//	@Tags		Evaluations
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Param		model_type	query		string	false	"Тип модели"
+//	@Success	200			{array}		data.ModelEvaluation
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/evaluations [get]
+func (c *CoreHandler) GetModelEvaluations(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetModelEvaluations"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Берем id пользователя из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+
+	var modelType *string
+	if modelTypeString := r.URL.Query().Get("model_type"); modelTypeString != "" {
+		modelType = &modelTypeString
+	}
+
+	evaluations, err := c.featureRepository.GetModelEvaluations(r.Context(), userID, modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, evaluations, http.StatusOK, l)
+	return nil
+}
+
+// GetModelEvaluation godoc
+//
+//	@Summary	Возвращает сравнение версий модели
+//	@ID			get model evaluation
+//	@Tags		Evaluations
+//	@Param		evaluation_id	path		string	true	"ID сравнения"
+//	@Success	200				{object}	data.ModelEvaluation
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/evaluations/{evaluation_id} [get]
+func (c *CoreHandler) GetModelEvaluation(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetModelEvaluation"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	evaluation, err := c.featureRepository.GetModelEvaluation(r.Context(), chi.URLParam(r, "evaluation_id"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, evaluation, http.StatusOK, l)
+	return nil
+}
+
+// validateHeldOutVideos - проверяет, что видео сравнения отложены раньше, чем созданы задачи обучения обеих версий,
+// то есть ни одна из версий не обучалась на этих видео
+func (c *CoreHandler) validateHeldOutVideos(
+	ctx context.Context,
+	userID, modelType string,
+	videoIDs []string,
+	s3Keys ...string,
+) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.validateHeldOutVideos"
+
+	heldOutVideos, err := c.featureRepository.GetHeldOutVideos(ctx, userID, modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	heldOutAt := make(map[string]time.Time, len(heldOutVideos))
+	for _, video := range heldOutVideos {
+		heldOutAt[video.VideoID] = video.CreatedAt
+	}
+
+	var lastHeldOutAt time.Time
+	for _, videoID := range videoIDs {
+		createdAt, ok := heldOutAt[videoID]
+		if !ok {
+			return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("video %s is not held out", videoID))
+		}
+		if createdAt.After(lastHeldOutAt) {
+			lastHeldOutAt = createdAt
+		}
+	}
+
+	for _, s3Key := range s3Keys {
+		trainQueuedAt, err := c.featureRepository.GetModelTrainQueuedAt(ctx, s3Key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !lastHeldOutAt.Before(trainQueuedAt) {
+			return app_errors.ErrValidationError.WrapError(op,
+				fmt.Sprintf("model %s may be trained on evaluation videos: videos were held out after its train job", s3Key))
+		}
+	}
+
+	return nil
+}
+
+// isEvaluableModel - модели пользователя и глобальные модели хранятся в s3 по пути <тип модели>/<id пользователя>/
+func isEvaluableModel(s3Key, userID, modelType string) bool {
+	return strings.HasPrefix(s3Key, path.Join(modelType, userID)+"/") ||
+		strings.HasPrefix(s3Key, path.Join(modelType, data.GlobalModelUserID)+"/")
+}
@@ -5,18 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
-	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
 	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
-	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
-	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 
 	"net/http"
 )
 
 // IncreaseFeatures godoc
 //
-//	@Summary	Принимает количество новых фич по моделям
+//	@Summary	Принимает количество новых и исправленных фич по моделям
 //	@ID			increase features
 //	@Tags		Features
 //	@Param		features_data	body	fixtures.IncreaseFeaturesRequest	true	"Данные о количестве фич"
@@ -26,8 +23,6 @@ import (
 func (c *CoreHandler) IncreaseFeatures(w http.ResponseWriter, r *http.Request) error {
 	// Объявляем текущую операцию для оборачивания ошибки
 	op := "handlers.CoreHandler.IncreaseFeatures"
-	// Берем логгер из контекста
-	l := logger.EntryWithRequestIDFromContext(r.Context())
 
 	// Десереализуем данные из тела запроса
 	var req fixtures.IncreaseFeaturesRequest
@@ -42,14 +37,23 @@ func (c *CoreHandler) IncreaseFeatures(w http.ResponseWriter, r *http.Request) e
 		return appErr
 	}
 
+	// Признаки принимаются только для типов моделей из справочника
+	_, err = c.featureRepository.GetModelType(r.Context(), req.ModelType)
+	if err != nil {
+		if app_errors.IsNotFound(err) {
+			return app_errors.ErrValidationError.WrapError(op, "unknown model_type")
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	// Делаем все изменения данных в БД в транзакции
 	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
 		// Берем модель пользователя по ID пользователя и типу модели из БД
-		_, err = c.featureRepository.GetModelByUserID(txCtx, req.UserID, data.FaceModel)
+		_, err = c.featureRepository.GetModelByUserID(txCtx, req.UserID, req.ModelType)
 		if err != nil {
 			// Если модель не нашлась, то создаем новоую в БД
 			if app_errors.IsNotFound(err) {
-				err = c.featureRepository.CreateModel(txCtx, req.UserID, data.FaceModel)
+				err = c.featureRepository.CreateModel(txCtx, req.UserID, req.ModelType)
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
@@ -59,9 +63,19 @@ func (c *CoreHandler) IncreaseFeatures(w http.ResponseWriter, r *http.Request) e
 		}
 
 		// увеличиваем количество признаков на желаемое значение в БД
-		err = c.featureRepository.ChangeFeaturesCount(txCtx, req.UserID, data.FaceModel, req.FeaturesCount)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if req.FeaturesCount != 0 {
+			err = c.featureRepository.ChangeFeaturesCount(txCtx, req.UserID, req.ModelType, req.FeaturesCount)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		// если у признаков были исправлены метки, то модель нужно переобучить с нуля
+		if req.RelabeledCount > 0 {
+			err = c.featureRepository.SetNeedsRetrain(txCtx, req.UserID, req.ModelType, true)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
 		}
 
 		return nil
@@ -72,6 +86,6 @@ func (c *CoreHandler) IncreaseFeatures(w http.ResponseWriter, r *http.Request) e
 	}
 
 	// Возвращаем пустой ответ со статусом 204
-	api.WriteSuccess(r.Context(), w, struct{}{}, http.StatusNoContent, l)
+	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
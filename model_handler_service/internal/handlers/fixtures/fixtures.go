@@ -1,11 +1,173 @@
 package fixtures
 
+import (
+	"encoding/json"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"time"
+)
+
 type IncreaseFeaturesRequest struct {
-	ModelType     string `json:"model_type"  validate:"required"`
-	UserID        string `json:"user_id"  validate:"required"`
-	FeaturesCount int    `json:"features_count"  validate:"required"`
+	ModelType      string `json:"model_type"  validate:"required"`
+	UserID         string `json:"user_id"  validate:"required"`
+	FeaturesCount  int    `json:"features_count"  validate:"required_without=RelabeledCount"`
+	RelabeledCount int    `json:"relabeled_count"  validate:"omitempty,gt=0"`
 }
 
 type GetModelsRequest struct {
 	UserID string `json:"user_id"  validate:"required"`
 }
+
+// ModelURL - ссылка на скачивание модели. IsPersonalized равен false, если пользователю еще не обучена своя модель
+// и вместо нее выдана глобальная, обученная на признаках всех пользователей
+type ModelURL struct {
+	URL string `json:"url"`
+	// SHA256 - контрольная сумма файла модели в hex для проверки после скачивания
+	SHA256         *string `json:"sha256"`
+	IsPersonalized bool    `json:"is_personalized"`
+}
+
+type SaveModelResponse struct {
+	S3Key  string `json:"s3_key"`
+	SHA256 string `json:"sha256"`
+}
+
+// CreateModelUploadRequest - запрос ссылки на загрузку обученной модели, SHA256 - контрольная сумма файла в hex
+type CreateModelUploadRequest struct {
+	UserID      string `json:"user_id"  validate:"required"`
+	ModelType   string `json:"model_type"  validate:"required"`
+	Filename    string `json:"filename"  validate:"required,max=256"`
+	SHA256      string `json:"sha256"  validate:"required,len=64,hexadecimal"`
+	ContentType string `json:"content_type"  validate:"max=128"`
+}
+
+// CreateModelUploadResponse - ссылка на загрузку модели PUT-запросом вместе с заголовками Headers.
+// После загрузки тренер подтверждает ее запросом /model_uploads/complete с полученным S3Key
+type CreateModelUploadResponse struct {
+	S3Key     string            `json:"s3_key"`
+	UploadURL string            `json:"upload_url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type CompleteModelUploadRequest struct {
+	UserID        string `json:"user_id"  validate:"required"`
+	ModelType     string `json:"model_type"  validate:"required"`
+	S3Key         string `json:"s3_key"  validate:"required"`
+	FeaturesCount int    `json:"features_count"  validate:"required"`
+}
+
+type CreateTrainThresholdRequest struct {
+	ModelType      string             `json:"model_type"  validate:"required"`
+	TrainThreshold uint64             `json:"train_threshold"  validate:"required"`
+	TuneThreshold  uint64             `json:"tune_threshold"  validate:"required"`
+	Priority       data.PriorityRules `json:"priority"`
+	WarmStart      bool               `json:"warm_start"`
+}
+
+type UpdateTrainThresholdRequest struct {
+	TrainThreshold uint64             `json:"train_threshold"  validate:"required"`
+	TuneThreshold  uint64             `json:"tune_threshold"  validate:"required"`
+	Priority       data.PriorityRules `json:"priority"`
+	WarmStart      bool               `json:"warm_start"`
+}
+
+type CreateModelTypeRequest struct {
+	ModelType      string               `json:"model_type"  validate:"required,max=64"`
+	Description    string               `json:"description"  validate:"max=256"`
+	FeaturesSchema []data.FeatureColumn `json:"features_schema"  validate:"required,min=1,dive"`
+}
+
+// SetModelTypeFeaturesSchemaRequest - новая схема признаков: признаки можно только добавлять, тип признака не меняется
+type SetModelTypeFeaturesSchemaRequest struct {
+	FeaturesSchema []data.FeatureColumn `json:"features_schema"  validate:"required,min=1,dive"`
+}
+
+type PredictRequest struct {
+	UserID    string `json:"user_id"  validate:"required"`
+	ModelType string `json:"model_type"  validate:"required"`
+	// Rows - строки признаков в порядке feature_names модели, null означает пропущенное значение
+	Rows [][]*float64 `json:"rows"  validate:"required,min=1,max=1000,dive,required"`
+}
+
+type Prediction struct {
+	Tired float64 `json:"tired"`
+	Awake float64 `json:"awake"`
+}
+
+type PredictResponse struct {
+	ModelType    string       `json:"model_type"`
+	S3Key        string       `json:"s3_key"`
+	FeatureNames []string     `json:"feature_names"`
+	Predictions  []Prediction `json:"predictions"`
+}
+
+// StreamFrame - сообщение клиента в потоке предсказаний: признаки одного кадра в порядке feature_names модели
+type StreamFrame struct {
+	Features []*float64 `json:"features"`
+}
+
+// StreamPrediction - ответ на кадр: вероятности по кадру и сглаженное по окну состояние
+type StreamPrediction struct {
+	Frame       int     `json:"frame"`
+	Tired       float64 `json:"tired"`
+	Awake       float64 `json:"awake"`
+	IsTired     bool    `json:"is_tired"`
+	TiredFrames int     `json:"tired_frames"`
+	Window      int     `json:"window"`
+	Error       string  `json:"error,omitempty"`
+}
+
+type SetUserOrganizationRequest struct {
+	OrganizationID string `json:"organization_id"  validate:"required,max=64"`
+}
+
+type CreateWebhookEndpointRequest struct {
+	OrganizationID string `json:"organization_id"  validate:"required,max=64"`
+	URL            string `json:"url"  validate:"required,url,max=2048"`
+	// Secret - секрет подписи, если не задан, то генерируется сервисом
+	Secret     string   `json:"secret"  validate:"omitempty,min=16,max=128"`
+	EventTypes []string `json:"event_types"  validate:"required,min=1,dive,oneof=model.trained model.training_failed fatigue.alert"`
+}
+
+// CreateWebhookEndpointResponse - секрет возвращается только при создании вебхука
+type CreateWebhookEndpointResponse struct {
+	data.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type SetWebhookEndpointActiveRequest struct {
+	IsActive bool `json:"is_active"`
+}
+
+type PublishWebhookEvent struct {
+	EventType string          `json:"event_type"  validate:"required,oneof=model.trained model.training_failed fatigue.alert"`
+	UserID    string          `json:"user_id"  validate:"required"`
+	Data      json.RawMessage `json:"data"  swaggertype:"object"`
+}
+
+// PublishWebhookEventsRequest - события, которые другие сервисы системы передают на рассылку в вебхуки
+type PublishWebhookEventsRequest struct {
+	Events []PublishWebhookEvent `json:"events"  validate:"required,min=1,max=1000,dive"`
+}
+
+type PublishWebhookEventsResponse struct {
+	EventIDs   []string `json:"event_ids"`
+	Deliveries int64    `json:"deliveries"`
+}
+
+// AddHeldOutVideosRequest - видео пользователя, которые откладываются для сравнения версий модели
+type AddHeldOutVideosRequest struct {
+	UserID    string   `json:"user_id"  validate:"required"`
+	ModelType string   `json:"model_type"  validate:"required"`
+	VideoIDs  []string `json:"video_ids"  validate:"required,min=1,max=100,dive,required,max=64"`
+}
+
+// CreateModelEvaluationRequest - если версии не заданы, то сравниваются текущая модель пользователя (candidate)
+// и предыдущая версия из журнала задач обучения (baseline)
+type CreateModelEvaluationRequest struct {
+	UserID         string   `json:"user_id"  validate:"required"`
+	ModelType      string   `json:"model_type"  validate:"required"`
+	BaselineS3Key  string   `json:"baseline_s3_key"  validate:"max=128"`
+	CandidateS3Key string   `json:"candidate_s3_key"  validate:"max=128"`
+	VideoIDs       []string `json:"video_ids"  validate:"required,min=1,max=100,dive,required,max=64"`
+}
@@ -11,10 +11,12 @@ import (
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/google/uuid"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"path"
 	"strconv"
+	"time"
 )
 
 // SaveModel godoc
@@ -23,7 +25,7 @@ import (
 //	@ID			save model
 //	@Tags		Models
 //	@Param		file	formData	file	true	"Загружаемая ml-модель"
-//	@Success	204
+//	@Success	200	{object}	fixtures.SaveModelResponse
 //	@Failure	400	{object}	app_errors.AppError
 //	@Router		/save_model [post]
 func (c *CoreHandler) SaveModel(w http.ResponseWriter, r *http.Request) error {
@@ -66,27 +68,15 @@ func (c *CoreHandler) SaveModel(w http.ResponseWriter, r *http.Request) error {
 	filename := path.Join(modelType, userID, fmt.Sprintf("%s_%s", modelID.String(), header.Filename))
 
 	// Делаем все изменения данных в транзакции
+	var checksum string
 	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
-		// Задаем новый s3 ключ для модели
-		err := c.featureRepository.SetModelS3Key(txCtx, filename, modelType, userID)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-
-		// Задаем статус модели - обучена
-		err = c.featureRepository.SetModelStatus(txCtx, data.StatusTrained, modelType, userID)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-
-		// Задаем количество признаков, которые использовались в обучении
-		err = c.featureRepository.SetFeaturesCountUsed(txCtx, userID, modelType, featuresCount)
+		// Сохраняем модель в s3
+		checksum, err = c.modelSaver.SaveFile(r.Context(), filename, file, modelContentType(header))
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
-		// Сохраняем модель в s3
-		err = c.modelSaver.SaveFile(r.Context(), filename, file)
+		err = c.commitTrainedModel(txCtx, userID, modelType, filename, checksum, featuresCount)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
@@ -97,20 +87,71 @@ func (c *CoreHandler) SaveModel(w http.ResponseWriter, r *http.Request) error {
 		return txErr
 	}
 
-	// Возвращаем пустой ответ со статусом 204
-	api.WriteSuccess(r.Context(), w, struct{}{}, http.StatusNoContent, l)
+	// Возвращаем s3 ключ сохраненной модели со статусом 200, тренер передает его в очереди результатов
+	api.WriteSuccess(r.Context(), w, fixtures.SaveModelResponse{S3Key: filename, SHA256: checksum}, http.StatusOK, l)
+	return nil
+}
+
+// commitTrainedModel - записывает загруженную в s3 модель как текущую модель пользователя
+func (c *CoreHandler) commitTrainedModel(ctx context.Context, userID, modelType, s3Key, checksum string,
+	featuresCount int) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.commitTrainedModel"
+
+	// Задаем новый s3 ключ для модели
+	err := c.featureRepository.SetModelS3Key(ctx, s3Key, modelType, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Запоминаем контрольную сумму, по ней клиенты проверяют скачанную модель
+	err = c.featureRepository.SetModelChecksum(ctx, checksum, modelType, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Задаем статус модели - обучена
+	err = c.featureRepository.SetModelStatus(ctx, data.StatusTrained, modelType, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Запоминаем время обучения, по нему считается приоритет следующего дообучения
+	err = c.featureRepository.SetLastTrainedAt(ctx, userID, modelType, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Задаем количество признаков, которые использовались в обучении
+	err = c.featureRepository.SetFeaturesCountUsed(ctx, userID, modelType, featuresCount)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Уведомляем подписанные вебхуки организации пользователя об обучении модели
+	_, err = c.featureRepository.EnqueueWebhookEvent(ctx, data.NewWebhookEvent(
+		data.WebhookEventModelTrained, userID, data.ModelTrainedEventData{
+			ModelType:     modelType,
+			S3Key:         s3Key,
+			FeaturesCount: featuresCount,
+		}))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
 // GetModels godoc
 //
-//	@Summary	Возвращает ссылки на модели по id пользователя
-//	@ID			get models
-//	@Tags		Models
-//	@Param		features_data	body		fixtures.GetModelsRequest	true	"ID пользователя"
-//	@Success	200				{object}	map[string]string
-//	@Failure	400				{object}	app_errors.AppError
-//	@Router		/get_models [post]
+//	@Summary		Возвращает ссылки на модели по id пользователя
+//	@Description	Если у пользователя еще нет своей модели какого-либо типа, то возвращается ссылка на глобальную модель с is_personalized = false
+//	@ID				get models
+//	@Tags			Models
+//	@Param			features_data	body		fixtures.GetModelsRequest	true	"ID пользователя"
+//	@Success		200				{object}	map[string]fixtures.ModelURL
+//	@Failure		400				{object}	app_errors.AppError
+//	@Router			/get_models [post]
 func (c *CoreHandler) GetModels(w http.ResponseWriter, r *http.Request) error {
 	// Объявляем текущую операцию для оборачивания ошибки
 	op := "handlers.CoreHandler.GetModels"
@@ -137,19 +178,122 @@ func (c *CoreHandler) GetModels(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Формируем ответ, состоящий из предподписанных ссылок на скачивание всех типов моделей
-	modelsURLS := make(map[string]string, len(models))
+	modelsURLS := make(map[string]fixtures.ModelURL, len(models))
 	for _, model := range models {
 		if model.S3Key != nil {
 			var modelURL string
-			modelURL, err = c.modelSaver.GetPresignURL(r.Context(), *model.S3Key)
+			modelURL, err = c.modelSaver.GetPresignURL(r.Context(), *model.S3Key, c.presignExpiry.Download)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
-			modelsURLS[model.ModelType] = modelURL
+			modelsURLS[model.ModelType] = fixtures.ModelURL{URL: modelURL, SHA256: model.Checksum, IsPersonalized: true}
 		}
 	}
 
+	// Для типов, по которым у пользователя еще нет своей модели, выдаем глобальную модель
+	globalModels, err := c.featureRepository.GetModelsByUserID(r.Context(), data.GlobalModelUserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, model := range globalModels {
+		if _, ok := modelsURLS[model.ModelType]; ok || model.S3Key == nil {
+			continue
+		}
+		var modelURL string
+		modelURL, err = c.modelSaver.GetPresignURL(r.Context(), *model.S3Key, c.presignExpiry.Download)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		modelsURLS[model.ModelType] = fixtures.ModelURL{URL: modelURL, SHA256: model.Checksum, IsPersonalized: false}
+	}
+
 	// Возвращаем результат со статусом 200
 	api.WriteSuccess(r.Context(), w, modelsURLS, http.StatusOK, l)
 	return nil
 }
+
+// GetUntrainedUsers godoc
+//
+//	@Summary	Возвращает id пользователей, модели которых еще не обучены
+//	@ID			get untrained users
+//	@Tags		Models
+//	@Param		model_type	query		string	true	"Тип модели"
+//	@Success	200			{array}		string
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/untrained_users [get]
+func (c *CoreHandler) GetUntrainedUsers(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetUntrainedUsers"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Берем тип модели из параметров запроса
+	modelType := r.URL.Query().Get("model_type")
+	if modelType == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty model_type")
+	}
+
+	// Находим пользователей с необученными моделями
+	userIDs, err := c.featureRepository.ViewUntrainedUserIDs(r.Context(), modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, userIDs, http.StatusOK, l)
+	return nil
+}
+
+// GetTrainJobs godoc
+//
+//	@Summary	Возвращает журнал задач обучения моделей пользователя
+//	@ID			get train jobs
+//	@Tags		Models
+//	@Param		user_id		query		string	true	"ID пользователя"
+//	@Param		model_type	query		string	false	"Тип модели"
+//	@Success	200			{array}		data.TrainJob
+//	@Failure	400			{object}	app_errors.AppError
+//	@Router		/train_jobs [get]
+func (c *CoreHandler) GetTrainJobs(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetTrainJobs"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Берем id пользователя из параметров запроса
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+
+	var modelType *string
+	if modelTypeString := r.URL.Query().Get("model_type"); modelTypeString != "" {
+		modelType = &modelTypeString
+	}
+
+	// Находим задачи обучения пользователя
+	jobs, err := c.featureRepository.GetTrainJobs(r.Context(), userID, modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, jobs, http.StatusOK, l)
+	return nil
+}
+
+// modelContentType - определяет тип содержимого файла модели по заголовку части формы или по расширению
+func modelContentType(header *multipart.FileHeader) string {
+	if contentType := header.Header.Get("Content-Type"); contentType != "" {
+		return contentType
+	}
+	return contentTypeByFilename(header.Filename)
+}
+
+// contentTypeByFilename - определяет тип содержимого по расширению файла
+func contentTypeByFilename(filename string) string {
+	if contentType := mime.TypeByExtension(path.Ext(filename)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// identifierRegexp - тип модели используется в url, именах очередей и таблиц,
+// поэтому допускаются только строчные латинские буквы, цифры и подчеркивания
+var identifierRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+const (
+	// schemasRequestTimeout - время ожидания face_features_storage, который создает или изменяет таблицу признаков
+	schemasRequestTimeout = 30 * time.Second
+	// maxResponseErrorLength - сколько байт тела неуспешного ответа face_features_storage включать в ошибку
+	maxResponseErrorLength = 512
+)
+
+// GetModelTypes godoc
+//
+//	@Summary	Возвращает справочник типов моделей
+//	@ID			get model types
+//	@Tags		Model types
+//	@Success	200	{array}		data.ModelType
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/model_types [get]
+func (c *CoreHandler) GetModelTypes(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetModelTypes"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	modelTypes, err := c.featureRepository.GetModelTypes(r.Context())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, modelTypes, http.StatusOK, l)
+	return nil
+}
+
+// GetModelType godoc
+//
+//	@Summary	Возвращает тип модели из справочника
+//	@ID			get model type
+//	@Tags		Model types
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Success	200			{object}	data.ModelType
+//	@Failure	404			{object}	app_errors.AppError
+//	@Router		/model_types/{model_type} [get]
+func (c *CoreHandler) GetModelType(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetModelType"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	modelType, err := c.featureRepository.GetModelType(r.Context(), chi.URLParam(r, "model_type"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, modelType, http.StatusOK, l)
+	return nil
+}
+
+// CreateModelType godoc
+//
+//	@Summary		Добавляет тип модели в справочник. Для отправки задач обучения нужно также задать пороги обучения
+//	@Description	Схема признаков применяется к face_features_storage (PUT /schemas/{model_type}), который создает таблицу признаков.
+//	@Description	Если таблицу создать не удалось, тип не добавляется в справочник
+//	@ID				create model type
+//	@Tags			Model types
+//	@Param			X-Service-Token	header		string							true	"Токен внутренних сервисов"
+//	@Param			model_type		body		fixtures.CreateModelTypeRequest	true	"Тип модели и схема признаков"
+//	@Success		201				{object}	data.ModelType
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Failure		409				{object}	app_errors.AppError
+//	@Router			/model_types [post]
+func (c *CoreHandler) CreateModelType(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CreateModelType"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CreateModelTypeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	if !identifierRegexp.MatchString(req.ModelType) {
+		return app_errors.ErrValidationError.WrapError(op, "invalid model_type")
+	}
+
+	var modelType *data.ModelType
+	// тип попадает в справочник, только если face_features_storage создал для него таблицу признаков
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		// тип добавляется до применения схемы, чтобы повторное создание не изменило схему существующего типа
+		_, err = c.featureRepository.CreateModelType(txCtx, data.ModelType{
+			ModelType:      req.ModelType,
+			Description:    req.Description,
+			FeaturesSchema: req.FeaturesSchema,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		modelType, err = c.applyFeaturesSchema(txCtx, req.ModelType, req.FeaturesSchema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// Возвращаем созданный тип модели со статусом 201
+	api.WriteSuccess(r.Context(), w, modelType, http.StatusCreated, l)
+	return nil
+}
+
+// SetModelTypeFeaturesSchema godoc
+//
+//	@Summary		Изменяет схему признаков типа модели
+//	@Description	Признаки можно только добавлять, удалять признаки и менять их тип нельзя.
+//	@Description	Новые признаки добавляются в таблицу признаков face_features_storage в той же операции
+//	@ID				set model type features schema
+//	@Tags			Model types
+//	@Param			X-Service-Token	header		string										true	"Токен внутренних сервисов"
+//	@Param			model_type		path		string										true	"Тип модели"
+//	@Param			schema			body		fixtures.SetModelTypeFeaturesSchemaRequest	true	"Схема признаков"
+//	@Success		200				{object}	data.ModelType
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Failure		404				{object}	app_errors.AppError
+//	@Router			/model_types/{model_type}/features_schema [put]
+func (c *CoreHandler) SetModelTypeFeaturesSchema(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.SetModelTypeFeaturesSchema"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.SetModelTypeFeaturesSchemaRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	modelTypeName := chi.URLParam(r, "model_type")
+
+	var modelType *data.ModelType
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		// запись справочника блокируется до конца транзакции, поэтому изменения схемы одного типа не пересекаются
+		_, err = c.featureRepository.SetModelTypeFeaturesSchema(txCtx, modelTypeName, req.FeaturesSchema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		modelType, err = c.applyFeaturesSchema(txCtx, modelTypeName, req.FeaturesSchema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// Возвращаем тип модели с актуальной схемой со статусом 200
+	api.WriteSuccess(r.Context(), w, modelType, http.StatusOK, l)
+	return nil
+}
+
+// applyFeaturesSchema - применяет схему к таблице признаков face_features_storage и сохраняет в справочнике
+// схему, которую вернул face_features_storage: в ней нет системных колонок, а существующие признаки идут первыми
+func (c *CoreHandler) applyFeaturesSchema(ctx context.Context, modelType string, featuresSchema []data.FeatureColumn) (*data.ModelType, error) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.applyFeaturesSchema"
+
+	jsonData, err := json.Marshal(struct {
+		Columns []data.FeatureColumn `json:"columns"`
+	}{Columns: featuresSchema})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, schemasRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPut, c.schemasURL+"/"+url.PathEscape(modelType), bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ServiceTokenHeader, c.serviceToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	// ошибки проверки схемы возвращаются клиенту, остальные считаются ошибкой face_features_storage
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Error api.AppError `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseErrorLength))
+		if resp.StatusCode == http.StatusBadRequest && json.Unmarshal(body, &result) == nil {
+			return nil, app_errors.ErrValidationError.WrapError(op, result.Error.Message)
+		}
+		return nil, fmt.Errorf("%s: error from face_features_storage service: status %d: %s", op, resp.StatusCode, body)
+	}
+
+	var result struct {
+		Content struct {
+			Columns []data.FeatureColumn `json:"columns"`
+		} `json:"content"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := c.featureRepository.SetModelTypeFeaturesSchema(ctx, modelType, result.Content.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+// DeleteModelType godoc
+//
+//	@Summary	Удаляет тип модели, на который еще не ссылаются модели и пороги обучения
+//	@ID			delete model type
+//	@Tags		Model types
+//	@Param		X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param		model_type		path	string	true	"Тип модели"
+//	@Success	204
+//	@Failure	400	{object}	app_errors.AppError
+//	@Failure	401	{object}	app_errors.AppError
+//	@Failure	404	{object}	app_errors.AppError
+//	@Router		/model_types/{model_type} [delete]
+func (c *CoreHandler) DeleteModelType(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.DeleteModelType"
+
+	err := c.featureRepository.DeleteModelType(r.Context(), chi.URLParam(r, "model_type"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем пустой ответ со статусом 204
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
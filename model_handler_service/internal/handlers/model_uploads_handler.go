@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
+	"github.com/google/uuid"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// CreateModelUpload godoc
+//
+//	@Summary		Возвращает ссылку на загрузку обученной модели напрямую в s3
+//	@Description	Тренер загружает файл модели PUT-запросом по upload_url с заголовками headers,
+//	@Description	после чего подтверждает загрузку запросом /model_uploads/complete. Ссылка фиксирует заявленную контрольную сумму в метаданных,
+//	@Description	но содержимое с ней не сверяет: файл проверяется при подтверждении загрузки и удаляется при расхождении
+//	@ID				create model upload
+//	@Tags			Models
+//	@Param			upload	body		fixtures.CreateModelUploadRequest	true	"Модель и контрольная сумма файла"
+//	@Success		200		{object}	fixtures.CreateModelUploadResponse
+//	@Failure		400		{object}	app_errors.AppError
+//	@Failure		404		{object}	app_errors.AppError
+//	@Router			/model_uploads [post]
+func (c *CoreHandler) CreateModelUpload(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CreateModelUpload"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CreateModelUploadRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Ссылку выдаем только для существующей модели, иначе подтверждение загрузки будет некуда записать
+	_, err = c.featureRepository.GetModelByUserID(r.Context(), req.UserID, req.ModelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Создаем новый uuid для модели
+	modelID, err := uuid.NewUUID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Задаем путь и название файла в s3 так же, как при сохранении через /save_model
+	filename := path.Join(req.ModelType, req.UserID, fmt.Sprintf("%s_%s", modelID.String(), path.Base(req.Filename)))
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = contentTypeByFilename(req.Filename)
+	}
+
+	upload, err := c.modelSaver.GetPresignUploadURL(r.Context(), filename, strings.ToLower(req.SHA256), contentType,
+		c.presignExpiry.Upload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, fixtures.CreateModelUploadResponse{
+		S3Key:     filename,
+		UploadURL: upload.URL,
+		Headers:   upload.Headers,
+		ExpiresAt: upload.ExpiresAt,
+	}, http.StatusOK, l)
+	return nil
+}
+
+// CompleteModelUpload godoc
+//
+//	@Summary		Подтверждает загрузку модели по ссылке из /model_uploads
+//	@Description	Содержимое загруженного файла сверяется с контрольной суммой, заявленной при получении ссылки.
+//	@Description	При расхождении файл удаляется, и модель пользователя не меняется
+//	@ID				complete model upload
+//	@Tags			Models
+//	@Param			upload	body		fixtures.CompleteModelUploadRequest	true	"Загруженная модель"
+//	@Success		200		{object}	fixtures.SaveModelResponse
+//	@Failure		400		{object}	app_errors.AppError
+//	@Failure		404		{object}	app_errors.AppError
+//	@Router			/model_uploads/complete [post]
+func (c *CoreHandler) CompleteModelUpload(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CompleteModelUpload"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CompleteModelUploadRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Модели пользователя хранятся в s3 по пути <тип модели>/<id пользователя>/
+	if !strings.HasPrefix(req.S3Key, path.Join(req.ModelType, req.UserID)+"/") {
+		return app_errors.ErrValidationError.WrapError(op,
+			fmt.Sprintf("model %s does not belong to user", req.S3Key))
+	}
+
+	// Перечитываем загруженный файл, так как хранилище не сверяет содержимое с метаданными при загрузке
+	checksum, err := c.modelSaver.VerifyFile(r.Context(), req.S3Key)
+	if err != nil {
+		switch {
+		case errors.Is(err, s3_client.ErrObjectNotFound):
+			return app_errors.ErrNotFound.WrapError(op, "model is not uploaded")
+		case errors.Is(err, s3_client.ErrChecksumMismatch):
+			// Поврежденный файл удаляем, тренер может запросить новую ссылку и загрузить модель заново
+			deleteErr := c.modelSaver.DeleteFile(r.Context(), req.S3Key)
+			if deleteErr != nil {
+				l.Error(fmt.Errorf("%s: %w", op, deleteErr).Error())
+			}
+			return app_errors.ErrValidationError.WrapError(op, err.Error())
+		default:
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// Делаем все изменения данных в транзакции
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		err := c.commitTrainedModel(txCtx, req.UserID, req.ModelType, req.S3Key, checksum, req.FeaturesCount)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// Возвращаем s3 ключ сохраненной модели со статусом 200, тренер передает его в очереди результатов
+	api.WriteSuccess(r.Context(), w, fixtures.SaveModelResponse{S3Key: req.S3Key, SHA256: checksum}, http.StatusOK, l)
+	return nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"math"
+	"net/http"
+)
+
+// Predict godoc
+//
+//	@Summary	Возвращает вероятности усталости для пакета строк признаков по активной модели пользователя
+//	@ID			predict
+//	@Tags		Models
+//	@Param		predict_data	body		fixtures.PredictRequest	true	"ID пользователя, тип модели и строки признаков"
+//	@Success	200				{object}	fixtures.PredictResponse
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/predict [post]
+func (c *CoreHandler) Predict(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.Predict"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.PredictRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Находим активную модель пользователя
+	model, err := c.featureRepository.GetModelByUserID(r.Context(), req.UserID, req.ModelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if model.S3Key == nil {
+		return app_errors.ErrNotFound.WrapError(op, "model is not trained yet")
+	}
+
+	xgbModel, err := c.predictor.Model(r.Context(), *model.S3Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows := make([][]float64, 0, len(req.Rows))
+	for _, reqRow := range req.Rows {
+		rows = append(rows, featureRow(reqRow))
+	}
+
+	probabilities, err := xgbModel.Predict(rows)
+	if err != nil {
+		return app_errors.ErrValidationError.WrapError(op, err.Error())
+	}
+
+	// Модели обучаются с label = 1 для видео, на которых пользователь устал
+	predictions := make([]fixtures.Prediction, 0, len(probabilities))
+	for _, p := range probabilities {
+		predictions = append(predictions, fixtures.Prediction{Tired: p, Awake: 1 - p})
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, fixtures.PredictResponse{
+		ModelType:    req.ModelType,
+		S3Key:        *model.S3Key,
+		FeatureNames: xgbModel.FeatureNames,
+		Predictions:  predictions,
+	}, http.StatusOK, l)
+	return nil
+}
+
+// featureRow - пропущенные значения признаков (null) xgboost обрабатывает как NaN
+func featureRow(values []*float64) []float64 {
+	row := make([]float64, len(values))
+	for i, value := range values {
+		row[i] = math.NaN()
+		if value != nil {
+			row[i] = *value
+		}
+	}
+	return row
+}
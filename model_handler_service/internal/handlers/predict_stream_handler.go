@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"go.uber.org/zap"
+	"net/http"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+	"strconv"
+)
+
+// PredictStream godoc
+//
+//	@Summary		Поток предсказаний усталости по WebSocket
+//	@Description	Клиент отправляет признаки кадров сообщениями fixtures.StreamFrame, на каждый кадр сервер отвечает
+//	@Description	fixtures.StreamPrediction. Состояние is_tired сглаживается так же, как в десктопном приложении:
+//	@Description	пользователь считается уставшим, если уставшими были все кадры последнего окна.
+//	@Description	Модель загружается при подключении и не меняется до конца потока
+//	@ID				predict stream
+//	@Tags			Models
+//	@Param			user_id		query		string	true	"ID пользователя"
+//	@Param			model_type	query		string	true	"Тип модели"
+//	@Param			window		query		int		false	"Размер окна сглаживания в кадрах"
+//	@Success		101
+//	@Failure		400	{object}	app_errors.AppError
+//	@Failure		404	{object}	app_errors.AppError
+//	@Router			/predict/stream [get]
+func (c *CoreHandler) PredictStream(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.PredictStream"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty user_id")
+	}
+	modelType := r.URL.Query().Get("model_type")
+	if modelType == "" {
+		return app_errors.ErrValidationError.WrapError(op, "empty model_type")
+	}
+
+	var window int
+	if windowString := r.URL.Query().Get("window"); windowString != "" {
+		var err error
+		window, err = strconv.Atoi(windowString)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+	}
+
+	smoother, err := c.predictor.NewSmoother(window)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Модель находим до установки соединения, чтобы ошибки вернулись обычным http ответом
+	model, err := c.featureRepository.GetModelByUserID(r.Context(), userID, modelType)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if model.S3Key == nil {
+		return app_errors.ErrNotFound.WrapError(op, "model is not trained yet")
+	}
+
+	xgbModel, err := c.predictor.Model(r.Context(), *model.S3Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept сам отвечает клиенту при неудачном рукопожатии
+		l.Error(fmt.Errorf("%s: %w", op, err).Error())
+		return nil
+	}
+	defer conn.CloseNow()
+
+	l = l.With(zap.String("user_id", userID), zap.String("model_type", modelType), zap.String("s3_key", *model.S3Key))
+	l.Info(fmt.Sprintf("%s: stream opened", op))
+
+	// После рукопожатия ошибки уже нельзя вернуть http ответом, поэтому они логируются и закрывают соединение
+	for frame := 0; ; frame++ {
+		var req fixtures.StreamFrame
+		// При невалидном JSON wsjson сам закрывает соединение
+		err = wsjson.Read(r.Context(), conn, &req)
+		if err != nil {
+			status := websocket.CloseStatus(err)
+			if status == websocket.StatusNormalClosure || status == websocket.StatusGoingAway {
+				l.With(zap.Int("frames", frame)).Info(fmt.Sprintf("%s: stream closed", op))
+				return nil
+			}
+			l.Error(fmt.Errorf("%s: %w", op, err).Error())
+			return nil
+		}
+
+		resp := fixtures.StreamPrediction{
+			Frame:  frame,
+			Window: smoother.Window(),
+		}
+
+		// Кадр с неверным числом признаков не меняет окно, клиенту возвращается ошибка и поток продолжается
+		probabilities, err := xgbModel.Predict([][]float64{featureRow(req.Features)})
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Tired = probabilities[0]
+			resp.Awake = 1 - probabilities[0]
+			smoother.Push(probabilities[0])
+		}
+		resp.IsTired = smoother.IsTired()
+		resp.TiredFrames = smoother.TiredFrames()
+
+		err = wsjson.Write(r.Context(), conn, resp)
+		if err != nil {
+			l.Error(fmt.Errorf("%s: %w", op, err).Error())
+			return nil
+		}
+	}
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/inference"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/s3_client"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
@@ -16,6 +19,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type FeatureRepository interface {
@@ -25,12 +29,55 @@ type FeatureRepository interface {
 	GetModelsByUserID(ctx context.Context, userID string) ([]data.MLModel, error)
 	SetFeaturesCountUsed(ctx context.Context, userID, modelType string, faceFeaturesCount int) error
 	SetModelS3Key(ctx context.Context, s3Key, modelType, userID string) error
+	SetModelChecksum(ctx context.Context, checksum, modelType, userID string) error
 	SetModelStatus(ctx context.Context, status string, modelType string, userID string) error
+	SetNeedsRetrain(ctx context.Context, userID, modelType string, needsRetrain bool) error
+	SetLastTrainedAt(ctx context.Context, userID, modelType string, trainedAt time.Time) error
+	GetTrainJobs(ctx context.Context, userID string, modelType *string) ([]data.TrainJob, error)
+	ViewUntrainedUserIDs(ctx context.Context, modelType string) ([]string, error)
+	GetTrainThresholds(ctx context.Context) ([]data.TrainThreshold, error)
+	GetTrainThreshold(ctx context.Context, modelType string) (*data.TrainThreshold, error)
+	CreateTrainThreshold(ctx context.Context, threshold data.TrainThreshold) (*data.TrainThreshold, error)
+	UpdateTrainThreshold(ctx context.Context, threshold data.TrainThreshold) (*data.TrainThreshold, error)
+	DeleteTrainThreshold(ctx context.Context, modelType string) error
+	GetModelTypes(ctx context.Context) ([]data.ModelType, error)
+	GetModelType(ctx context.Context, modelType string) (*data.ModelType, error)
+	CreateModelType(ctx context.Context, modelType data.ModelType) (*data.ModelType, error)
+	SetModelTypeFeaturesSchema(ctx context.Context, modelType string, featuresSchema []data.FeatureColumn) (*data.ModelType, error)
+	DeleteModelType(ctx context.Context, modelType string) error
+	SetUserOrganization(ctx context.Context, userID, organizationID string) error
+	DeleteUserOrganization(ctx context.Context, userID string) error
+	CreateWebhookEndpoint(ctx context.Context, endpoint data.WebhookEndpoint) (*data.WebhookEndpoint, error)
+	GetWebhookEndpoints(ctx context.Context, organizationID *string) ([]data.WebhookEndpoint, error)
+	SetWebhookEndpointActive(ctx context.Context, endpointID string, isActive bool) (*data.WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, endpointID string) error
+	EnqueueWebhookEvent(ctx context.Context, event data.WebhookEvent) (int64, error)
+	RetryWebhookDelivery(ctx context.Context, deliveryID int64) (*data.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, filter data.WebhookDeliveriesFilter, beforeID int64, limit uint64) ([]data.WebhookDelivery, error)
+	GetPreviousModelS3Key(ctx context.Context, userID, modelType, s3Key string) (string, error)
+	CreateModelEvaluation(ctx context.Context, evaluation data.ModelEvaluation) (*data.ModelEvaluation, error)
+	GetModelEvaluation(ctx context.Context, evaluationID string) (*data.ModelEvaluation, error)
+	GetModelEvaluations(ctx context.Context, userID string, modelType *string) ([]data.ModelEvaluation, error)
+	AddHeldOutVideos(ctx context.Context, userID, modelType string, videoIDs []string) error
+	GetHeldOutVideos(ctx context.Context, userID, modelType string) ([]data.HeldOutVideo, error)
+	GetModelTrainQueuedAt(ctx context.Context, s3Key string) (time.Time, error)
 }
 
 type ModelSaver interface {
-	SaveFile(ctx context.Context, fileName string, file io.Reader) error
-	GetPresignURL(ctx context.Context, fileName string) (string, error)
+	SaveFile(ctx context.Context, fileName string, file io.ReadSeeker, contentType string) (string, error)
+	GetPresignURL(ctx context.Context, fileName string, expires time.Duration) (string, error)
+	GetPresignUploadURL(ctx context.Context, fileName, checksum, contentType string,
+		expires time.Duration) (*s3_client.PresignedUpload, error)
+	VerifyFile(ctx context.Context, fileName string) (string, error)
+	FileExists(ctx context.Context, fileName string) (bool, error)
+	DeleteFile(ctx context.Context, fileName string) error
+}
+
+// PresignExpiry - время жизни предподписанных ссылок: Download - ссылок на скачивание моделей,
+// Upload - ссылок на загрузку обученных моделей тренером
+type PresignExpiry struct {
+	Download time.Duration
+	Upload   time.Duration
 }
 
 type Producer interface {
@@ -40,9 +87,16 @@ type Producer interface {
 type CoreHandler struct {
 	featureRepository FeatureRepository
 	modelSaver        ModelSaver
-	resultQueue       string
 	transactor        postgresql.Transactor
+	predictor         *inference.Predictor
 	validator         *validator.Validate
+	presignExpiry     PresignExpiry
+	// maxPriority - x-max-priority очередей задач, приоритеты порогов обучения не должны его превышать
+	maxPriority uint8
+	// serviceToken - общий токен внутренних сервисов, без него недоступны административные запросы
+	serviceToken string
+	// schemasURL - схемы признаков face_features_storage, к которым применяются схемы из справочника типов
+	schemasURL string
 
 	logger *zap.Logger
 }
@@ -50,13 +104,23 @@ type CoreHandler struct {
 func NewCoreHandler(modelSaver ModelSaver,
 	featureRepository FeatureRepository,
 	transactor postgresql.Transactor,
+	predictor *inference.Predictor,
 	validator *validator.Validate,
+	presignExpiry PresignExpiry,
+	maxPriority uint8,
+	serviceToken string,
+	schemasURL string,
 	logger *zap.Logger) *CoreHandler {
 	return &CoreHandler{
 		featureRepository: featureRepository,
 		transactor:        transactor,
 		modelSaver:        modelSaver,
+		predictor:         predictor,
 		validator:         validator,
+		presignExpiry:     presignExpiry,
+		maxPriority:       maxPriority,
+		serviceToken:      serviceToken,
+		schemasURL:        schemasURL,
 		logger:            logger,
 	}
 }
@@ -96,6 +160,25 @@ func ErrorMiddleware(handler ErrorHandlerFunc) http.HandlerFunc {
 	}
 }
 
+const ServiceTokenHeader = "X-Service-Token"
+
+// RequireServiceToken - пропускает только запросы внутренних сервисов с общим токеном в заголовке X-Service-Token
+func (c *CoreHandler) RequireServiceToken(handler ErrorHandlerFunc) ErrorHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		op := "handlers.CoreHandler.RequireServiceToken"
+
+		token := r.Header.Get(ServiceTokenHeader)
+		if token == "" {
+			return app_errors.ErrNoAuthorizationHeader.WrapError(op, "empty service token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.serviceToken)) != 1 {
+			return app_errors.ErrWrongToken.WrapError(op, "wrong service token")
+		}
+
+		return handler(w, r)
+	}
+}
+
 func (c *CoreHandler) Router() chi.Router {
 	router := chi.NewRouter()
 
@@ -104,8 +187,61 @@ func (c *CoreHandler) Router() chi.Router {
 
 	router.Route("/api/v1", func(router chi.Router) {
 		router.Post("/save_model", ErrorMiddleware(c.SaveModel))
+		router.Post("/model_uploads", ErrorMiddleware(c.CreateModelUpload))
+		router.Post("/model_uploads/complete", ErrorMiddleware(c.CompleteModelUpload))
 		router.Post("/increase_features", ErrorMiddleware(c.IncreaseFeatures))
 		router.Post("/get_models", ErrorMiddleware(c.GetModels))
+		router.Get("/untrained_users", ErrorMiddleware(c.GetUntrainedUsers))
+		router.Get("/train_jobs", ErrorMiddleware(c.GetTrainJobs))
+		router.Post("/predict", ErrorMiddleware(c.Predict))
+		router.Get("/predict/stream", ErrorMiddleware(c.PredictStream))
+
+		// справочник типов моделей читают клиенты и сервисы, а изменяет только администрирование
+		router.Route("/model_types", func(router chi.Router) {
+			router.Get("/", ErrorMiddleware(c.GetModelTypes))
+			router.Post("/", ErrorMiddleware(c.RequireServiceToken(c.CreateModelType)))
+			router.Get("/{model_type}", ErrorMiddleware(c.GetModelType))
+			router.Delete("/{model_type}", ErrorMiddleware(c.RequireServiceToken(c.DeleteModelType)))
+			router.Put("/{model_type}/features_schema", ErrorMiddleware(c.RequireServiceToken(c.SetModelTypeFeaturesSchema)))
+		})
+
+		// пороги определяют, когда и с каким приоритетом обучаются модели, поэтому изменять их может только администрирование
+		router.Route("/train_thresholds", func(router chi.Router) {
+			router.Get("/", ErrorMiddleware(c.GetTrainThresholds))
+			router.Post("/", ErrorMiddleware(c.RequireServiceToken(c.CreateTrainThreshold)))
+			router.Get("/{model_type}", ErrorMiddleware(c.GetTrainThreshold))
+			router.Put("/{model_type}", ErrorMiddleware(c.RequireServiceToken(c.UpdateTrainThreshold)))
+			router.Delete("/{model_type}", ErrorMiddleware(c.RequireServiceToken(c.DeleteTrainThreshold)))
+		})
+
+		// отложенные видео и сравнения версий моделей задает только администрирование
+		router.Route("/held_out_videos", func(router chi.Router) {
+			router.Get("/", ErrorMiddleware(c.GetHeldOutVideos))
+			router.Post("/", ErrorMiddleware(c.RequireServiceToken(c.AddHeldOutVideos)))
+		})
+
+		router.Route("/evaluations", func(router chi.Router) {
+			router.Get("/", ErrorMiddleware(c.GetModelEvaluations))
+			router.Post("/", ErrorMiddleware(c.RequireServiceToken(c.CreateModelEvaluation)))
+			router.Get("/{evaluation_id}", ErrorMiddleware(c.GetModelEvaluation))
+		})
+
+		// вебхуки и организации пользователей настраивает только администрирование через внутренние сервисы:
+		// иначе любой клиент мог бы подписаться на события чужих пользователей или разослать подписанное событие
+		router.Route("/organization_users", func(router chi.Router) {
+			router.Put("/{user_id}", ErrorMiddleware(c.RequireServiceToken(c.SetUserOrganization)))
+			router.Delete("/{user_id}", ErrorMiddleware(c.RequireServiceToken(c.DeleteUserOrganization)))
+		})
+
+		router.Route("/webhooks", func(router chi.Router) {
+			router.Get("/endpoints", ErrorMiddleware(c.RequireServiceToken(c.GetWebhookEndpoints)))
+			router.Post("/endpoints", ErrorMiddleware(c.RequireServiceToken(c.CreateWebhookEndpoint)))
+			router.Patch("/endpoints/{endpoint_id}", ErrorMiddleware(c.RequireServiceToken(c.SetWebhookEndpointActive)))
+			router.Delete("/endpoints/{endpoint_id}", ErrorMiddleware(c.RequireServiceToken(c.DeleteWebhookEndpoint)))
+			router.Get("/deliveries", ErrorMiddleware(c.RequireServiceToken(c.GetWebhookDeliveries)))
+			router.Post("/deliveries/{delivery_id}/retry", ErrorMiddleware(c.RequireServiceToken(c.RetryWebhookDelivery)))
+			router.Post("/events", ErrorMiddleware(c.RequireServiceToken(c.PublishWebhookEvents)))
+		})
 	})
 
 	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
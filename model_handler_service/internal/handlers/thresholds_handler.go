@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// GetTrainThresholds godoc
+//
+//	@Summary	Возвращает пороги обучения для всех типов моделей
+//	@ID			get train thresholds
+//	@Tags		Thresholds
+//	@Success	200	{array}		data.TrainThreshold
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/train_thresholds [get]
+func (c *CoreHandler) GetTrainThresholds(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetTrainThresholds"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	thresholds, err := c.featureRepository.GetTrainThresholds(r.Context())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, thresholds, http.StatusOK, l)
+	return nil
+}
+
+// GetTrainThreshold godoc
+//
+//	@Summary	Возвращает пороги обучения для типа модели
+//	@ID			get train threshold
+//	@Tags		Thresholds
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Success	200			{object}	data.TrainThreshold
+//	@Failure	404			{object}	app_errors.AppError
+//	@Router		/train_thresholds/{model_type} [get]
+func (c *CoreHandler) GetTrainThreshold(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetTrainThreshold"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	threshold, err := c.featureRepository.GetTrainThreshold(r.Context(), chi.URLParam(r, "model_type"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, threshold, http.StatusOK, l)
+	return nil
+}
+
+// CreateTrainThreshold godoc
+//
+//	@Summary	Задает пороги обучения для нового типа модели
+//	@ID			create train threshold
+//	@Tags		Thresholds
+//	@Param		X-Service-Token	header		string									true	"Токен внутренних сервисов"
+//	@Param		threshold		body		fixtures.CreateTrainThresholdRequest	true	"Пороги обучения"
+//	@Success	201				{object}	data.TrainThreshold
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	401				{object}	app_errors.AppError
+//	@Failure	409				{object}	app_errors.AppError
+//	@Router		/train_thresholds [post]
+func (c *CoreHandler) CreateTrainThreshold(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CreateTrainThreshold"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CreateTrainThresholdRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Приоритеты выше x-max-priority брокер не различает
+	err = req.Priority.Validate(c.maxPriority)
+	if err != nil {
+		return err
+	}
+
+	threshold, err := c.featureRepository.CreateTrainThreshold(r.Context(), data.TrainThreshold{
+		ModelType:      req.ModelType,
+		TrainThreshold: req.TrainThreshold,
+		TuneThreshold:  req.TuneThreshold,
+		Priority:       req.Priority,
+		WarmStart:      req.WarmStart,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем созданные пороги со статусом 201
+	api.WriteSuccess(r.Context(), w, threshold, http.StatusCreated, l)
+	return nil
+}
+
+// UpdateTrainThreshold godoc
+//
+//	@Summary	Изменяет пороги обучения для типа модели, изменения применяются на следующем запуске тренера
+//	@ID			update train threshold
+//	@Tags		Thresholds
+//	@Param		X-Service-Token	header		string									true	"Токен внутренних сервисов"
+//	@Param		model_type		path		string									true	"Тип модели"
+//	@Param		threshold		body		fixtures.UpdateTrainThresholdRequest	true	"Пороги обучения"
+//	@Success	200				{object}	data.TrainThreshold
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	401				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/train_thresholds/{model_type} [put]
+func (c *CoreHandler) UpdateTrainThreshold(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.UpdateTrainThreshold"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.UpdateTrainThresholdRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// Приоритеты выше x-max-priority брокер не различает
+	err = req.Priority.Validate(c.maxPriority)
+	if err != nil {
+		return err
+	}
+
+	threshold, err := c.featureRepository.UpdateTrainThreshold(r.Context(), data.TrainThreshold{
+		ModelType:      chi.URLParam(r, "model_type"),
+		TrainThreshold: req.TrainThreshold,
+		TuneThreshold:  req.TuneThreshold,
+		Priority:       req.Priority,
+		WarmStart:      req.WarmStart,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем обновленные пороги со статусом 200
+	api.WriteSuccess(r.Context(), w, threshold, http.StatusOK, l)
+	return nil
+}
+
+// DeleteTrainThreshold godoc
+//
+//	@Summary	Удаляет пороги обучения, после чего тренер перестает отправлять задачи для типа модели
+//	@ID			delete train threshold
+//	@Tags		Thresholds
+//	@Param		X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param		model_type		path	string	true	"Тип модели"
+//	@Success	204
+//	@Failure	401	{object}	app_errors.AppError
+//	@Failure	404	{object}	app_errors.AppError
+//	@Router		/train_thresholds/{model_type} [delete]
+func (c *CoreHandler) DeleteTrainThreshold(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.DeleteTrainThreshold"
+
+	err := c.featureRepository.DeleteTrainThreshold(r.Context(), chi.URLParam(r, "model_type"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем пустой ответ со статусом 204
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/handlers/fixtures"
+	customTools "github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/api"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/tools"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// webhookSecretBytes - длина генерируемого секрета подписи
+	webhookSecretBytes = 32
+	// defaultDeliveriesPageSize - размер страницы журнала доставок, если клиент не задал limit
+	defaultDeliveriesPageSize = 100
+	// maxDeliveriesPageSize - максимальный размер страницы журнала доставок
+	maxDeliveriesPageSize = 1000
+
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// SetUserOrganization godoc
+//
+//	@Summary	Привязывает пользователя к организации, события пользователя рассылаются в вебхуки организации
+//	@ID			set user organization
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header	string								true	"Токен внутренних сервисов"
+//	@Param		user_id			path	string								true	"ID пользователя"
+//	@Param		organization	body	fixtures.SetUserOrganizationRequest	true	"Организация"
+//	@Success	204
+//	@Failure	400	{object}	app_errors.AppError
+//	@Failure	401	{object}	app_errors.AppError
+//	@Router		/organization_users/{user_id} [put]
+func (c *CoreHandler) SetUserOrganization(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.SetUserOrganization"
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.SetUserOrganizationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	err = c.featureRepository.SetUserOrganization(r.Context(), chi.URLParam(r, "user_id"), req.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем пустой ответ со статусом 204
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// DeleteUserOrganization godoc
+//
+//	@Summary	Отвязывает пользователя от организации
+//	@ID			delete user organization
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param		user_id			path	string	true	"ID пользователя"
+//	@Success	204
+//	@Failure	401	{object}	app_errors.AppError
+//	@Failure	404	{object}	app_errors.AppError
+//	@Router		/organization_users/{user_id} [delete]
+func (c *CoreHandler) DeleteUserOrganization(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.DeleteUserOrganization"
+
+	err := c.featureRepository.DeleteUserOrganization(r.Context(), chi.URLParam(r, "user_id"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем пустой ответ со статусом 204
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// CreateWebhookEndpoint godoc
+//
+//	@Summary		Регистрирует вебхук организации
+//	@Description	Тело каждого запроса подписывается HMAC-SHA256 от строки "<X-Webhook-Timestamp>.<тело>" с секретом вебхука,
+//	@Description	подпись передается в заголовке X-Webhook-Signature в виде "sha256=<hex>". Секрет возвращается только в ответе на создание
+//	@ID				create webhook endpoint
+//	@Tags			Webhooks
+//	@Param			X-Service-Token	header		string									true	"Токен внутренних сервисов"
+//	@Param			endpoint		body		fixtures.CreateWebhookEndpointRequest	true	"Вебхук"
+//	@Success		201				{object}	fixtures.CreateWebhookEndpointResponse
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/webhooks/endpoints [post]
+func (c *CoreHandler) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.CreateWebhookEndpoint"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.CreateWebhookEndpointRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	// запросы в вебхук отправляет сервис, поэтому адрес не должен указывать на него самого или во внутреннюю сеть
+	err = webhook.ValidateURL(req.URL)
+	if err != nil {
+		return app_errors.ErrValidationError.WrapError(op, err.Error())
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	endpointID, err := uuid.NewUUID()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	endpoint, err := c.featureRepository.CreateWebhookEndpoint(r.Context(), data.WebhookEndpoint{
+		EndpointID:     endpointID.String(),
+		OrganizationID: req.OrganizationID,
+		URL:            req.URL,
+		Secret:         secret,
+		EventTypes:     req.EventTypes,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем созданный вебхук вместе с секретом со статусом 201
+	api.WriteSuccess(r.Context(), w, fixtures.CreateWebhookEndpointResponse{
+		WebhookEndpoint: *endpoint,
+		Secret:          endpoint.Secret,
+	}, http.StatusCreated, l)
+	return nil
+}
+
+// GetWebhookEndpoints godoc
+//
+//	@Summary	Возвращает вебхуки организаций
+//	@ID			get webhook endpoints
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header		string	true	"Токен внутренних сервисов"
+//	@Param		organization_id	query		string	false	"ID организации"
+//	@Success	200				{array}		data.WebhookEndpoint
+//	@Failure	400				{object}	app_errors.AppError
+//	@Failure	401				{object}	app_errors.AppError
+//	@Router		/webhooks/endpoints [get]
+func (c *CoreHandler) GetWebhookEndpoints(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetWebhookEndpoints"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	var organizationID *string
+	if organizationIDString := r.URL.Query().Get("organization_id"); organizationIDString != "" {
+		organizationID = &organizationIDString
+	}
+
+	endpoints, err := c.featureRepository.GetWebhookEndpoints(r.Context(), organizationID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, endpoints, http.StatusOK, l)
+	return nil
+}
+
+// SetWebhookEndpointActive godoc
+//
+//	@Summary	Приостанавливает или возобновляет рассылку событий в вебхук
+//	@ID			set webhook endpoint active
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header		string										true	"Токен внутренних сервисов"
+//	@Param		endpoint_id		path		string										true	"ID вебхука"
+//	@Param		endpoint		body		fixtures.SetWebhookEndpointActiveRequest	true	"Активность вебхука"
+//	@Success	200				{object}	data.WebhookEndpoint
+//	@Failure	401				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/webhooks/endpoints/{endpoint_id} [patch]
+func (c *CoreHandler) SetWebhookEndpointActive(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.SetWebhookEndpointActive"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.SetWebhookEndpointActiveRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	endpoint, err := c.featureRepository.SetWebhookEndpointActive(r.Context(), chi.URLParam(r, "endpoint_id"), req.IsActive)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, endpoint, http.StatusOK, l)
+	return nil
+}
+
+// DeleteWebhookEndpoint godoc
+//
+//	@Summary	Удаляет вебхук вместе с журналом доставок
+//	@ID			delete webhook endpoint
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header	string	true	"Токен внутренних сервисов"
+//	@Param		endpoint_id		path	string	true	"ID вебхука"
+//	@Success	204
+//	@Failure	401	{object}	app_errors.AppError
+//	@Failure	404	{object}	app_errors.AppError
+//	@Router		/webhooks/endpoints/{endpoint_id} [delete]
+func (c *CoreHandler) DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.DeleteWebhookEndpoint"
+
+	err := c.featureRepository.DeleteWebhookEndpoint(r.Context(), chi.URLParam(r, "endpoint_id"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем пустой ответ со статусом 204
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		Возвращает журнал доставок событий в вебхуки, начиная с последних
+//	@Description	Курсор следующей страницы передается в заголовке X-Next-Cursor
+//	@ID				get webhook deliveries
+//	@Tags			Webhooks
+//	@Param			X-Service-Token	header		string	true	"Токен внутренних сервисов"
+//	@Param			endpoint_id		query		string	false	"ID вебхука"
+//	@Param			event_id		query		string	false	"ID события"
+//	@Param			status			query		string	false	"Статус доставки"	Enums(pending, delivered, failed)
+//	@Param			before			query		int		false	"Курсор: доставки до данного id"
+//	@Param			limit			query		int		false	"Размер страницы"
+//	@Success		200				{array}		data.WebhookDelivery
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/webhooks/deliveries [get]
+func (c *CoreHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.GetWebhookDeliveries"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	query := r.URL.Query()
+
+	var filter data.WebhookDeliveriesFilter
+	if endpointID := query.Get("endpoint_id"); endpointID != "" {
+		filter.EndpointID = tools.PString(endpointID)
+	}
+	if eventID := query.Get("event_id"); eventID != "" {
+		filter.EventID = tools.PString(eventID)
+	}
+	switch status := query.Get("status"); status {
+	case "":
+	case data.DeliveryStatusPending, data.DeliveryStatusDelivered, data.DeliveryStatusFailed:
+		filter.Status = tools.PString(status)
+	default:
+		return app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("unknown status: %s", status))
+	}
+
+	var beforeID int64
+	var err error
+	if before := query.Get("before"); before != "" {
+		beforeID, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+	}
+
+	limit := uint64(defaultDeliveriesPageSize)
+	if limitString := query.Get("limit"); limitString != "" {
+		limit, err = strconv.ParseUint(limitString, 10, 64)
+		if err != nil {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		if limit == 0 || limit > maxDeliveriesPageSize {
+			return app_errors.ErrValidationError.WrapError(op,
+				fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesPageSize))
+		}
+	}
+
+	deliveries, err := c.featureRepository.GetWebhookDeliveries(r.Context(), filter, beforeID, limit)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if uint64(len(deliveries)) == limit {
+		w.Header().Set(NextCursorHeader, strconv.FormatInt(deliveries[len(deliveries)-1].DeliveryID, 10))
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, deliveries, http.StatusOK, l)
+	return nil
+}
+
+// RetryWebhookDelivery godoc
+//
+//	@Summary	Повторно ставит в очередь доставку, исчерпавшую попытки
+//	@ID			retry webhook delivery
+//	@Tags		Webhooks
+//	@Param		X-Service-Token	header		string	true	"Токен внутренних сервисов"
+//	@Param		delivery_id		path		int		true	"ID доставки"
+//	@Success	200				{object}	data.WebhookDelivery
+//	@Failure	401				{object}	app_errors.AppError
+//	@Failure	404				{object}	app_errors.AppError
+//	@Router		/webhooks/deliveries/{delivery_id}/retry [post]
+func (c *CoreHandler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.RetryWebhookDelivery"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "delivery_id"), 10, 64)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	delivery, err := c.featureRepository.RetryWebhookDelivery(r.Context(), deliveryID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Возвращаем результат со статусом 200
+	api.WriteSuccess(r.Context(), w, delivery, http.StatusOK, l)
+	return nil
+}
+
+// PublishWebhookEvents godoc
+//
+//	@Summary		Принимает события других сервисов системы (например, предупреждения об усталости) для рассылки в вебхуки
+//	@Description	Доступна только внутренним сервисам.
+//	@ID				publish webhook events
+//	@Tags			Webhooks
+//	@Param			X-Service-Token	header		string									true	"Токен внутренних сервисов"
+//	@Param			events			body		fixtures.PublishWebhookEventsRequest	true	"События"
+//	@Success		202				{object}	fixtures.PublishWebhookEventsResponse
+//	@Failure		400				{object}	app_errors.AppError
+//	@Failure		401				{object}	app_errors.AppError
+//	@Router			/webhooks/events [post]
+func (c *CoreHandler) PublishWebhookEvents(w http.ResponseWriter, r *http.Request) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.PublishWebhookEvents"
+	// Берем логгер из контекста
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	// Десереализуем данные из тела запроса
+	var req fixtures.PublishWebhookEventsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// Валидируем данные на наличие необходимых полей
+	appErr := customTools.ValidateStruct(c.validator, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	resp := fixtures.PublishWebhookEventsResponse{
+		EventIDs: make([]string, 0, len(req.Events)),
+	}
+
+	// Ставим в очередь все события пакета или ни одного
+	txErr := c.transactor.WithinTransaction(r.Context(), func(txCtx context.Context) error {
+		for _, reqEvent := range req.Events {
+			event := data.NewWebhookEvent(reqEvent.EventType, reqEvent.UserID, reqEvent.Data)
+
+			deliveries, err := c.featureRepository.EnqueueWebhookEvent(txCtx, event)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			resp.EventIDs = append(resp.EventIDs, event.EventID)
+			resp.Deliveries += deliveries
+		}
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	// События будут отправлены диспетчером вебхуков, возвращаем ответ со статусом 202
+	api.WriteSuccess(r.Context(), w, resp, http.StatusAccepted, l)
+	return nil
+}
+
+// newWebhookSecret - генерирует случайный секрет подписи
+func newWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,120 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/xgboost"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"io"
+)
+
+type Config struct {
+	// ModelCacheSize - сколько разобранных моделей держать в памяти
+	ModelCacheSize int
+	// SmoothingWindow - размер окна сглаживания по умолчанию, как LimitedSizeArray в десктопном приложении
+	SmoothingWindow int
+	// MaxSmoothingWindow - наибольшее окно, которое может запросить клиент
+	MaxSmoothingWindow int
+}
+
+type FileGetter interface {
+	GetFile(ctx context.Context, fileName string) (io.ReadCloser, error)
+}
+
+// Predictor - загружает модели пользователей из s3 и кэширует их с вытеснением давно не использованных (LRU).
+// Ключом кэша служит s3 ключ модели: при каждом обучении он новый, поэтому переобученная модель
+// подхватывается без явной инвалидации, а старая со временем вытесняется
+type Predictor struct {
+	cfg   Config
+	files FileGetter
+	cache *lru.Cache[string, *xgboost.Model]
+	// loads - объединяет одновременные загрузки одной модели, например при подключении нескольких потоков
+	loads singleflight.Group
+}
+
+func NewPredictor(cfg Config, files FileGetter) (*Predictor, error) {
+	op := "inference.NewPredictor"
+
+	if cfg.SmoothingWindow <= 0 || cfg.SmoothingWindow > cfg.MaxSmoothingWindow {
+		return nil, fmt.Errorf("%s: smoothing window %d is out of [1, %d]", op, cfg.SmoothingWindow, cfg.MaxSmoothingWindow)
+	}
+
+	cache, err := lru.New[string, *xgboost.Model](cfg.ModelCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Predictor{
+		cfg:   cfg,
+		files: files,
+		cache: cache,
+	}, nil
+}
+
+// Model - возвращает модель из кэша или скачивает и разбирает ее
+func (p *Predictor) Model(ctx context.Context, s3Key string) (*xgboost.Model, error) {
+	op := "inference.Predictor.Model"
+
+	if model, ok := p.cache.Get(s3Key); ok {
+		return model, nil
+	}
+
+	model, err, _ := p.loads.Do(s3Key, func() (any, error) {
+		model, err := p.loadModel(ctx, s3Key)
+		if err != nil {
+			return nil, err
+		}
+		p.cache.Add(s3Key, model)
+		return model, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return model.(*xgboost.Model), nil
+}
+
+// loadModel - скачивает модель из s3 и разбирает ее
+func (p *Predictor) loadModel(ctx context.Context, s3Key string) (*xgboost.Model, error) {
+	op := "inference.Predictor.loadModel"
+	l := logger.EntryWithRequestIDFromContext(ctx)
+
+	file, err := p.files.GetFile(ctx, s3Key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// Закрываем файл при завершении функции
+	defer func(file io.ReadCloser) {
+		err := file.Close()
+		if err != nil {
+			l.Error(fmt.Errorf("%s: %w", op, err).Error())
+		}
+	}(file)
+
+	model, err := xgboost.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.With(zap.String("s3_key", s3Key), zap.Int("features", model.NumFeature)).Info(fmt.Sprintf("%s: model loaded", op))
+
+	return model, nil
+}
+
+// NewSmoother - создает окно сглаживания; window = 0 означает размер окна по умолчанию
+func (p *Predictor) NewSmoother(window int) (*Smoother, error) {
+	op := "inference.Predictor.NewSmoother"
+
+	if window == 0 {
+		window = p.cfg.SmoothingWindow
+	}
+	if window < 0 || window > p.cfg.MaxSmoothingWindow {
+		return nil, app_errors.ErrValidationError.WrapError(op, fmt.Sprintf("window must be in [1, %d]", p.cfg.MaxSmoothingWindow))
+	}
+
+	return NewSmoother(window), nil
+}
@@ -0,0 +1,51 @@
+package inference
+
+// tiredThreshold - предсказание не меньше порога считается кадром, на котором пользователь устал
+const tiredThreshold = 0.5
+
+// Smoother - серверный аналог LimitedSizeArray из десктопного приложения: хранит последние window решений по кадрам
+// и считает пользователя уставшим, только если уставшими были все кадры окна.
+// Изначально окно заполнено бодрыми кадрами, поэтому первые window-1 кадров состояние не меняют
+type Smoother struct {
+	frames    []bool
+	next      int
+	awakeSeen int
+}
+
+func NewSmoother(window int) *Smoother {
+	return &Smoother{
+		frames:    make([]bool, window),
+		awakeSeen: window,
+	}
+}
+
+// Push - добавляет вероятность усталости очередного кадра, вытесняя самый старый, и возвращает сглаженное состояние
+func (s *Smoother) Push(tiredProbability float64) bool {
+	tired := tiredProbability >= tiredThreshold
+
+	if !s.frames[s.next] {
+		s.awakeSeen--
+	}
+	if !tired {
+		s.awakeSeen++
+	}
+	s.frames[s.next] = tired
+	s.next = (s.next + 1) % len(s.frames)
+
+	return s.IsTired()
+}
+
+// IsTired - пользователь устал, если в окне не осталось бодрых кадров
+func (s *Smoother) IsTired() bool {
+	return s.awakeSeen == 0
+}
+
+// TiredFrames - количество уставших кадров в окне
+func (s *Smoother) TiredFrames() int {
+	return len(s.frames) - s.awakeSeen
+}
+
+// Window - размер окна сглаживания
+func (s *Smoother) Window() int {
+	return len(s.frames)
+}
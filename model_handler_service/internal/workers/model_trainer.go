@@ -4,41 +4,58 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
 	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
 	"github.com/go-co-op/gocron"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type ViewModelRepository interface {
 	ViewNotLearnedModels(ctx context.Context, modelType string, trainThreshold uint64) ([]data.MLModel, error)
 	ViewNotFineTunedFaceModels(ctx context.Context, modelType string, tuneThreshold uint64) ([]data.MLModel, error)
 	SetModelStatus(ctx context.Context, status string, modelType string, userID string) error
+	SetNeedsRetrain(ctx context.Context, userID, modelType string, needsRetrain bool) error
+	CreateTrainJob(ctx context.Context, job data.TrainJob) error
+	TryLockTrainDispatch(ctx context.Context) (bool, error)
+	GetTrainThresholds(ctx context.Context) ([]data.TrainThreshold, error)
+	GetModelByUserID(ctx context.Context, userID, modelType string) (*data.MLModel, error)
+	GetGlobalModel(ctx context.Context, modelType string) (*data.MLModel, error)
+	GetTotalFeaturesCount(ctx context.Context, modelType string) (uint64, error)
+	SetGlobalModelFeaturesCount(ctx context.Context, modelType string, featuresCount uint64) error
+	ViewPendingModelEvaluations(ctx context.Context) ([]data.ModelEvaluation, error)
+	SetModelEvaluationQueued(ctx context.Context, evaluationID, jobID string) error
+	GetHeldOutVideoIDs(ctx context.Context, modelType string, userID *string) ([]string, error)
 }
 
 type Producer interface {
-	Publish(queue string, message []byte) error
+	InitQueues(queues []string) error
+	PublishWithPriority(queue string, message []byte, priority uint8) error
+	IsHealthy() bool
 }
 
 type Presigner interface {
-	GetPresignURL(ctx context.Context, fileName string) (string, error)
-}
-
-type ModelTrainThreshold struct {
-	TrainThreshold uint64 `json:"train_threshold"`
-	TuneThreshold  uint64 `json:"tune_threshold"`
+	GetPresignURL(ctx context.Context, fileName string, expires time.Duration) (string, error)
 }
 
 type ModelTrainer struct {
 	viewModelRepository ViewModelRepository
 	presigner           Presigner
-	transactor          postgresql.Transactor
+	// jobURLExpiry - время жизни ссылок на скачивание моделей в задачах, задача может долго ждать в очереди
+	jobURLExpiry time.Duration
+	// maxPriority - x-max-priority очередей задач, приоритеты задач ограничиваются этим значением
+	maxPriority uint8
+	transactor  postgresql.Transactor
 
 	producer        Producer
 	goCronScheduler *gocron.Scheduler
-	thresholds      map[string]ModelTrainThreshold
+	// declaredQueues - очереди типов моделей, уже объявленные в брокере
+	declaredQueues *sync.Map
 
 	logger *zap.Logger
 }
@@ -46,25 +63,29 @@ type ModelTrainer struct {
 func NewModelTrainer(
 	viewModelRepository ViewModelRepository,
 	presigner Presigner,
+	jobURLExpiry time.Duration,
+	maxPriority uint8,
 	transactor postgresql.Transactor,
 	producer Producer,
 	goCronScheduler *gocron.Scheduler,
-	thresholds map[string]ModelTrainThreshold,
 	logger *zap.Logger,
 ) *ModelTrainer {
 	return &ModelTrainer{
 		presigner:           presigner,
+		jobURLExpiry:        jobURLExpiry,
+		maxPriority:         maxPriority,
 		viewModelRepository: viewModelRepository,
 		transactor:          transactor,
 		producer:            producer,
 		goCronScheduler:     goCronScheduler,
-		thresholds:          thresholds,
+		declaredQueues:      &sync.Map{},
 		logger:              logger,
 	}
 }
 
-// StartTrainModels - функция запуска инициализации тренировки моделей по расписанию
-func (m ModelTrainer) StartTrainModels(cron string) {
+// StartTrainModels - функция запуска инициализации тренировки моделей по расписанию.
+// Глобальные модели обучаются по отдельному расписанию globalCron, пустое расписание отключает их обучение
+func (m ModelTrainer) StartTrainModels(cron, globalCron string) {
 	// Объявляем текущую операцию для оборачивания ошибки
 	op := "model_trainer.ModelTrainer.StartTrainModels"
 	// Формируем задачу по расписанию в формате cron
@@ -72,6 +93,12 @@ func (m ModelTrainer) StartTrainModels(cron string) {
 	if err != nil {
 		m.logger.Fatal(fmt.Sprintf("%s: %s", op, err.Error()))
 	}
+	if globalCron != "" {
+		_, err = m.goCronScheduler.CronWithSeconds(globalCron).Do(m.trainGlobalModels)
+		if err != nil {
+			m.logger.Fatal(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+	}
 	// Запускаем задачу синхронным методом
 	m.goCronScheduler.StartBlocking()
 }
@@ -81,20 +108,62 @@ func (m ModelTrainer) trainAndTuneModels() {
 	// Объявляем текущую операцию для оборачивания ошибки
 	op := "model_trainer.ModelTrainer.trainAndTuneModels"
 
+	// Пока нет соединения с брокером, задачи не отправляем, чтобы не менять статусы моделей впустую
+	if !m.producer.IsHealthy() {
+		m.logger.Warn(fmt.Sprintf("%s: broker is unavailable, skip tick", op))
+		return
+	}
+
 	// Кладем логгер в контекст
 	ctx := logger.ContextWithLogger(context.Background(), m.logger)
 
 	// Производим все операции изменения данных в транзакции
 	txErr := m.transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+		// Задачи рассылает только одна реплика за раз: блокировка снимается вместе с завершением транзакции,
+		// и следующая реплика уже видит обновленные статусы моделей
+		locked, err := m.viewModelRepository.TryLockTrainDispatch(txCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !locked {
+			m.logger.Debug(fmt.Sprintf("%s: dispatch is locked by another replica, skip tick", op))
+			return nil
+		}
+
+		// Пороги читаются на каждом запуске, чтобы изменения через API применялись без перезапуска
+		thresholds, err := m.viewModelRepository.GetTrainThresholds(txCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
 		// Перебираем все пороговые значение для моделей разных типов
-		for modelType, threshold := range m.thresholds {
-			// Находим модели определенного типа, количество признаков, у которых преодолел порог обучения
+		for _, threshold := range thresholds {
+			modelType := threshold.ModelType
+
+			// Очередь для типа модели, добавленного через API, объявляем перед первой отправкой
+			err = m.declareQueue(modelType)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// Находим модели определенного типа, количество признаков, у которых преодолел порог обучения,
+			// а также обученные модели, требующие переобучения после исправления меток
 			models, err := m.viewModelRepository.ViewNotLearnedModels(txCtx, modelType, threshold.TrainThreshold)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
 
+			// При теплом старте первое обучение дообучает глобальную модель, если она уже обучена.
+			// Переобучение после исправления меток идет с нуля, как и без теплого старта
+			var baseline *data.MLModel
+			var baselineURL string
+			if threshold.WarmStart && len(models) > 0 {
+				baseline, baselineURL, err = m.getBaseline(txCtx, modelType)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			}
+
 			// Проходим по всем найденным моделям
 			for _, model := range models {
 				// Задаем статус модели - в процессе обучения
@@ -103,14 +172,46 @@ func (m ModelTrainer) trainAndTuneModels() {
 					return fmt.Errorf("%s: %w", op, err)
 				}
 
+				// Обучение с нуля учитывает все исправленные метки, поэтому снимаем флаг переобучения
+				err = m.viewModelRepository.SetNeedsRetrain(txCtx, model.UserID, modelType, false)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
+				// Первое обучение идет вперед дообучений, так как у нового пользователя еще нет модели
+				priority := threshold.Priority.TrainPriority(m.maxPriority)
+
+				// Записываем задачу в журнал, тренер вернет ее id в очереди результатов
+				jobID, err := m.createTrainJob(txCtx, model.UserID, modelType, data.JobTypeTrain, priority)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
+				// Отложенные для сравнения версий видео не должны попасть в обучение
+				heldOutVideoIDs, err := m.viewModelRepository.GetHeldOutVideoIDs(txCtx, modelType, &model.UserID)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
 				// Формируем задачу на обучение
-				msg, err := json.Marshal(map[string]string{"type": "train", "user_id": model.UserID, "model_type": modelType})
+				job := map[string]any{
+					"type":              data.JobTypeTrain,
+					"job_id":            jobID,
+					"user_id":           model.UserID,
+					"model_type":        modelType,
+					"exclude_video_ids": heldOutVideoIDs}
+				if baseline != nil && model.ModelTrainStatus == data.StatusNotTrain {
+					// Задача теплого старта повторяет формат задачи дообучения
+					job["model_features"] = strconv.FormatUint(baseline.ModelFeatures, 10)
+					job["model_url"] = baselineURL
+				}
+				msg, err := json.Marshal(job)
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
 
 				// Отправляем задачу в соответствующую очередь
-				err = m.producer.Publish(modelType, msg)
+				err = m.producer.PublishWithPriority(modelType, msg, priority)
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
@@ -131,24 +232,40 @@ func (m ModelTrainer) trainAndTuneModels() {
 				}
 
 				// Формируем ссылку на скачивание предыдущей модели
-				modelURL, err := m.presigner.GetPresignURL(txCtx, *model.S3Key)
+				modelURL, err := m.presigner.GetPresignURL(txCtx, *model.S3Key, m.jobURLExpiry)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
+				// Чем дольше модель не обучалась, тем выше приоритет
+				priority := threshold.Priority.TunePriority(model.LastTrainedAt, time.Now(), m.maxPriority)
+
+				// Записываем задачу в журнал, тренер вернет ее id в очереди результатов
+				jobID, err := m.createTrainJob(txCtx, model.UserID, modelType, data.JobTypeTune, priority)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+
+				heldOutVideoIDs, err := m.viewModelRepository.GetHeldOutVideoIDs(txCtx, modelType, &model.UserID)
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
 
 				// Формируем задачу на дообучение
-				msg, err := json.Marshal(map[string]string{
-					"type":           "tune",
-					"user_id":        model.UserID,
-					"model_type":     modelType,
-					"model_features": strconv.FormatUint(model.ModelFeatures, 10),
-					"model_url":      modelURL})
+				msg, err := json.Marshal(map[string]any{
+					"type":              data.JobTypeTune,
+					"job_id":            jobID,
+					"user_id":           model.UserID,
+					"model_type":        modelType,
+					"model_features":    strconv.FormatUint(model.ModelFeatures, 10),
+					"model_url":         modelURL,
+					"exclude_video_ids": heldOutVideoIDs})
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
 
 				// Отправляем задачу в соответствующую очередь
-				err = m.producer.Publish(modelType, msg)
+				err = m.producer.PublishWithPriority(modelType, msg, priority)
 				if err != nil {
 					return fmt.Errorf("%s: %w", op, err)
 				}
@@ -158,6 +275,12 @@ func (m ModelTrainer) trainAndTuneModels() {
 			m.logger.Info(fmt.Sprintf("%s: train models worker finished", op))
 		}
 
+		// Отправляем созданные через API сравнения версий моделей
+		err = m.dispatchEvaluations(txCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
 		return nil
 	})
 
@@ -165,3 +288,250 @@ func (m ModelTrainer) trainAndTuneModels() {
 		m.logger.Error(txErr.Error())
 	}
 }
+
+// trainGlobalModels - функция, отправляющая задачи на обучение глобальных моделей на признаках всех пользователей.
+// Глобальная модель обучается, когда признаков всех пользователей набралось на порог обучения,
+// и переобучается с нуля, когда с прошлого обучения прибавилось признаков на порог дообучения
+func (m ModelTrainer) trainGlobalModels() {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "model_trainer.ModelTrainer.trainGlobalModels"
+
+	// Пока нет соединения с брокером, задачи не отправляем, чтобы не менять статусы моделей впустую
+	if !m.producer.IsHealthy() {
+		m.logger.Warn(fmt.Sprintf("%s: broker is unavailable, skip tick", op))
+		return
+	}
+
+	// Кладем логгер в контекст
+	ctx := logger.ContextWithLogger(context.Background(), m.logger)
+
+	// Производим все операции изменения данных в транзакции
+	txErr := m.transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+		// Используем ту же блокировку, что и рассылка персональных задач
+		locked, err := m.viewModelRepository.TryLockTrainDispatch(txCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !locked {
+			m.logger.Debug(fmt.Sprintf("%s: dispatch is locked by another replica, skip tick", op))
+			return nil
+		}
+
+		thresholds, err := m.viewModelRepository.GetTrainThresholds(txCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, threshold := range thresholds {
+			modelType := threshold.ModelType
+
+			model, err := m.viewModelRepository.GetGlobalModel(txCtx, modelType)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// Предыдущая задача еще не завершена
+			if model.ModelTrainStatus == data.StatusInTrainProcess || model.ModelTrainStatus == data.StatusInTuneProcess {
+				continue
+			}
+
+			totalFeatures, err := m.viewModelRepository.GetTotalFeaturesCount(txCtx, modelType)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// features_count глобальной модели хранит число признаков на момент прошлой отправки на обучение
+			if model.ModelTrainStatus == data.StatusTrained {
+				if totalFeatures < model.ModelFeatures+threshold.TuneThreshold {
+					continue
+				}
+			} else if totalFeatures < threshold.TrainThreshold {
+				continue
+			}
+
+			err = m.declareQueue(modelType)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			err = m.viewModelRepository.SetGlobalModelFeaturesCount(txCtx, modelType, totalFeatures)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			err = m.viewModelRepository.SetModelStatus(txCtx, data.StatusInTrainProcess, modelType, data.GlobalModelUserID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// Глобальная модель лишь запасной вариант, поэтому ее обучение не задерживает персональные задачи
+			var priority uint8
+
+			jobID, err := m.createTrainJob(txCtx, data.GlobalModelUserID, modelType, data.JobTypeTrain, priority)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// Глобальная модель служит baseline в сравнениях, поэтому не обучается на отложенных видео всех пользователей
+			heldOutVideoIDs, err := m.viewModelRepository.GetHeldOutVideoIDs(txCtx, modelType, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			// Формируем задачу на обучение, тренер выгружает признаки всех пользователей
+			msg, err := json.Marshal(map[string]any{
+				"type":              data.JobTypeTrain,
+				"scope":             data.JobScopeGlobal,
+				"job_id":            jobID,
+				"user_id":           data.GlobalModelUserID,
+				"model_type":        modelType,
+				"exclude_video_ids": heldOutVideoIDs})
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			err = m.producer.PublishWithPriority(modelType, msg, priority)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			m.logger.With(
+				zap.String("model_type", modelType),
+				zap.Uint64("features_count", totalFeatures),
+			).Info(fmt.Sprintf("%s: global model sent to train", op))
+		}
+
+		return nil
+	})
+
+	if txErr != nil {
+		m.logger.Error(txErr.Error())
+	}
+}
+
+// dispatchEvaluations - отправляет тренеру задачи сравнения двух версий модели на отложенных видео
+func (m ModelTrainer) dispatchEvaluations(ctx context.Context) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "model_trainer.ModelTrainer.dispatchEvaluations"
+
+	evaluations, err := m.viewModelRepository.ViewPendingModelEvaluations(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, evaluation := range evaluations {
+		err = m.declareQueue(evaluation.ModelType)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Формируем ссылки на скачивание обеих версий модели
+		baselineURL, err := m.presigner.GetPresignURL(ctx, evaluation.BaselineS3Key, m.jobURLExpiry)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		candidateURL, err := m.presigner.GetPresignURL(ctx, evaluation.CandidateS3Key, m.jobURLExpiry)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Сравнение не меняет модели пользователя, поэтому не задерживает задачи обучения
+		var priority uint8
+
+		jobID, err := m.createTrainJob(ctx, evaluation.UserID, evaluation.ModelType, data.JobTypeEvaluate, priority)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		msg, err := json.Marshal(map[string]any{
+			"type":                data.JobTypeEvaluate,
+			"job_id":              jobID,
+			"user_id":             evaluation.UserID,
+			"model_type":          evaluation.ModelType,
+			"baseline_model_url":  baselineURL,
+			"candidate_model_url": candidateURL,
+			"video_ids":           evaluation.VideoIDs})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = m.producer.PublishWithPriority(evaluation.ModelType, msg, priority)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = m.viewModelRepository.SetModelEvaluationQueued(ctx, evaluation.EvaluationID, jobID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// getBaseline - возвращает обученную глобальную модель и ссылку на ее скачивание, или nil, если глобальная модель еще не обучена
+func (m ModelTrainer) getBaseline(ctx context.Context, modelType string) (*data.MLModel, string, error) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "model_trainer.ModelTrainer.getBaseline"
+
+	baseline, err := m.viewModelRepository.GetModelByUserID(ctx, data.GlobalModelUserID, modelType)
+	if err != nil {
+		if app_errors.IsNotFound(err) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	// Пока глобальная модель не обучена, пользователи обучаются с нуля
+	if baseline.S3Key == nil {
+		return nil, "", nil
+	}
+
+	baselineURL, err := m.presigner.GetPresignURL(ctx, *baseline.S3Key, m.jobURLExpiry)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return baseline, baselineURL, nil
+}
+
+// declareQueue - объявляет очередь типа модели в брокере, если она еще не объявлена
+func (m ModelTrainer) declareQueue(modelType string) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "model_trainer.ModelTrainer.declareQueue"
+
+	if _, ok := m.declaredQueues.Load(modelType); ok {
+		return nil
+	}
+
+	err := m.producer.InitQueues([]string{modelType})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.declaredQueues.Store(modelType, struct{}{})
+	return nil
+}
+
+// createTrainJob - создает запись о задаче обучения в журнале и возвращает id задачи
+func (m ModelTrainer) createTrainJob(ctx context.Context, userID, modelType, jobType string, priority uint8) (string, error) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "model_trainer.ModelTrainer.createTrainJob"
+
+	jobID, err := uuid.NewUUID()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = m.viewModelRepository.CreateTrainJob(ctx, data.TrainJob{
+		JobID:     jobID.String(),
+		UserID:    userID,
+		ModelType: modelType,
+		JobType:   jobType,
+		Priority:  int(priority),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobID.String(), nil
+}
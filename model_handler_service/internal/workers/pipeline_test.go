@@ -0,0 +1,418 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
+	"github.com/go-co-op/gocron"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipelineRepository - хранилище моделей и журнала задач в памяти для прогона model-trainer и results-consumer в одном процессе
+type pipelineRepository struct {
+	mu         sync.Mutex
+	thresholds []data.TrainThreshold
+	models     map[string]*data.MLModel
+	jobs       map[string]*data.TrainJob
+	// needsRetrain - обученные модели, метки признаков которых исправили
+	needsRetrain map[string]bool
+}
+
+func newPipelineRepository(thresholds []data.TrainThreshold, models ...data.MLModel) *pipelineRepository {
+	r := &pipelineRepository{
+		thresholds:   thresholds,
+		models:       make(map[string]*data.MLModel),
+		jobs:         make(map[string]*data.TrainJob),
+		needsRetrain: make(map[string]bool),
+	}
+	for _, model := range models {
+		model := model
+		r.models[model.UserID+"/"+model.ModelType] = &model
+	}
+	return r
+}
+
+func (r *pipelineRepository) model(userID, modelType string) data.MLModel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.models[userID+"/"+modelType]
+}
+
+func (r *pipelineRepository) ViewNotLearnedModels(_ context.Context, modelType string, trainThreshold uint64) ([]data.MLModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []data.MLModel
+	for _, model := range r.models {
+		if model.ModelType != modelType || model.UserID == data.GlobalModelUserID {
+			continue
+		}
+		notTrained := model.ModelTrainStatus == data.StatusNotTrain && model.ModelFeatures >= trainThreshold
+		retrain := model.ModelTrainStatus == data.StatusTrained && r.needsRetrain[model.UserID+"/"+modelType]
+		if notTrained || retrain {
+			res = append(res, *model)
+		}
+	}
+	return res, nil
+}
+
+func (r *pipelineRepository) ViewNotFineTunedFaceModels(context.Context, string, uint64) ([]data.MLModel, error) {
+	return nil, nil
+}
+
+func (r *pipelineRepository) SetModelStatus(_ context.Context, status string, modelType string, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[userID+"/"+modelType].ModelTrainStatus = status
+	return nil
+}
+
+func (r *pipelineRepository) SetNeedsRetrain(_ context.Context, userID, modelType string, needsRetrain bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.needsRetrain[userID+"/"+modelType] = needsRetrain
+	return nil
+}
+
+func (r *pipelineRepository) CreateTrainJob(_ context.Context, job data.TrainJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.Status = data.JobStatusQueued
+	r.jobs[job.JobID] = &job
+	return nil
+}
+
+func (r *pipelineRepository) TryLockTrainDispatch(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (r *pipelineRepository) GetTrainThresholds(context.Context) ([]data.TrainThreshold, error) {
+	return r.thresholds, nil
+}
+
+func (r *pipelineRepository) GetModelByUserID(_ context.Context, userID, modelType string) (*data.MLModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	model, ok := r.models[userID+"/"+modelType]
+	if !ok {
+		return nil, app_errors.ErrNotFound.WrapError("pipelineRepository.GetModelByUserID", "model not found")
+	}
+	res := *model
+	return &res, nil
+}
+
+func (r *pipelineRepository) GetGlobalModel(ctx context.Context, modelType string) (*data.MLModel, error) {
+	return r.GetModelByUserID(ctx, data.GlobalModelUserID, modelType)
+}
+
+func (r *pipelineRepository) GetTotalFeaturesCount(context.Context, string) (uint64, error) {
+	return 0, nil
+}
+
+func (r *pipelineRepository) SetGlobalModelFeaturesCount(context.Context, string, uint64) error {
+	return nil
+}
+
+func (r *pipelineRepository) ViewPendingModelEvaluations(context.Context) ([]data.ModelEvaluation, error) {
+	return nil, nil
+}
+
+func (r *pipelineRepository) SetModelEvaluationQueued(context.Context, string, string) error {
+	return nil
+}
+
+func (r *pipelineRepository) GetHeldOutVideoIDs(context.Context, string, *string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *pipelineRepository) UpdateTrainJob(_ context.Context, job data.TrainJob) (*data.TrainJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.JobID]
+	if !ok {
+		return nil, app_errors.ErrNotFound.WrapError("pipelineRepository.UpdateTrainJob", "job not found")
+	}
+	stored.Status = job.Status
+	stored.S3Key = job.S3Key
+	stored.Error = job.Error
+	res := *stored
+	return &res, nil
+}
+
+func (r *pipelineRepository) SetModelS3Key(_ context.Context, s3Key, modelType, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[userID+"/"+modelType].S3Key = &s3Key
+	return nil
+}
+
+func (r *pipelineRepository) SetFeaturesCountUsed(context.Context, string, string, int) error {
+	return nil
+}
+
+func (r *pipelineRepository) SetLastTrainedAt(_ context.Context, userID, modelType string, trainedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[userID+"/"+modelType].LastTrainedAt = &trainedAt
+	return nil
+}
+
+func (r *pipelineRepository) EnqueueWebhookEvent(context.Context, data.WebhookEvent) (int64, error) {
+	return 0, nil
+}
+
+func (r *pipelineRepository) SetModelEvaluationResult(context.Context, data.ModelEvaluationResult) error {
+	return nil
+}
+
+type fakePresigner struct{}
+
+func (fakePresigner) GetPresignURL(_ context.Context, fileName string, _ time.Duration) (string, error) {
+	return "http://s3/" + fileName, nil
+}
+
+// runFakeTrainer - тренер, отвечающий на задачи очереди результатом, который возвращает handle
+func runFakeTrainer(t *testing.T, b broker.Broker, queue string, handle func(job map[string]string) TrainResult) func() {
+	msgs, closeFunc, err := b.Consume(queue)
+	if err != nil {
+		t.Fatalf("consume %s: %v", queue, err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var job map[string]string
+			if err := json.Unmarshal(d.Body, &job); err != nil {
+				t.Errorf("decode job: %v", err)
+				_ = d.Nack()
+				continue
+			}
+
+			result, err := json.Marshal(handle(job))
+			if err != nil {
+				t.Errorf("encode result: %v", err)
+			}
+			if err = b.Publish("model_results", result); err != nil {
+				t.Errorf("publish result: %v", err)
+			}
+			_ = d.Ack()
+		}
+	}()
+
+	return closeFunc
+}
+
+// waitFor - ждет выполнения условия, которое проверяется по результатам асинхронной обработки
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPipelineTrainsModelOverMemoryBroker(t *testing.T) {
+	repo := newPipelineRepository(
+		[]data.TrainThreshold{{ModelType: "face_model", TrainThreshold: 10}},
+		data.MLModel{UserID: "user", ModelType: "face_model", ModelTrainStatus: data.StatusNotTrain, ModelFeatures: 100},
+	)
+
+	b := broker.NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	s3Key := "face_model/user/model.ubj"
+	stopTrainer := runFakeTrainer(t, b, "face_model", func(job map[string]string) TrainResult {
+		return TrainResult{
+			JobID:     job["job_id"],
+			UserID:    job["user_id"],
+			ModelType: job["model_type"],
+			Status:    ResultStatusSuccess,
+			S3Key:     &s3Key,
+		}
+	})
+	defer stopTrainer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := NewResultsConsumer(repo, fakeTransactor{}, b, "model_results", zap.NewNop())
+	go func() {
+		_ = consumer.Run(ctx)
+	}()
+
+	trainer := NewModelTrainer(repo, fakePresigner{}, time.Hour, 10, fakeTransactor{}, b,
+		gocron.NewScheduler(time.UTC), zap.NewNop())
+	trainer.trainAndTuneModels()
+
+	if status := repo.model("user", "face_model").ModelTrainStatus; status != data.StatusInTrainProcess {
+		t.Fatalf("model status after dispatch = %q, want %q", status, data.StatusInTrainProcess)
+	}
+
+	waitFor(t, func() bool {
+		return repo.model("user", "face_model").ModelTrainStatus == data.StatusTrained
+	})
+
+	model := repo.model("user", "face_model")
+	if model.S3Key == nil || *model.S3Key != s3Key {
+		t.Errorf("model s3 key = %v, want %s", model.S3Key, s3Key)
+	}
+
+	// Повторный запуск не отправляет задачу для уже обученной модели
+	trainer.trainAndTuneModels()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.jobs) != 1 {
+		t.Fatalf("jobs = %d, want 1", len(repo.jobs))
+	}
+	for _, job := range repo.jobs {
+		if job.Status != data.JobStatusSucceeded {
+			t.Errorf("job status = %q, want %q", job.Status, data.JobStatusSucceeded)
+		}
+	}
+}
+
+func TestWarmStartOnlyForFirstTraining(t *testing.T) {
+	globalKey := "face_model/global/model.ubj"
+	userKey := "face_model/retrained/model.ubj"
+	repo := newPipelineRepository(
+		[]data.TrainThreshold{{ModelType: "face_model", TrainThreshold: 10, WarmStart: true}},
+		data.MLModel{UserID: data.GlobalModelUserID, ModelType: "face_model", ModelTrainStatus: data.StatusTrained,
+			ModelFeatures: 500, S3Key: &globalKey},
+		data.MLModel{UserID: "new", ModelType: "face_model", ModelTrainStatus: data.StatusNotTrain, ModelFeatures: 100},
+		data.MLModel{UserID: "retrained", ModelType: "face_model", ModelTrainStatus: data.StatusTrained,
+			ModelFeatures: 100, S3Key: &userKey},
+	)
+	repo.needsRetrain["retrained/face_model"] = true
+
+	b := broker.NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	msgs, closeFunc, err := b.Consume("face_model")
+	if err != nil {
+		t.Fatalf("consume face_model: %v", err)
+	}
+	defer closeFunc()
+
+	trainer := NewModelTrainer(repo, fakePresigner{}, time.Hour, 10, fakeTransactor{}, b,
+		gocron.NewScheduler(time.UTC), zap.NewNop())
+	trainer.trainAndTuneModels()
+
+	jobs := make(map[string]map[string]any)
+	for len(jobs) < 2 {
+		select {
+		case d := <-msgs:
+			var job map[string]any
+			if err := json.Unmarshal(d.Body, &job); err != nil {
+				t.Fatalf("decode job: %v", err)
+			}
+			jobs[job["user_id"].(string)] = job
+			_ = d.Ack()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("jobs = %d, want 2", len(jobs))
+		}
+	}
+
+	// Первое обучение начинается с глобальной модели
+	if url := jobs["new"]["model_url"]; url != "http://s3/"+globalKey {
+		t.Errorf("first training model_url = %v, want %s", url, "http://s3/"+globalKey)
+	}
+	if features := jobs["new"]["model_features"]; features != "500" {
+		t.Errorf("first training model_features = %v, want 500", features)
+	}
+
+	// Переобучение после исправления меток идет с нуля
+	for _, key := range []string{"model_url", "model_features"} {
+		if value, ok := jobs["retrained"][key]; ok {
+			t.Errorf("retrain job has %s = %v, want none", key, value)
+		}
+	}
+}
+
+func TestPipelineRetriesRetryableFailure(t *testing.T) {
+	repo := newPipelineRepository(
+		[]data.TrainThreshold{{ModelType: "face_model", TrainThreshold: 10}},
+		data.MLModel{UserID: "user", ModelType: "face_model", ModelTrainStatus: data.StatusNotTrain, ModelFeatures: 100},
+	)
+
+	b := broker.NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	// Первая попытка падает с временной ошибкой, тренер возвращает задачу в очередь с увеличенной попыткой
+	msgs, closeFunc, err := b.Consume("face_model")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := NewResultsConsumer(repo, fakeTransactor{}, b, "model_results", zap.NewNop())
+	go func() {
+		_ = consumer.Run(ctx)
+	}()
+
+	trainer := NewModelTrainer(repo, fakePresigner{}, time.Hour, 10, fakeTransactor{}, b,
+		gocron.NewScheduler(time.UTC), zap.NewNop())
+	trainer.trainAndTuneModels()
+
+	s3Key := "face_model/user/model.ubj"
+	attempts := make([]int, 0, 2)
+	for len(attempts) < 2 {
+		select {
+		case d := <-msgs:
+			var job map[string]string
+			if err := json.Unmarshal(d.Body, &job); err != nil {
+				t.Fatalf("decode job: %v", err)
+			}
+			attempts = append(attempts, d.Attempt)
+
+			result := TrainResult{
+				JobID:     job["job_id"],
+				UserID:    job["user_id"],
+				ModelType: job["model_type"],
+				Status:    ResultStatusSuccess,
+				S3Key:     &s3Key,
+			}
+			if d.Attempt == 0 {
+				errMsg := "features storage is unavailable"
+				result = TrainResult{
+					JobID:     job["job_id"],
+					UserID:    job["user_id"],
+					ModelType: job["model_type"],
+					Status:    ResultStatusFailure,
+					Error:     &errMsg,
+					Retryable: true,
+				}
+			}
+
+			body, _ := json.Marshal(result)
+			if err = b.Publish("model_results", body); err != nil {
+				t.Fatalf("publish result: %v", err)
+			}
+			if d.Attempt == 0 {
+				err = d.Retry()
+			} else {
+				err = d.Ack()
+			}
+			if err != nil {
+				t.Fatalf("acknowledge job: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("job is not redelivered in time")
+		}
+	}
+
+	if attempts[0] != 0 || attempts[1] != 1 {
+		t.Errorf("attempts = %v, want [0 1]", attempts)
+	}
+
+	waitFor(t, func() bool {
+		return repo.model("user", "face_model").ModelTrainStatus == data.StatusTrained
+	})
+}
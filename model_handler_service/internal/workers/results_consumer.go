@@ -0,0 +1,337 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	ResultStatusSuccess = "success"
+	ResultStatusFailure = "failure"
+)
+
+const resubscribeDelay = time.Second
+
+// errMalformedResult - сообщение тренера не удастся обработать и при повторной доставке
+var errMalformedResult = errors.New("malformed result")
+
+// TrainResult - сообщение тренера о результате выполнения задачи обучения
+type TrainResult struct {
+	JobID         string          `json:"job_id"`
+	UserID        string          `json:"user_id"`
+	ModelType     string          `json:"model_type"`
+	Status        string          `json:"status"`
+	S3Key         *string         `json:"s3_key"`
+	FeaturesCount *int            `json:"features_count"`
+	Metrics       json.RawMessage `json:"metrics"`
+	Error         *string         `json:"error"`
+	Retryable     bool            `json:"retryable"`
+}
+
+type ResultRepository interface {
+	UpdateTrainJob(ctx context.Context, job data.TrainJob) (*data.TrainJob, error)
+	SetModelS3Key(ctx context.Context, s3Key, modelType, userID string) error
+	SetModelStatus(ctx context.Context, status string, modelType string, userID string) error
+	SetFeaturesCountUsed(ctx context.Context, userID, modelType string, featuresCount int) error
+	SetLastTrainedAt(ctx context.Context, userID, modelType string, trainedAt time.Time) error
+	EnqueueWebhookEvent(ctx context.Context, event data.WebhookEvent) (int64, error)
+	SetModelEvaluationResult(ctx context.Context, result data.ModelEvaluationResult) error
+}
+
+// EvaluationMetrics - метрики задачи сравнения: по одной версии модели в каждом поле
+type EvaluationMetrics struct {
+	Baseline  json.RawMessage `json:"baseline"`
+	Candidate json.RawMessage `json:"candidate"`
+}
+
+type Consumer interface {
+	broker.Consumer
+	IsHealthy() bool
+}
+
+type ResultsConsumer struct {
+	resultRepository ResultRepository
+	transactor       postgresql.Transactor
+
+	consumer     Consumer
+	resultsQueue string
+
+	logger *zap.Logger
+}
+
+func NewResultsConsumer(
+	resultRepository ResultRepository,
+	transactor postgresql.Transactor,
+	consumer Consumer,
+	resultsQueue string,
+	logger *zap.Logger,
+) *ResultsConsumer {
+	return &ResultsConsumer{
+		resultRepository: resultRepository,
+		transactor:       transactor,
+		consumer:         consumer,
+		resultsQueue:     resultsQueue,
+		logger:           logger,
+	}
+}
+
+// Run - читает очередь результатов до отмены контекста, заново подписываясь на очередь после разрыва соединения
+func (c ResultsConsumer) Run(ctx context.Context) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.ResultsConsumer.Run"
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		// Ждем восстановления соединения с брокером
+		if !c.consumer.IsHealthy() {
+			time.Sleep(resubscribeDelay)
+			continue
+		}
+
+		msgs, closeFunc, err := c.consumer.Consume(c.resultsQueue)
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+			time.Sleep(resubscribeDelay)
+			continue
+		}
+
+		c.logger.With(zap.String("queue", c.resultsQueue)).Info(fmt.Sprintf("%s: consume results", op))
+		c.consume(ctx, msgs)
+		closeFunc()
+	}
+}
+
+func (c ResultsConsumer) consume(ctx context.Context, msgs <-chan broker.Delivery) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.ResultsConsumer.consume"
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-msgs:
+			if !ok {
+				c.logger.Warn(fmt.Sprintf("%s: deliveries channel closed", op))
+				return
+			}
+
+			c.handleDelivery(d)
+		}
+	}
+}
+
+func (c ResultsConsumer) handleDelivery(d broker.Delivery) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.ResultsConsumer.handleDelivery"
+
+	var result TrainResult
+	err := json.Unmarshal(d.Body, &result)
+	if err != nil || result.JobID == "" || result.UserID == "" || result.ModelType == "" || !validMetrics(result.Metrics) {
+		// Некорректное сообщение повторно обрабатывать бессмысленно, отправляем его в очередь недоставленных
+		c.logger.With(zap.ByteString("body", d.Body)).Error(fmt.Sprintf("%s: malformed result", op))
+		err = d.Nack()
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+		return
+	}
+
+	err = c.handleResult(result)
+	if errors.Is(err, errMalformedResult) {
+		// Метрики сравнения версий проверяются после чтения типа задачи из журнала
+		c.logger.With(zap.ByteString("body", d.Body)).Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		err = d.Nack()
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+		return
+	}
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		err = d.Retry()
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+		return
+	}
+
+	err = d.Ack()
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+	}
+}
+
+// validMetrics - метрики в результате необязательны, но если они есть, то должны быть объектом
+func validMetrics(metrics json.RawMessage) bool {
+	if len(metrics) == 0 || string(metrics) == "null" {
+		return true
+	}
+	return isJSONObject(metrics)
+}
+
+// isJSONObject - проверяет, что значение является JSON-объектом, а не null, массивом или скаляром
+func isJSONObject(raw json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+	return json.Unmarshal(raw, &fields) == nil && fields != nil
+}
+
+// handleResult - обновляет журнал задач и статус модели по результату обучения
+func (c ResultsConsumer) handleResult(result TrainResult) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.ResultsConsumer.handleResult"
+
+	// Кладем логгер в контекст
+	ctx := logger.ContextWithLogger(context.Background(), c.logger)
+
+	job := data.TrainJob{
+		JobID:   result.JobID,
+		S3Key:   result.S3Key,
+		Metrics: result.Metrics,
+		Error:   result.Error,
+	}
+	switch {
+	case result.Status == ResultStatusSuccess:
+		job.Status = data.JobStatusSucceeded
+	case result.Status == ResultStatusFailure && result.Retryable:
+		// Задача будет доставлена тренеру повторно, модель остается в процессе обучения
+		job.Status = data.JobStatusRetrying
+	case result.Status == ResultStatusFailure:
+		job.Status = data.JobStatusFailed
+	default:
+		return fmt.Errorf("%s: unknown result status: %s", op, result.Status)
+	}
+
+	// Производим все операции изменения данных в транзакции
+	txErr := c.transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+		updatedJob, err := c.resultRepository.UpdateTrainJob(txCtx, job)
+		if err != nil {
+			// Задачи, отправленные до появления журнала, в нем отсутствуют, но статус модели обновить нужно
+			if !app_errors.IsNotFound(err) {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			c.logger.With(zap.String("job_id", result.JobID)).Warn(fmt.Sprintf("%s: job not found in ledger", op))
+		}
+
+		// Сравнение версий не меняет модель пользователя, записываем только его результат.
+		// Тип задачи берем из журнала, так как сообщение об ошибке тренера его не содержит
+		if updatedJob != nil && updatedJob.JobType == data.JobTypeEvaluate {
+			return c.handleEvaluationResult(txCtx, job, result)
+		}
+
+		switch job.Status {
+		case data.JobStatusSucceeded:
+			// Тренер мог сохранить модель через /save_model или /model_uploads/complete, тогда повторная запись ничего не меняет
+			if result.S3Key != nil {
+				err = c.resultRepository.SetModelS3Key(txCtx, *result.S3Key, result.ModelType, result.UserID)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			}
+			if result.FeaturesCount != nil {
+				err = c.resultRepository.SetFeaturesCountUsed(txCtx, result.UserID, result.ModelType, *result.FeaturesCount)
+				if err != nil {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			}
+			err = c.resultRepository.SetLastTrainedAt(txCtx, result.UserID, result.ModelType, time.Now())
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			err = c.resultRepository.SetModelStatus(txCtx, data.StatusTrained, result.ModelType, result.UserID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		case data.JobStatusFailed:
+			// Возвращаем модель в состояние до отправки задачи, чтобы следующий запуск тренера снова ее выбрал:
+			// необученная модель ждет обучения, обученная продолжает работать и ждет дообучения
+			err = c.resultRepository.SetModelStatus(txCtx, failedJobModelStatus(updatedJob), result.ModelType, result.UserID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			_, err = c.resultRepository.EnqueueWebhookEvent(txCtx, data.NewWebhookEvent(
+				data.WebhookEventTrainingFailed, result.UserID, data.TrainingFailedEventData{
+					ModelType: result.ModelType,
+					JobID:     result.JobID,
+					Error:     result.Error,
+				}))
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	c.logger.With(
+		zap.String("job_id", result.JobID),
+		zap.String("user_id", result.UserID),
+		zap.String("status", job.Status),
+	).Info(fmt.Sprintf("%s: handle train result", op))
+
+	return nil
+}
+
+// failedJobModelStatus - статус модели после неудачной задачи. Задача, отсутствующая в журнале,
+// считается обучением: модель без подтвержденного дообучения обучается заново
+func failedJobModelStatus(job *data.TrainJob) string {
+	if job != nil && job.JobType == data.JobTypeTune {
+		return data.StatusTrained
+	}
+	return data.StatusNotTrain
+}
+
+// handleEvaluationResult - записывает метрики версий модели по результату задачи сравнения
+func (c ResultsConsumer) handleEvaluationResult(ctx context.Context, job data.TrainJob, result TrainResult) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.ResultsConsumer.handleEvaluationResult"
+
+	evaluationResult := data.ModelEvaluationResult{
+		JobID: job.JobID,
+		Error: result.Error,
+	}
+	switch job.Status {
+	case data.JobStatusSucceeded:
+		evaluationResult.Status = data.EvaluationStatusSucceeded
+
+		// Успешное сравнение без метрик обеих версий сохранить нельзя
+		var metrics EvaluationMetrics
+		err := json.Unmarshal(result.Metrics, &metrics)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", op, errMalformedResult, err.Error())
+		}
+		if !isJSONObject(metrics.Baseline) || !isJSONObject(metrics.Candidate) {
+			return fmt.Errorf("%s: %w: baseline and candidate metrics are required", op, errMalformedResult)
+		}
+		evaluationResult.BaselineMetrics = metrics.Baseline
+		evaluationResult.CandidateMetrics = metrics.Candidate
+	case data.JobStatusFailed:
+		evaluationResult.Status = data.EvaluationStatusFailed
+	default:
+		// Задача будет доставлена тренеру повторно, сравнение остается в очереди
+		return nil
+	}
+
+	err := c.resultRepository.SetModelEvaluationResult(ctx, evaluationResult)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package workers
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/broker"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+type fakeResultRepository struct {
+	job *data.TrainJob
+
+	modelStatus string
+	events      []data.WebhookEvent
+}
+
+func (r *fakeResultRepository) UpdateTrainJob(_ context.Context, job data.TrainJob) (*data.TrainJob, error) {
+	updated := *r.job
+	updated.Status = job.Status
+	updated.Error = job.Error
+	return &updated, nil
+}
+
+func (r *fakeResultRepository) SetModelS3Key(context.Context, string, string, string) error {
+	return nil
+}
+
+func (r *fakeResultRepository) SetModelStatus(_ context.Context, status string, _ string, _ string) error {
+	r.modelStatus = status
+	return nil
+}
+
+func (r *fakeResultRepository) SetFeaturesCountUsed(context.Context, string, string, int) error {
+	return nil
+}
+
+func (r *fakeResultRepository) SetLastTrainedAt(context.Context, string, string, time.Time) error {
+	return nil
+}
+
+func (r *fakeResultRepository) EnqueueWebhookEvent(_ context.Context, event data.WebhookEvent) (int64, error) {
+	r.events = append(r.events, event)
+	return int64(len(r.events)), nil
+}
+
+func (r *fakeResultRepository) SetModelEvaluationResult(context.Context, data.ModelEvaluationResult) error {
+	return nil
+}
+
+func TestHandleResultFailedJobRestoresModelStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		jobType    string
+		wantStatus string
+	}{
+		{name: "train", jobType: data.JobTypeTrain, wantStatus: data.StatusNotTrain},
+		{name: "tune", jobType: data.JobTypeTune, wantStatus: data.StatusTrained},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeResultRepository{job: &data.TrainJob{
+				JobID:     "job",
+				UserID:    "user",
+				ModelType: "face_model",
+				JobType:   tt.jobType,
+				Status:    data.JobStatusQueued,
+			}}
+			consumer := NewResultsConsumer(repo, fakeTransactor{}, nil, "model_results", zap.NewNop())
+
+			errMsg := "not enough features"
+			err := consumer.handleResult(TrainResult{
+				JobID:     "job",
+				UserID:    "user",
+				ModelType: "face_model",
+				Status:    ResultStatusFailure,
+				Error:     &errMsg,
+			})
+			if err != nil {
+				t.Fatalf("handleResult: %v", err)
+			}
+
+			if repo.modelStatus != tt.wantStatus {
+				t.Errorf("model status = %q, want %q", repo.modelStatus, tt.wantStatus)
+			}
+			if len(repo.events) != 1 || repo.events[0].EventType != data.WebhookEventTrainingFailed {
+				t.Errorf("events = %+v, want one %s event", repo.events, data.WebhookEventTrainingFailed)
+			}
+		})
+	}
+}
+
+func TestHandleResultRetryableFailureKeepsModelStatus(t *testing.T) {
+	repo := &fakeResultRepository{job: &data.TrainJob{JobID: "job", JobType: data.JobTypeTrain}}
+	consumer := NewResultsConsumer(repo, fakeTransactor{}, nil, "model_results", zap.NewNop())
+
+	err := consumer.handleResult(TrainResult{
+		JobID:     "job",
+		UserID:    "user",
+		ModelType: "face_model",
+		Status:    ResultStatusFailure,
+		Retryable: true,
+	})
+	if err != nil {
+		t.Fatalf("handleResult: %v", err)
+	}
+
+	if repo.modelStatus != "" {
+		t.Errorf("model status = %q, want unchanged", repo.modelStatus)
+	}
+}
+
+// fakeAcknowledger - запоминает, как было подтверждено сообщение
+type fakeAcknowledger struct {
+	result string
+}
+
+func (a *fakeAcknowledger) Ack() error {
+	a.result = "ack"
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack() error {
+	a.result = "nack"
+	return nil
+}
+
+func (a *fakeAcknowledger) Retry() error {
+	a.result = "retry"
+	return nil
+}
+
+func TestHandleDeliveryValidatesMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobType string
+		body    string
+		want    string
+	}{
+		{
+			name:    "train without metrics",
+			jobType: data.JobTypeTrain,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success"}`,
+			want:    "ack",
+		},
+		{
+			name:    "train with metrics",
+			jobType: data.JobTypeTrain,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success","metrics":{"f1":0.9}}`,
+			want:    "ack",
+		},
+		{
+			name:    "metrics are not an object",
+			jobType: data.JobTypeTrain,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success","metrics":[0.9]}`,
+			want:    "nack",
+		},
+		{
+			name:    "evaluation with metrics of both versions",
+			jobType: data.JobTypeEvaluate,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success","metrics":{"baseline":{"f1":0.8},"candidate":{"f1":0.9}}}`,
+			want:    "ack",
+		},
+		{
+			name:    "evaluation without candidate metrics",
+			jobType: data.JobTypeEvaluate,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success","metrics":{"baseline":{"f1":0.8}}}`,
+			want:    "nack",
+		},
+		{
+			name:    "evaluation without metrics",
+			jobType: data.JobTypeEvaluate,
+			body:    `{"job_id":"job","user_id":"user","model_type":"face_model","status":"success"}`,
+			want:    "nack",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeResultRepository{job: &data.TrainJob{JobID: "job", JobType: tt.jobType}}
+			consumer := NewResultsConsumer(repo, fakeTransactor{}, nil, "model_results", zap.NewNop())
+
+			ack := &fakeAcknowledger{}
+			consumer.handleDelivery(broker.Delivery{Acknowledger: ack, Body: []byte(tt.body)})
+
+			if ack.result != tt.want {
+				t.Errorf("delivery = %s, want %s", ack.result, tt.want)
+			}
+		})
+	}
+}
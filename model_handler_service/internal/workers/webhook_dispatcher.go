@@ -0,0 +1,214 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/logger"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/webhook"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxResponseErrorLength - сколько байт тела неуспешного ответа сохранять в журнал доставок
+const maxResponseErrorLength = 512
+
+type WebhookRepository interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]data.ClaimedWebhookDelivery, error)
+	SetWebhookDeliveryResult(ctx context.Context, result data.WebhookDeliveryResult) error
+}
+
+// WebhookRetryPolicy - повторы доставки с экспоненциальной задержкой: BaseDelay, 2*BaseDelay, 4*BaseDelay... но не больше MaxDelay
+type WebhookRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay - задержка перед следующей попыткой после attempt неудачных попыток
+func (p WebhookRetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// WebhookDispatcher - отправляет события из журнала доставок в вебхуки организаций.
+// Несколько диспетчеров могут работать одновременно, доставки между ними делятся через SKIP LOCKED
+type WebhookDispatcher struct {
+	webhookRepository WebhookRepository
+	client            *http.Client
+
+	retryPolicy  WebhookRetryPolicy
+	batchSize    int
+	pollInterval time.Duration
+	// lease - время, на которое доставка скрывается от других диспетчеров, должно превышать таймаут запроса
+	lease time.Duration
+
+	logger *zap.Logger
+}
+
+func NewWebhookDispatcher(
+	webhookRepository WebhookRepository,
+	retryPolicy WebhookRetryPolicy,
+	timeout time.Duration,
+	batchSize int,
+	pollInterval time.Duration,
+	logger *zap.Logger,
+) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepository: webhookRepository,
+		client:            newWebhookClient(timeout),
+		retryPolicy:       retryPolicy,
+		batchSize:         batchSize,
+		pollInterval:      pollInterval,
+		lease:             2 * timeout,
+		logger:            logger,
+	}
+}
+
+// newWebhookClient - клиент, который не подключается к адресам внутренней сети, в том числе после
+// перенаправления или смены DNS записи зарегистрированного хоста
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: webhook.DialControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
+
+// Run - отправляет доставки до отмены контекста
+func (d WebhookDispatcher) Run(ctx context.Context) error {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.WebhookDispatcher.Run"
+
+	// Кладем логгер в контекст
+	ctx = logger.ContextWithLogger(ctx, d.logger)
+
+	for {
+		deliveries, err := d.webhookRepository.ClaimWebhookDeliveries(ctx, d.batchSize, d.lease)
+		if err != nil {
+			d.logger.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		}
+
+		// Запросы пакета отправляются параллельно, чтобы медленный получатель не задерживал остальных
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery data.ClaimedWebhookDelivery) {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		// Полный пакет означает, что в очереди могут остаться доставки, поэтому забираем следующий без ожидания
+		if err == nil && len(deliveries) == d.batchSize {
+			// Без ожидания остановка не проверяется в select, поэтому проверяем ее здесь
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// deliver - отправляет событие и записывает результат попытки в журнал
+func (d WebhookDispatcher) deliver(ctx context.Context, delivery data.ClaimedWebhookDelivery) {
+	// Объявляем текущую операцию для оборачивания ошибки
+	op := "workers.WebhookDispatcher.deliver"
+
+	l := d.logger.With(
+		zap.Int64("delivery_id", delivery.DeliveryID),
+		zap.String("endpoint_id", delivery.EndpointID),
+		zap.String("event_type", delivery.EventType),
+		zap.Int("attempt", delivery.Attempts),
+	)
+
+	result := data.WebhookDeliveryResult{
+		DeliveryID: delivery.DeliveryID,
+		Status:     data.DeliveryStatusDelivered,
+	}
+
+	statusCode, err := d.send(ctx, delivery)
+	if statusCode != 0 {
+		result.StatusCode = &statusCode
+	}
+	if err != nil {
+		errString := err.Error()
+		result.Error = &errString
+
+		if delivery.Attempts >= d.retryPolicy.MaxAttempts {
+			result.Status = data.DeliveryStatusFailed
+			l.Error(fmt.Sprintf("%s: delivery failed: %s", op, errString))
+		} else {
+			nextAttemptAt := time.Now().Add(d.retryPolicy.Delay(delivery.Attempts))
+			result.Status = data.DeliveryStatusPending
+			result.NextAttemptAt = &nextAttemptAt
+			l.Warn(fmt.Sprintf("%s: delivery will be retried at %s: %s", op, nextAttemptAt.Format(time.RFC3339), errString))
+		}
+	}
+
+	// Результат записываем и при остановке диспетчера, иначе успешная доставка будет отправлена повторно
+	err = d.webhookRepository.SetWebhookDeliveryResult(context.WithoutCancel(ctx), result)
+	if err != nil {
+		l.Error(fmt.Sprintf("%s: %s", op, err.Error()))
+		return
+	}
+
+	if result.Status == data.DeliveryStatusDelivered {
+		l.Info(fmt.Sprintf("%s: delivered", op))
+	}
+}
+
+// send - отправляет подписанный запрос и возвращает код ответа. Успешной считается доставка с кодом 2xx
+func (d WebhookDispatcher) send(ctx context.Context, delivery data.ClaimedWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderEventID, delivery.EventID)
+	req.Header.Set(webhook.HeaderEventType, delivery.EventType)
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseErrorLength))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,278 @@
+package workers
+
+import (
+	"context"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/internal/domains/data"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/webhook"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = WebhookRetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    time.Minute,
+}
+
+type fakeWebhookRepository struct {
+	mu sync.Mutex
+
+	// claim - пакет, который возвращается при каждом вызове ClaimWebhookDeliveries
+	claim  []data.ClaimedWebhookDelivery
+	claims int
+	// onClaim - вызывается после каждого вызова ClaimWebhookDeliveries
+	onClaim func()
+
+	results []data.WebhookDeliveryResult
+}
+
+func (r *fakeWebhookRepository) ClaimWebhookDeliveries(context.Context, int, time.Duration) ([]data.ClaimedWebhookDelivery, error) {
+	r.mu.Lock()
+	r.claims++
+	r.mu.Unlock()
+
+	if r.onClaim != nil {
+		r.onClaim()
+	}
+	return r.claim, nil
+}
+
+func (r *fakeWebhookRepository) SetWebhookDeliveryResult(_ context.Context, result data.WebhookDeliveryResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, result)
+	return nil
+}
+
+func newTestDelivery(url string, attempts int) data.ClaimedWebhookDelivery {
+	return data.ClaimedWebhookDelivery{
+		DeliveryID: 1,
+		EndpointID: "endpoint",
+		EventID:    "event",
+		EventType:  "fatigue.alert",
+		Payload:    []byte(`{"event_id":"event"}`),
+		Attempts:   attempts,
+		URL:        url,
+		Secret:     "secret",
+	}
+}
+
+// newTestDispatcher - диспетчер с клиентом без проверки адресов, так как тестовые серверы слушают loopback
+func newTestDispatcher(repo WebhookRepository, batchSize int, pollInterval time.Duration) *WebhookDispatcher {
+	dispatcher := NewWebhookDispatcher(repo, testRetryPolicy, time.Second, batchSize, pollInterval, zap.NewNop())
+	dispatcher.client = &http.Client{Timeout: time.Second}
+	return dispatcher
+}
+
+func deliverOnce(t *testing.T, delivery data.ClaimedWebhookDelivery) data.WebhookDeliveryResult {
+	t.Helper()
+
+	repo := &fakeWebhookRepository{}
+	dispatcher := newTestDispatcher(repo, 10, time.Second)
+	dispatcher.deliver(context.Background(), delivery)
+
+	if len(repo.results) != 1 {
+		t.Fatalf("results = %d, want 1", len(repo.results))
+	}
+	return repo.results[0]
+}
+
+func TestDeliverSendsSignedRequest(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := newTestDelivery(server.URL, 1)
+	result := deliverOnce(t, delivery)
+
+	if got.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.Method)
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if got.Header.Get(webhook.HeaderEventID) != delivery.EventID {
+		t.Errorf("%s = %q, want %q", webhook.HeaderEventID, got.Header.Get(webhook.HeaderEventID), delivery.EventID)
+	}
+	if got.Header.Get(webhook.HeaderEventType) != delivery.EventType {
+		t.Errorf("%s = %q, want %q", webhook.HeaderEventType, got.Header.Get(webhook.HeaderEventType), delivery.EventType)
+	}
+	if got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got.Header.Get("Content-Type"))
+	}
+	if !webhook.Verify(delivery.Secret, got.Header.Get(webhook.HeaderSignature), got.Header.Get(webhook.HeaderTimestamp),
+		body, time.Minute) {
+		t.Errorf("signature %q does not verify", got.Header.Get(webhook.HeaderSignature))
+	}
+	if webhook.Verify("other secret", got.Header.Get(webhook.HeaderSignature), got.Header.Get(webhook.HeaderTimestamp),
+		body, time.Minute) {
+		t.Error("signature verifies with another secret")
+	}
+
+	if result.Status != data.DeliveryStatusDelivered {
+		t.Errorf("status = %s, want %s", result.Status, data.DeliveryStatusDelivered)
+	}
+	if result.StatusCode == nil || *result.StatusCode != http.StatusNoContent {
+		t.Errorf("status code = %v, want %d", result.StatusCode, http.StatusNoContent)
+	}
+	if result.Error != nil || result.NextAttemptAt != nil {
+		t.Errorf("error = %v, next attempt = %v, want nil", result.Error, result.NextAttemptAt)
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "receiver is down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		attempts  int
+		wantDelay time.Duration
+	}{
+		{attempts: 1, wantDelay: 10 * time.Second},
+		{attempts: 2, wantDelay: 20 * time.Second},
+	}
+
+	for _, tt := range tests {
+		before := time.Now()
+		result := deliverOnce(t, newTestDelivery(server.URL, tt.attempts))
+
+		if result.Status != data.DeliveryStatusPending {
+			t.Errorf("attempt %d: status = %s, want %s", tt.attempts, result.Status, data.DeliveryStatusPending)
+		}
+		if result.StatusCode == nil || *result.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("attempt %d: status code = %v, want %d", tt.attempts, result.StatusCode, http.StatusServiceUnavailable)
+		}
+		if result.Error == nil || !strings.Contains(*result.Error, "receiver is down") {
+			t.Errorf("attempt %d: error = %v, want response body", tt.attempts, result.Error)
+		}
+		if result.NextAttemptAt == nil {
+			t.Fatalf("attempt %d: next attempt = nil", tt.attempts)
+		}
+		delay := result.NextAttemptAt.Sub(before)
+		if delay < tt.wantDelay || delay > tt.wantDelay+time.Second {
+			t.Errorf("attempt %d: delay = %v, want %v", tt.attempts, delay, tt.wantDelay)
+		}
+	}
+}
+
+func TestDeliverFailsAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	result := deliverOnce(t, newTestDelivery(server.URL, testRetryPolicy.MaxAttempts))
+
+	if result.Status != data.DeliveryStatusFailed {
+		t.Errorf("status = %s, want %s", result.Status, data.DeliveryStatusFailed)
+	}
+	if result.NextAttemptAt != nil {
+		t.Errorf("next attempt = %v, want nil", result.NextAttemptAt)
+	}
+}
+
+func TestDeliverRetriesUnreachableEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	result := deliverOnce(t, newTestDelivery(url, 1))
+
+	if result.Status != data.DeliveryStatusPending {
+		t.Errorf("status = %s, want %s", result.Status, data.DeliveryStatusPending)
+	}
+	if result.StatusCode != nil {
+		t.Errorf("status code = %v, want nil", *result.StatusCode)
+	}
+	if result.Error == nil {
+		t.Error("error = nil, want connection error")
+	}
+}
+
+func TestDeliverRejectsInternalAddress(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhookRepository{}
+	dispatcher := NewWebhookDispatcher(repo, testRetryPolicy, time.Second, 10, time.Second, zap.NewNop())
+	dispatcher.deliver(context.Background(), newTestDelivery(server.URL, 1))
+
+	if requests != 0 {
+		t.Errorf("requests = %d, want 0", requests)
+	}
+	if len(repo.results) != 1 {
+		t.Fatalf("results = %d, want 1", len(repo.results))
+	}
+	if result := repo.results[0]; result.Error == nil || !strings.Contains(*result.Error, webhook.ErrForbiddenAddress.Error()) {
+		t.Errorf("error = %v, want %v", result.Error, webhook.ErrForbiddenAddress)
+	}
+}
+
+func TestWebhookRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Second},
+		{attempt: 2, want: 20 * time.Second},
+		{attempt: 3, want: 40 * time.Second},
+		{attempt: 4, want: time.Minute},
+		{attempt: 100, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := testRetryPolicy.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRunStopsWhileBatchesAreFull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// каждый пакет полный, поэтому диспетчер не доходит до ожидания
+	repo := &fakeWebhookRepository{
+		claim:   []data.ClaimedWebhookDelivery{newTestDelivery(server.URL, 1)},
+		onClaim: cancel,
+	}
+	dispatcher := newTestDispatcher(repo, 1, time.Hour)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dispatcher.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not stop after context cancel")
+	}
+
+	if repo.claims != 1 {
+		t.Errorf("claims = %d, want 1", repo.claims)
+	}
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddModelsNeedsRetrain, downAddModelsNeedsRetrain)
+}
+
+func upAddModelsNeedsRetrain(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE models ADD COLUMN needs_retrain BOOLEAN NOT NULL DEFAULT(false);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddModelsNeedsRetrain(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE models DROP COLUMN needs_retrain;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddModelsLastTrainedAt, downAddModelsLastTrainedAt)
+}
+
+func upAddModelsLastTrainedAt(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE models ADD COLUMN last_trained_at TIMESTAMPTZ;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddModelsLastTrainedAt(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE models DROP COLUMN last_trained_at;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateTrainJobsTable, downCreateTrainJobsTable)
+}
+
+func upCreateTrainJobsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TYPE train_status ADD VALUE IF NOT EXISTS 'failed';`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE TYPE train_job_status AS ENUM ('queued', 'retrying', 'succeeded', 'failed');`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE TABLE train_jobs
+	(
+	    job_id CHAR(36) PRIMARY KEY,
+	    user_id CHAR(36) NOT NULL,
+	    model_type model_type NOT NULL,
+	    job_type VARCHAR(16) NOT NULL,
+	    priority SMALLINT NOT NULL DEFAULT(0),
+
+	    status train_job_status NOT NULL DEFAULT('queued'),
+	    attempts INT NOT NULL DEFAULT(0),
+	    error TEXT,
+	    s3_key VARCHAR(128),
+	    metrics JSONB,
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    finished_at TIMESTAMPTZ
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX train_jobs_user_id_model_type_idx ON train_jobs (user_id, model_type, created_at);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateTrainJobsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE train_jobs;`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DROP TYPE IF EXISTS train_job_status CASCADE;`)
+	if err != nil {
+		return err
+	}
+	// значение enum нельзя удалить, поэтому модели с ошибкой обучения возвращаем к статусу not_train
+	_, err = tx.ExecContext(ctx, `UPDATE models SET train_status = 'not_train' WHERE train_status = 'failed';`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
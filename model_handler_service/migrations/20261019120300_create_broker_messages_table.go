@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateBrokerMessagesTable, downCreateBrokerMessagesTable)
+}
+
+func upCreateBrokerMessagesTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE broker_messages
+	(
+	    message_id BIGSERIAL PRIMARY KEY,
+	    queue VARCHAR(256) NOT NULL,
+	    body BYTEA NOT NULL,
+	    priority SMALLINT NOT NULL DEFAULT 0,
+	    attempt INT NOT NULL DEFAULT 0,
+	    available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    locked_until TIMESTAMPTZ,
+	    dead BOOLEAN NOT NULL DEFAULT false,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+
+	CREATE INDEX broker_messages_queue_idx ON broker_messages (queue, priority DESC, message_id) WHERE NOT dead;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateBrokerMessagesTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE broker_messages;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
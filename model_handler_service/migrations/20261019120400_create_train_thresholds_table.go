@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateTrainThresholdsTable, downCreateTrainThresholdsTable)
+}
+
+func upCreateTrainThresholdsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE train_thresholds
+	(
+	    model_type model_type PRIMARY KEY,
+	    train_threshold BIGINT NOT NULL,
+	    tune_threshold BIGINT NOT NULL,
+	    priority JSONB NOT NULL DEFAULT('{}'),
+
+	    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateTrainThresholdsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE train_thresholds;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
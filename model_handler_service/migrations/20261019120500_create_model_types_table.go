@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateModelTypesTable, downCreateModelTypesTable)
+}
+
+// modelTypeTables - таблицы, ссылающиеся на тип модели
+var modelTypeTables = []string{"models", "train_jobs", "train_thresholds"}
+
+func upCreateModelTypesTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE model_types
+	(
+	    model_type VARCHAR(64) PRIMARY KEY,
+	    description VARCHAR(256) NOT NULL DEFAULT(''),
+	    features_schema JSONB NOT NULL DEFAULT('[]'),
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO model_types (model_type, description, features_schema) VALUES (
+	    'face_model',
+	    'Модель усталости по лицевым признакам',
+	    '[
+	        {"name": "frame_count", "type": "int"},
+	        {"name": "eye", "type": "float"},
+	        {"name": "mouth", "type": "float"},
+	        {"name": "perimeter_eye", "type": "float"},
+	        {"name": "perimeter_mouth", "type": "float"},
+	        {"name": "x_angle", "type": "float"},
+	        {"name": "y_angle", "type": "float"},
+	        {"name": "label", "type": "int"}
+	    ]'
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Типы моделей, уже встречающиеся в данных, переносятся в справочник,
+	// после чего перечисление заменяется внешним ключом на справочник
+	for _, table := range modelTypeTables {
+		_, err = tx.ExecContext(ctx, `
+		ALTER TABLE `+table+` ALTER COLUMN model_type TYPE VARCHAR(64) USING model_type::text;
+		INSERT INTO model_types (model_type) SELECT DISTINCT model_type FROM `+table+` ON CONFLICT DO NOTHING;
+		ALTER TABLE `+table+` ADD CONSTRAINT `+table+`_model_type_fkey
+		    FOREIGN KEY (model_type) REFERENCES model_types (model_type);`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DROP TYPE model_type;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateModelTypesTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TYPE model_type AS ENUM ('face_model');`)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range modelTypeTables {
+		_, err = tx.ExecContext(ctx, `
+		ALTER TABLE `+table+` DROP CONSTRAINT `+table+`_model_type_fkey;
+		DELETE FROM `+table+` WHERE model_type <> 'face_model';
+		ALTER TABLE `+table+` ALTER COLUMN model_type TYPE model_type USING model_type::model_type;`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DROP TABLE model_types;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
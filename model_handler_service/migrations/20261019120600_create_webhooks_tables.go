@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateWebhooksTables, downCreateWebhooksTables)
+}
+
+func upCreateWebhooksTables(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE organization_users
+	(
+	    user_id CHAR(36) PRIMARY KEY,
+	    organization_id VARCHAR(64) NOT NULL,
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX organization_users_organization_id_idx ON organization_users (organization_id);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE TABLE webhook_endpoints
+	(
+	    endpoint_id CHAR(36) PRIMARY KEY,
+	    organization_id VARCHAR(64) NOT NULL,
+	    url VARCHAR(2048) NOT NULL,
+	    secret VARCHAR(128) NOT NULL,
+	    event_types VARCHAR(64)[] NOT NULL,
+	    is_active BOOLEAN NOT NULL DEFAULT(true),
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX webhook_endpoints_organization_id_idx ON webhook_endpoints (organization_id);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE TABLE webhook_deliveries
+	(
+	    delivery_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	    endpoint_id CHAR(36) NOT NULL REFERENCES webhook_endpoints (endpoint_id) ON DELETE CASCADE,
+	    event_id CHAR(36) NOT NULL,
+	    event_type VARCHAR(64) NOT NULL,
+	    payload JSONB NOT NULL,
+
+	    status VARCHAR(16) NOT NULL DEFAULT('pending'),
+	    attempts INT NOT NULL DEFAULT(0),
+	    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    last_status_code INT,
+	    last_error TEXT,
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    delivered_at TIMESTAMPTZ
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, delivery_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateWebhooksTables(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE webhook_deliveries;`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DROP TABLE webhook_endpoints;`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DROP TABLE organization_users;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
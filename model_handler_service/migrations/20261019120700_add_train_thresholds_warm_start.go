@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddTrainThresholdsWarmStart, downAddTrainThresholdsWarmStart)
+}
+
+func upAddTrainThresholdsWarmStart(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE train_thresholds ADD COLUMN warm_start BOOLEAN NOT NULL DEFAULT(false);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddTrainThresholdsWarmStart(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE train_thresholds DROP COLUMN warm_start;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateModelEvaluationsTable, downCreateModelEvaluationsTable)
+}
+
+func upCreateModelEvaluationsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE model_evaluations
+	(
+	    evaluation_id CHAR(36) PRIMARY KEY,
+	    user_id CHAR(36) NOT NULL,
+	    model_type VARCHAR(64) NOT NULL REFERENCES model_types (model_type),
+	    baseline_s3_key VARCHAR(128) NOT NULL,
+	    candidate_s3_key VARCHAR(128) NOT NULL,
+	    video_ids VARCHAR(64)[] NOT NULL,
+
+	    status VARCHAR(16) NOT NULL DEFAULT('pending'),
+	    job_id CHAR(36),
+	    baseline_metrics JSONB,
+	    candidate_metrics JSONB,
+	    error TEXT,
+
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	    finished_at TIMESTAMPTZ
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	CREATE INDEX model_evaluations_user_id_model_type_idx ON model_evaluations (user_id, model_type, created_at);
+	CREATE INDEX model_evaluations_pending_idx ON model_evaluations (created_at) WHERE status = 'pending';
+	CREATE UNIQUE INDEX model_evaluations_job_id_idx ON model_evaluations (job_id);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateModelEvaluationsTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE model_evaluations;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddModelsS3Checksum, downAddModelsS3Checksum)
+}
+
+func upAddModelsS3Checksum(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	ALTER TABLE models ADD COLUMN s3_checksum CHAR(64);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddModelsS3Checksum(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE models DROP COLUMN s3_checksum;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upResetFailedModels, downResetFailedModels)
+}
+
+// upResetFailedModels - модели, помеченные failed после неудачной задачи, тренер больше не выбирал.
+// Обученные модели возвращаются к дообучению, остальные - к обучению
+func upResetFailedModels(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	UPDATE models
+	SET train_status = CASE WHEN s3_key IS NOT NULL THEN 'train'::train_status ELSE 'not_train'::train_status END
+	WHERE train_status = 'failed';`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downResetFailedModels(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
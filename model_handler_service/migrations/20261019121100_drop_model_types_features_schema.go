@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDropModelTypesFeaturesSchema, downDropModelTypesFeaturesSchema)
+}
+
+// upDropModelTypesFeaturesSchema - схема признаков хранится в feature_schemas сервиса face_features_storage,
+// по которой создаются таблицы признаков. Копия в справочнике типов не читалась и расходилась с ней
+func upDropModelTypesFeaturesSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE model_types DROP COLUMN features_schema;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downDropModelTypesFeaturesSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE model_types ADD COLUMN features_schema JSONB NOT NULL DEFAULT('[]');`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
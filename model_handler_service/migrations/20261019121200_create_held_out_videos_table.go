@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreateHeldOutVideosTable, downCreateHeldOutVideosTable)
+}
+
+// upCreateHeldOutVideosTable - отложенные видео пользователей не участвуют в обучении моделей и используются
+// для сравнения версий. Видео уже созданных сравнений откладываются, чтобы не попасть в следующие обучения
+func upCreateHeldOutVideosTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+	CREATE TABLE held_out_videos
+	(
+	    user_id CHAR(36) NOT NULL,
+	    model_type VARCHAR(64) NOT NULL REFERENCES model_types (model_type),
+	    video_id VARCHAR(64) NOT NULL,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+	    PRIMARY KEY (user_id, model_type, video_id)
+	);
+
+	INSERT INTO held_out_videos (user_id, model_type, video_id, created_at)
+	SELECT e.user_id, e.model_type, v.video_id, min(e.created_at)
+	FROM model_evaluations e CROSS JOIN LATERAL unnest(e.video_ids) AS v(video_id)
+	GROUP BY e.user_id, e.model_type, v.video_id;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downCreateHeldOutVideosTable(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE held_out_videos;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddModelTypesFeaturesSchema, downAddModelTypesFeaturesSchema)
+}
+
+// upAddModelTypesFeaturesSchema - справочник типов снова хранит схему признаков: типы и их схемы изменяются
+// только через справочник, который применяет схему к таблицам признаков face_features_storage
+func upAddModelTypesFeaturesSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE model_types ADD COLUMN features_schema JSONB NOT NULL DEFAULT('[]');`)
+	if err != nil {
+		return err
+	}
+
+	// схема признаков лица совпадает с feature_schemas сервиса face_features_storage
+	_, err = tx.ExecContext(ctx, `
+	UPDATE model_types SET features_schema = '[
+	    {"name": "frame_count", "type": "int"},
+	    {"name": "eye", "type": "float"},
+	    {"name": "mouth", "type": "float"},
+	    {"name": "perimeter_eye", "type": "float"},
+	    {"name": "perimeter_mouth", "type": "float"},
+	    {"name": "x_angle", "type": "float"},
+	    {"name": "y_angle", "type": "float"}
+	]'
+	WHERE model_type = 'face_model';`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func downAddModelTypesFeaturesSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE model_types DROP COLUMN features_schema;`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
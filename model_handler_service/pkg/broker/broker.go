@@ -0,0 +1,94 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	TypeRabbitMQ = "rabbitmq"
+	TypePostgres = "postgres"
+)
+
+var ErrClosed = errors.New("broker is closed")
+
+// Acknowledger - подтверждение обработки сообщения конкретной реализацией брокера
+type Acknowledger interface {
+	Ack() error
+	// Nack - отклоняет сообщение без повторной доставки, и оно попадает в недоставленные
+	Nack() error
+	// Retry - откладывает повторную доставку с увеличенным номером попытки,
+	// а при исчерпании попыток переносит сообщение в недоставленные
+	Retry() error
+}
+
+// Delivery - сообщение, полученное из очереди
+type Delivery struct {
+	Acknowledger
+
+	Body     []byte
+	Priority uint8
+	Attempt  int
+}
+
+type Publisher interface {
+	Publish(queue string, message []byte) error
+	PublishWithPriority(queue string, message []byte, priority uint8) error
+}
+
+type Consumer interface {
+	// Consume - подписывается на очередь с ручным подтверждением сообщений.
+	// Канал сообщений закрывается при потере соединения с брокером
+	Consume(queue string) (<-chan Delivery, func(), error)
+}
+
+type Broker interface {
+	Publisher
+	Consumer
+
+	InitQueues(queues []string) error
+	IsHealthy() bool
+	Close()
+}
+
+type Config struct {
+	Type string
+
+	RabbitMQ rabbitmq.ConfigRabbitMQ
+
+	// PollInterval - период опроса таблицы очереди в Postgres
+	PollInterval time.Duration
+	// VisibilityTimeout - время, на которое полученное из Postgres сообщение скрыто от других потребителей
+	VisibilityTimeout time.Duration
+}
+
+// New - создает брокер выбранного в конфигурации типа. MemoryBroker здесь не выбирается:
+// model-trainer, results-consumer и тренер работают в разных процессах и не видят очереди друг друга в памяти
+func New(cfg Config, db postgresql.DB, logger *zap.Logger) (Broker, error) {
+	op := "broker.New"
+
+	switch cfg.Type {
+	case TypeRabbitMQ:
+		conn, err := rabbitmq.NewRabbitMQConnection(cfg.RabbitMQ)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return NewRabbitMQBroker(conn), nil
+	case TypePostgres:
+		return NewPostgresBroker(
+			db,
+			cfg.RabbitMQ.Prefetch,
+			cfg.RabbitMQ.RetryDelay,
+			cfg.RabbitMQ.MaxAttempts,
+			cfg.PollInterval,
+			cfg.VisibilityTimeout,
+			logger,
+		), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown broker type: %s", op, cfg.Type)
+	}
+}
@@ -0,0 +1,283 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryMessage struct {
+	seq      uint64
+	body     []byte
+	priority uint8
+	attempt  int
+}
+
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages []memoryMessage
+	dead     []memoryMessage
+	// notify - сигнал ожидающим потребителям о появлении сообщения
+	notify chan struct{}
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{notify: make(chan struct{}, 1)}
+}
+
+// push - добавляет сообщение, сохраняя порядок по убыванию приоритета и порядку публикации
+func (q *memoryQueue) push(msg memoryMessage) {
+	q.mu.Lock()
+	i := sort.Search(len(q.messages), func(i int) bool {
+		m := q.messages[i]
+		return m.priority < msg.priority || (m.priority == msg.priority && m.seq > msg.seq)
+	})
+	q.messages = append(q.messages, memoryMessage{})
+	copy(q.messages[i+1:], q.messages[i:])
+	q.messages[i] = msg
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+func (q *memoryQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop - ожидает и извлекает сообщение с наибольшим приоритетом
+func (q *memoryQueue) pop(stop, done <-chan struct{}) (memoryMessage, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.messages) > 0 {
+			msg := q.messages[0]
+			q.messages = q.messages[1:]
+			rest := len(q.messages)
+			q.mu.Unlock()
+
+			// сигнал мог быть один на несколько сообщений, будим следующего потребителя
+			if rest > 0 {
+				q.signal()
+			}
+			return msg, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-stop:
+			return memoryMessage{}, false
+		case <-done:
+			return memoryMessage{}, false
+		}
+	}
+}
+
+func (q *memoryQueue) deadLetter(msg memoryMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = append(q.dead, msg)
+}
+
+// MemoryBroker - брокер на каналах внутри одного процесса. Сообщения не переживают перезапуск
+// и доступны только потребителям из того же процесса, поэтому брокер используется в интеграционных тестах,
+// где издатель и потребители запущены вместе, и не выбирается конфигурацией сервиса
+type MemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	seq    uint64
+
+	prefetch    int
+	retryDelay  time.Duration
+	maxAttempts int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewMemoryBroker(prefetch int, retryDelay time.Duration, maxAttempts int) *MemoryBroker {
+	if prefetch < 1 {
+		prefetch = 1
+	}
+
+	return &MemoryBroker{
+		queues:      make(map[string]*memoryQueue),
+		prefetch:    prefetch,
+		retryDelay:  retryDelay,
+		maxAttempts: maxAttempts,
+		done:        make(chan struct{}),
+	}
+}
+
+func (b *MemoryBroker) queue(name string) *memoryQueue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[name]
+	if !ok {
+		q = newMemoryQueue()
+		b.queues[name] = q
+	}
+	return q
+}
+
+func (b *MemoryBroker) nextSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	return b.seq
+}
+
+func (b *MemoryBroker) InitQueues(queues []string) error {
+	for _, queue := range queues {
+		b.queue(queue)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) IsHealthy() bool {
+	select {
+	case <-b.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *MemoryBroker) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+}
+
+func (b *MemoryBroker) Publish(queue string, message []byte) error {
+	return b.PublishWithPriority(queue, message, 0)
+}
+
+func (b *MemoryBroker) PublishWithPriority(queue string, message []byte, priority uint8) error {
+	op := "broker.MemoryBroker.PublishWithPriority"
+
+	if !b.IsHealthy() {
+		return fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+
+	b.queue(queue).push(memoryMessage{
+		seq:      b.nextSeq(),
+		body:     message,
+		priority: priority,
+	})
+
+	return nil
+}
+
+func (b *MemoryBroker) Consume(queue string) (<-chan Delivery, func(), error) {
+	op := "broker.MemoryBroker.Consume"
+
+	if !b.IsHealthy() {
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+
+	q := b.queue(queue)
+	deliveries := make(chan Delivery)
+	stop := make(chan struct{})
+	// inFlight - ограничивает количество неподтвержденных сообщений, как prefetch в RabbitMQ
+	inFlight := make(chan struct{}, b.prefetch)
+
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case inFlight <- struct{}{}:
+			case <-stop:
+				return
+			case <-b.done:
+				return
+			}
+
+			msg, ok := q.pop(stop, b.done)
+			if !ok {
+				return
+			}
+
+			d := Delivery{
+				Acknowledger: &memoryAcknowledger{broker: b, queue: q, msg: msg, inFlight: inFlight},
+				Body:         msg.body,
+				Priority:     msg.priority,
+				Attempt:      msg.attempt,
+			}
+
+			select {
+			case deliveries <- d:
+			case <-stop:
+				// сообщение так и не было получено потребителем, возвращаем его в очередь
+				q.push(msg)
+				return
+			case <-b.done:
+				return
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	closeFunc := func() {
+		stopOnce.Do(func() {
+			close(stop)
+		})
+	}
+
+	return deliveries, closeFunc, nil
+}
+
+type memoryAcknowledger struct {
+	broker   *MemoryBroker
+	queue    *memoryQueue
+	msg      memoryMessage
+	inFlight chan struct{}
+	once     sync.Once
+}
+
+// release - освобождает место под следующее неподтвержденное сообщение
+func (a *memoryAcknowledger) release() bool {
+	released := false
+	a.once.Do(func() {
+		<-a.inFlight
+		released = true
+	})
+	return released
+}
+
+func (a *memoryAcknowledger) Ack() error {
+	a.release()
+	return nil
+}
+
+func (a *memoryAcknowledger) Nack() error {
+	if a.release() {
+		a.queue.deadLetter(a.msg)
+	}
+	return nil
+}
+
+func (a *memoryAcknowledger) Retry() error {
+	if !a.release() {
+		return nil
+	}
+
+	msg := a.msg
+	msg.attempt++
+	if msg.attempt >= a.broker.maxAttempts {
+		a.queue.deadLetter(a.msg)
+		return nil
+	}
+
+	time.AfterFunc(a.broker.retryDelay, func() {
+		if a.broker.IsHealthy() {
+			a.queue.push(msg)
+		}
+	})
+
+	return nil
+}
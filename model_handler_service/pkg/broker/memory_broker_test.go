@@ -0,0 +1,160 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, msgs <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		if !ok {
+			t.Fatal("deliveries channel closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery in time")
+	}
+	return Delivery{}
+}
+
+func TestMemoryBrokerDeliversByPriority(t *testing.T) {
+	b := NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	for _, m := range []struct {
+		body     string
+		priority uint8
+	}{{"low", 0}, {"high", 5}, {"low-2", 0}, {"high-2", 5}} {
+		if err := b.PublishWithPriority("jobs", []byte(m.body), m.priority); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	for _, want := range []string{"high", "high-2", "low", "low-2"} {
+		d := receive(t, msgs)
+		if string(d.Body) != want {
+			t.Errorf("body = %q, want %q", d.Body, want)
+		}
+		if err = d.Ack(); err != nil {
+			t.Fatalf("ack: %v", err)
+		}
+	}
+}
+
+func TestMemoryBrokerRetriesUntilDeadLetter(t *testing.T) {
+	b := NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	if err := b.Publish("jobs", []byte("job")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	for attempt := 0; attempt < 3; attempt++ {
+		d := receive(t, msgs)
+		if d.Attempt != attempt {
+			t.Errorf("attempt = %d, want %d", d.Attempt, attempt)
+		}
+		if err = d.Retry(); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+	}
+
+	select {
+	case d := <-msgs:
+		t.Fatalf("unexpected delivery after max attempts: %q", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q := b.queue("jobs")
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.dead) != 1 || string(q.dead[0].body) != "job" {
+		t.Errorf("dead letters = %+v, want one job", q.dead)
+	}
+}
+
+func TestMemoryBrokerPrefetchLimitsUnacked(t *testing.T) {
+	b := NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	for _, body := range []string{"first", "second"} {
+		if err := b.Publish("jobs", []byte(body)); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	first := receive(t, msgs)
+	select {
+	case d := <-msgs:
+		t.Fatalf("second delivery %q before ack of the first", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err = first.Ack(); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if d := receive(t, msgs); string(d.Body) != "second" {
+		t.Errorf("body = %q, want second", d.Body)
+	}
+}
+
+func TestMemoryBrokerCloseFuncReturnsUndeliveredMessage(t *testing.T) {
+	b := NewMemoryBroker(1, time.Millisecond, 3)
+	defer b.Close()
+
+	_, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err = b.Publish("jobs", []byte("job")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	// потребитель отписывается, не прочитав сообщение, и сообщение возвращается в очередь
+	time.Sleep(50 * time.Millisecond)
+	closeFunc()
+
+	q := b.queue("jobs")
+	waitQueued := time.Now().Add(time.Second)
+	for {
+		q.mu.Lock()
+		queued := len(q.messages)
+		q.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		if time.Now().After(waitQueued) {
+			t.Fatalf("queued = %d, want 1", queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+	if d := receive(t, msgs); string(d.Body) != "job" {
+		t.Errorf("body = %q, want job", d.Body)
+	}
+}
@@ -0,0 +1,305 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const MessagesTable = "broker_messages"
+
+// claimErrorBackoff - пауза после ошибки базы при получении сообщений, чтобы потребитель
+// не переподписывался в цикле, пока база недоступна
+const claimErrorBackoff = 5 * time.Second
+
+// claimQuery - забирает доступные сообщения очереди, пропуская строки, заблокированные другими потребителями.
+// Сообщение скрыто от остальных потребителей до locked_until, после чего при отсутствии подтверждения доставляется снова
+const claimQuery = `
+UPDATE broker_messages SET locked_until = now() + make_interval(secs => $1)
+WHERE message_id IN (
+    SELECT message_id FROM broker_messages
+    WHERE queue = $2 AND NOT dead AND available_at <= now()
+      AND (locked_until IS NULL OR locked_until < now())
+    ORDER BY priority DESC, message_id
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING message_id, body, priority, attempt`
+
+type postgresMessage struct {
+	MessageID int64  `db:"message_id"`
+	Body      []byte `db:"body"`
+	Priority  int16  `db:"priority"`
+	Attempt   int    `db:"attempt"`
+}
+
+// PostgresBroker - очередь на таблице Postgres с захватом сообщений через SELECT ... FOR UPDATE SKIP LOCKED
+type PostgresBroker struct {
+	db           postgresql.DB
+	queryBuilder sq.StatementBuilderType
+
+	prefetch          int
+	retryDelay        time.Duration
+	maxAttempts       int
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	claimErrorBackoff time.Duration
+
+	logger    *zap.Logger
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPostgresBroker(
+	db postgresql.DB,
+	prefetch int,
+	retryDelay time.Duration,
+	maxAttempts int,
+	pollInterval time.Duration,
+	visibilityTimeout time.Duration,
+	logger *zap.Logger,
+) *PostgresBroker {
+	if prefetch < 1 {
+		prefetch = 1
+	}
+
+	return &PostgresBroker{
+		db:                db,
+		queryBuilder:      sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		prefetch:          prefetch,
+		retryDelay:        retryDelay,
+		maxAttempts:       maxAttempts,
+		pollInterval:      pollInterval,
+		visibilityTimeout: visibilityTimeout,
+		claimErrorBackoff: claimErrorBackoff,
+		logger:            logger,
+		done:              make(chan struct{}),
+	}
+}
+
+// InitQueues - очереди хранятся в одной таблице, создаваемой миграцией, поэтому объявлять их не нужно
+func (b *PostgresBroker) InitQueues(_ []string) error {
+	return nil
+}
+
+func (b *PostgresBroker) IsHealthy() bool {
+	select {
+	case <-b.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *PostgresBroker) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+}
+
+func (b *PostgresBroker) Publish(queue string, message []byte) error {
+	return b.PublishWithPriority(queue, message, 0)
+}
+
+func (b *PostgresBroker) PublishWithPriority(queue string, message []byte, priority uint8) error {
+	op := "broker.PostgresBroker.PublishWithPriority"
+
+	if !b.IsHealthy() {
+		return fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+
+	q, i, err := b.queryBuilder.
+		Insert(MessagesTable).
+		Columns("queue", "body", "priority").
+		Values(queue, message, int16(priority)).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// публикация не участвует в транзакции вызывающего кода, как и в остальных брокерах
+	ctx := context.Background()
+	_, err = b.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (b *PostgresBroker) Consume(queue string) (<-chan Delivery, func(), error) {
+	op := "broker.PostgresBroker.Consume"
+
+	if !b.IsHealthy() {
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+
+	deliveries := make(chan Delivery)
+	stop := make(chan struct{})
+	// inFlight - ограничивает количество неподтвержденных сообщений, как prefetch в RabbitMQ
+	inFlight := make(chan struct{}, b.prefetch)
+
+	go func() {
+		defer close(deliveries)
+
+		ticker := time.NewTicker(b.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			free := cap(inFlight) - len(inFlight)
+			if free > 0 {
+				msgs, err := b.claim(queue, free)
+				if err != nil {
+					// при ошибке базы выжидаем и закрываем канал, потребитель подпишется заново
+					b.logger.With(zap.String("queue", queue)).Error(err.Error())
+					select {
+					case <-time.After(b.claimErrorBackoff):
+					case <-stop:
+					case <-b.done:
+					}
+					return
+				}
+
+				for _, msg := range msgs {
+					inFlight <- struct{}{}
+					d := Delivery{
+						Acknowledger: &postgresAcknowledger{broker: b, msg: msg, inFlight: inFlight},
+						Body:         msg.Body,
+						Priority:     uint8(msg.Priority),
+						Attempt:      msg.Attempt,
+					}
+
+					select {
+					case deliveries <- d:
+					case <-stop:
+						// неполученные сообщения станут доступны снова по истечении visibilityTimeout
+						return
+					case <-b.done:
+						return
+					}
+				}
+
+				// очередь не пуста, забираем следующую порцию без ожидания
+				if len(msgs) == free {
+					continue
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			case <-b.done:
+				return
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	closeFunc := func() {
+		stopOnce.Do(func() {
+			close(stop)
+		})
+	}
+
+	return deliveries, closeFunc, nil
+}
+
+func (b *PostgresBroker) claim(queue string, limit int) ([]postgresMessage, error) {
+	op := "broker.PostgresBroker.claim"
+
+	ctx := context.Background()
+	var msgs []postgresMessage
+	err := b.db.Client(ctx).Select(ctx, &msgs, claimQuery, b.visibilityTimeout.Seconds(), queue, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return msgs, nil
+}
+
+func (b *PostgresBroker) exec(op string, builder sq.Sqlizer) error {
+	q, i, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx := context.Background()
+	_, err = b.db.Client(ctx).Exec(ctx, q, i...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type postgresAcknowledger struct {
+	broker   *PostgresBroker
+	msg      postgresMessage
+	inFlight chan struct{}
+	once     sync.Once
+}
+
+// release - освобождает место под следующее неподтвержденное сообщение
+func (a *postgresAcknowledger) release() bool {
+	released := false
+	a.once.Do(func() {
+		<-a.inFlight
+		released = true
+	})
+	return released
+}
+
+func (a *postgresAcknowledger) Ack() error {
+	op := "broker.postgresAcknowledger.Ack"
+
+	if !a.release() {
+		return nil
+	}
+
+	return a.broker.exec(op, a.broker.queryBuilder.
+		Delete(MessagesTable).
+		Where(sq.Eq{"message_id": a.msg.MessageID}))
+}
+
+func (a *postgresAcknowledger) Nack() error {
+	op := "broker.postgresAcknowledger.Nack"
+
+	if !a.release() {
+		return nil
+	}
+
+	return a.broker.exec(op, a.broker.queryBuilder.
+		Update(MessagesTable).
+		Set("dead", true).
+		Set("locked_until", nil).
+		Where(sq.Eq{"message_id": a.msg.MessageID}))
+}
+
+func (a *postgresAcknowledger) Retry() error {
+	op := "broker.postgresAcknowledger.Retry"
+
+	if !a.release() {
+		return nil
+	}
+
+	attempt := a.msg.Attempt + 1
+	if attempt >= a.broker.maxAttempts {
+		return a.broker.exec(op, a.broker.queryBuilder.
+			Update(MessagesTable).
+			Set("dead", true).
+			Set("locked_until", nil).
+			Where(sq.Eq{"message_id": a.msg.MessageID}))
+	}
+
+	return a.broker.exec(op, a.broker.queryBuilder.
+		Update(MessagesTable).
+		Set("attempt", attempt).
+		Set("available_at", sq.Expr("now() + make_interval(secs => ?)", a.broker.retryDelay.Seconds())).
+		Set("locked_until", nil).
+		Where(sq.Eq{"message_id": a.msg.MessageID}))
+}
@@ -0,0 +1,99 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/postgresql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unavailableDB - база, каждый запрос к которой завершается ошибкой
+type unavailableDB struct {
+	selects atomic.Int32
+}
+
+func (db *unavailableDB) Client(_ context.Context) postgresql.PGXdb {
+	return db
+}
+
+func (db *unavailableDB) Acquire(_ context.Context) (postgresql.Connection, error) {
+	return nil, errors.New("database is unavailable")
+}
+
+func (db *unavailableDB) Close() {}
+
+func (db *unavailableDB) Select(_ context.Context, _ interface{}, _ string, _ ...interface{}) error {
+	db.selects.Add(1)
+	return errors.New("database is unavailable")
+}
+
+func (db *unavailableDB) Get(_ context.Context, _ interface{}, _ string, _ ...interface{}) error {
+	return errors.New("database is unavailable")
+}
+
+func (db *unavailableDB) Exec(_ context.Context, _ string, _ ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("database is unavailable")
+}
+
+func (db *unavailableDB) ExecQueryRow(_ context.Context, _ string, _ ...interface{}) pgx.Row {
+	return nil
+}
+
+func (db *unavailableDB) CopyFrom(_ context.Context, _ pgx.Identifier, _ []string, _ pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("database is unavailable")
+}
+
+func TestPostgresBrokerBacksOffOnClaimError(t *testing.T) {
+	db := &unavailableDB{}
+	b := NewPostgresBroker(db, 1, time.Millisecond, 3, time.Millisecond, time.Minute, zap.NewNop())
+	b.claimErrorBackoff = 50 * time.Millisecond
+	defer b.Close()
+
+	start := time.Now()
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Fatal("got delivery from unavailable database")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deliveries channel is not closed")
+	}
+
+	// канал закрывается только после паузы, а не сразу после ошибки
+	if elapsed := time.Since(start); elapsed < b.claimErrorBackoff {
+		t.Errorf("channel closed after %s, want at least %s", elapsed, b.claimErrorBackoff)
+	}
+	if selects := db.selects.Load(); selects != 1 {
+		t.Errorf("claims = %d, want 1", selects)
+	}
+}
+
+func TestPostgresBrokerStopsBackoffOnClose(t *testing.T) {
+	b := NewPostgresBroker(&unavailableDB{}, 1, time.Millisecond, 3, time.Millisecond, time.Minute, zap.NewNop())
+	b.claimErrorBackoff = time.Hour
+
+	msgs, closeFunc, err := b.Consume("jobs")
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	defer closeFunc()
+
+	b.Close()
+
+	select {
+	case <-msgs:
+	case <-time.After(time.Second):
+		t.Fatal("deliveries channel is not closed after broker close")
+	}
+}
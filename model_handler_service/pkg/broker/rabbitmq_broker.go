@@ -0,0 +1,78 @@
+package broker
+
+import (
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/model_handler_service/pkg/rabbitmq"
+	"github.com/streadway/amqp"
+	"sync"
+)
+
+// RabbitMQBroker - брокер поверх RabbitMQConnection
+type RabbitMQBroker struct {
+	*rabbitmq.RabbitMQConnection
+}
+
+func NewRabbitMQBroker(conn *rabbitmq.RabbitMQConnection) *RabbitMQBroker {
+	return &RabbitMQBroker{RabbitMQConnection: conn}
+}
+
+type rabbitMQAcknowledger struct {
+	conn     *rabbitmq.RabbitMQConnection
+	queue    string
+	delivery amqp.Delivery
+}
+
+func (a rabbitMQAcknowledger) Ack() error {
+	return a.conn.Ack(a.delivery)
+}
+
+func (a rabbitMQAcknowledger) Nack() error {
+	return a.conn.Nack(a.delivery)
+}
+
+func (a rabbitMQAcknowledger) Retry() error {
+	return a.conn.Retry(a.queue, a.delivery)
+}
+
+func (b *RabbitMQBroker) Consume(queue string) (<-chan Delivery, func(), error) {
+	op := "broker.RabbitMQBroker.Consume"
+
+	msgs, closeFunc, err := b.RabbitMQConnection.Consume(queue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stop := make(chan struct{})
+	deliveries := make(chan Delivery)
+	go b.forward(queue, msgs, deliveries, stop)
+
+	var stopOnce sync.Once
+	stopFunc := func() {
+		stopOnce.Do(func() {
+			close(stop)
+			closeFunc()
+		})
+	}
+
+	return deliveries, stopFunc, nil
+}
+
+// forward - передает сообщения подписки потребителю, пока подписка не закрыта или потребитель не отписался
+func (b *RabbitMQBroker) forward(queue string, msgs <-chan amqp.Delivery, deliveries chan<- Delivery, stop <-chan struct{}) {
+	defer close(deliveries)
+	for d := range msgs {
+		delivery := Delivery{
+			Acknowledger: rabbitMQAcknowledger{conn: b.RabbitMQConnection, queue: queue, delivery: d},
+			Body:         d.Body,
+			Priority:     d.Priority,
+			Attempt:      rabbitmq.Attempt(d),
+		}
+
+		select {
+		case deliveries <- delivery:
+		case <-stop:
+			// неподтвержденное сообщение вернется в очередь при закрытии канала подписки
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package broker
+
+import (
+	"github.com/streadway/amqp"
+	"testing"
+	"time"
+)
+
+func TestRabbitMQBrokerForwardStopsWithoutReader(t *testing.T) {
+	b := &RabbitMQBroker{}
+
+	msgs := make(chan amqp.Delivery, 2)
+	msgs <- amqp.Delivery{Body: []byte("first")}
+	msgs <- amqp.Delivery{Body: []byte("second")}
+
+	deliveries := make(chan Delivery)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		b.forward("jobs", msgs, deliveries, stop)
+		close(done)
+	}()
+
+	if d := <-deliveries; string(d.Body) != "first" {
+		t.Fatalf("body = %q, want first", d.Body)
+	}
+
+	// потребитель больше не читает канал, а подписка еще открыта: горутина не должна зависнуть на отправке
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forward goroutine leaked after stop")
+	}
+
+	if _, ok := <-deliveries; ok {
+		t.Error("deliveries channel is not closed")
+	}
+}
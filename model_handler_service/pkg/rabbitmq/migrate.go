@@ -0,0 +1,161 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+)
+
+const migratingQueueSuffix = ".migrating"
+
+// MigrateQueue - приводит очередь, ее очередь повторов и очередь недоставленных к текущим параметрам объявления.
+// Брокер не меняет параметры существующей очереди (durable, x-max-priority, x-dead-letter-exchange) и отвечает
+// PRECONDITION_FAILED на повторное объявление, поэтому сообщения переносятся во временные очереди,
+// очереди пересоздаются, и сообщения возвращаются обратно. Возвращает количество перенесенных сообщений.
+// Потребители и публикующие в очередь сервисы на время миграции должны быть остановлены
+func (rmq *RabbitMQConnection) MigrateQueue(queue string) (int, error) {
+	op := "rabbitmq.RabbitMQConnection.MigrateQueue"
+
+	upToDate, err := rmq.isQueueUpToDate(queue)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if upToDate {
+		return 0, nil
+	}
+
+	names := []string{queue, RetryQueue(queue), DeadLetterQueue(queue)}
+
+	var moved int
+	for _, name := range names {
+		n, err := rmq.evacuateQueue(name)
+		if err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+		moved += n
+	}
+
+	err = rmq.InitQueues([]string{queue})
+	if err != nil {
+		return moved, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, name := range names {
+		_, err = rmq.moveMessages(name+migratingQueueSuffix, name)
+		if err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = rmq.deleteQueue(name + migratingQueueSuffix)
+		if err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return moved, nil
+}
+
+// isQueueUpToDate - объявляет очереди с текущими параметрами, false - параметры существующих очередей отличаются
+func (rmq *RabbitMQConnection) isQueueUpToDate(queue string) (bool, error) {
+	op := "rabbitmq.RabbitMQConnection.isQueueUpToDate"
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	// после ошибки объявления брокер закрывает канал, и ReleaseChannel его не вернет в пул
+	defer rmq.ReleaseChannel(ch)
+
+	_, err = rmq.declareQueue(ch, queue)
+	if err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// evacuateQueue - переносит сообщения очереди во временную очередь и удаляет исходную очередь
+func (rmq *RabbitMQConnection) evacuateQueue(name string) (int, error) {
+	op := "rabbitmq.RabbitMQConnection.evacuateQueue"
+
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	// временная очередь без дополнительных параметров, чтобы сообщения в ней не истекали и не уходили в другие очереди
+	_, err = ch.QueueDeclare(name+migratingQueueSuffix, true, false, false, false, nil)
+	rmq.ReleaseChannel(ch)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	moved, err := rmq.moveMessages(name, name+migratingQueueSuffix)
+	if err != nil {
+		return moved, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = rmq.deleteQueue(name)
+	if err != nil {
+		return moved, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return moved, nil
+}
+
+// moveMessages - переносит все сообщения из очереди from в очередь to, сохраняя заголовки и приоритет.
+// Отсутствующая очередь from считается пустой
+func (rmq *RabbitMQConnection) moveMessages(from, to string) (int, error) {
+	op := "rabbitmq.RabbitMQConnection.moveMessages"
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rmq.ReleaseChannel(ch)
+
+	var moved int
+	for {
+		d, ok, err := ch.Get(from, false)
+		if err != nil {
+			var amqpErr *amqp.Error
+			if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+				return moved, nil
+			}
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+		if !ok {
+			return moved, nil
+		}
+
+		err = rmq.publish("", to, withAttempt(d, Attempt(d)))
+		if err != nil {
+			_ = d.Nack(false, true)
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = d.Ack(false)
+		if err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+		moved++
+	}
+}
+
+func (rmq *RabbitMQConnection) deleteQueue(name string) error {
+	op := "rabbitmq.RabbitMQConnection.deleteQueue"
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rmq.ReleaseChannel(ch)
+
+	// удаление отсутствующей очереди брокер считает успешным
+	_, err = ch.QueueDelete(name, false, false, false)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -1,23 +1,121 @@
 package rabbitmq
 
 import (
+	"errors"
 	"fmt"
 	"github.com/streadway/amqp"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrPublishNack    = errors.New("message was nacked by broker")
+	ErrConfirmTimeout = errors.New("confirm timeout")
+	ErrNotConnected   = errors.New("connection to broker is not established")
+)
+
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// HealthState - состояние подключения к брокеру
+type HealthState int32
+
+const (
+	StateConnected HealthState = iota
+	StateReconnecting
+	StateClosed
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+type ConfigRabbitMQ struct {
+	URI      string
+	PoolSize int
+	// Durable - очереди переживают перезапуск брокера, а сообщения публикуются как persistent
+	Durable bool
+	// ConfirmTimeout - время ожидания подтверждения публикации от брокера
+	ConfirmTimeout time.Duration
+	// Prefetch - количество неподтвержденных сообщений, которые брокер отдает одному потребителю
+	Prefetch int
+	// RetryDelay - задержка перед повторной доставкой сообщения
+	RetryDelay time.Duration
+	// MaxAttempts - количество попыток обработки сообщения, после которого оно попадает в очередь недоставленных
+	MaxAttempts int
+	// MaxPriority - максимальный приоритет сообщений очереди, 0 - очередь без приоритетов
+	MaxPriority uint8
+}
+
+// Channel - канал из пула, переведенный в режим подтверждения публикаций
+type Channel struct {
+	*amqp.Channel
+	confirms chan amqp.Confirmation
+	closed   chan *amqp.Error
+}
+
+// IsClosed - канал закрыт брокером или вместе с соединением
+func (ch *Channel) IsClosed() bool {
+	select {
+	case <-ch.closed:
+		return true
+	default:
+		return false
+	}
+}
+
 type RabbitMQConnection struct {
 	Connection  *amqp.Connection
-	ChannelPool chan *amqp.Channel
+	ChannelPool chan *Channel
+
+	uri            string
+	poolSize       int
+	durable        bool
+	confirmTimeout time.Duration
+	prefetch       int
+	retryDelay     time.Duration
+	maxAttempts    int
+	maxPriority    uint8
+
+	// очереди, объявленные через InitQueues, объявляются заново после переподключения
+	declaredQueues []string
+
+	mu    sync.RWMutex
+	state atomic.Int32
+	done  chan struct{}
 }
 
-func NewRabbitMQConnection(uri string, poolSize int) (*RabbitMQConnection, error) {
+func NewRabbitMQConnection(cfg ConfigRabbitMQ) (*RabbitMQConnection, error) {
 	op := "rabbitmq.NewRabbitMQConnection"
 
-	var conn *amqp.Connection
+	rmq := &RabbitMQConnection{
+		ChannelPool:    make(chan *Channel, cfg.PoolSize),
+		uri:            cfg.URI,
+		poolSize:       cfg.PoolSize,
+		durable:        cfg.Durable,
+		confirmTimeout: cfg.ConfirmTimeout,
+		prefetch:       cfg.Prefetch,
+		retryDelay:     cfg.RetryDelay,
+		maxAttempts:    cfg.MaxAttempts,
+		maxPriority:    cfg.MaxPriority,
+		done:           make(chan struct{}),
+	}
+	rmq.state.Store(int32(StateReconnecting))
+
 	err := DoWithTries(func() error {
-		var err error
-		conn, err = amqp.Dial(uri)
+		err := rmq.connect()
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
@@ -27,39 +125,178 @@ func NewRabbitMQConnection(uri string, poolSize int) (*RabbitMQConnection, error
 		return nil, err
 	}
 
-	pool := make(chan *amqp.Channel, poolSize)
-	for i := 0; i < poolSize; i++ {
-		ch, err := conn.Channel()
+	return rmq, nil
+}
+
+// connect - устанавливает соединение, заново наполняет пул каналов, объявляет очереди
+// и запускает отслеживание разрыва соединения
+func (rmq *RabbitMQConnection) connect() error {
+	op := "rabbitmq.RabbitMQConnection.connect"
+
+	conn, err := amqp.Dial(rmq.uri)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rmq.mu.Lock()
+	defer rmq.mu.Unlock()
+
+	// соединение могло быть закрыто пользователем, пока шло переподключение
+	if rmq.Health() == StateClosed {
+		conn.Close()
+		return nil
+	}
+
+	rmq.Connection = conn
+
+	// каналы старого соединения уже закрыты, поэтому просто выбрасываем их из пула
+	rmq.drainPool()
+	for i := 0; i < rmq.poolSize; i++ {
+		ch, err := rmq.newChannel(conn)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			conn.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		rmq.ChannelPool <- ch
+	}
+
+	if len(rmq.declaredQueues) > 0 {
+		ch, err := rmq.newChannel(conn)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		for _, queue := range rmq.declaredQueues {
+			_, err = rmq.declareQueue(ch, queue)
+			if err != nil {
+				conn.Close()
+				return fmt.Errorf("%s: %w", op, err)
+			}
 		}
-		pool <- ch
+		ch.Close()
 	}
 
-	return &RabbitMQConnection{
-		Connection:  conn,
-		ChannelPool: pool,
+	rmq.state.Store(int32(StateConnected))
+	go rmq.watchConnection(conn.NotifyClose(make(chan *amqp.Error, 1)))
+
+	return nil
+}
+
+// watchConnection - ждет разрыва соединения и переподключается к брокеру с экспоненциальной задержкой
+func (rmq *RabbitMQConnection) watchConnection(closed chan *amqp.Error) {
+	select {
+	case <-rmq.done:
+		return
+	case <-closed:
+	}
+
+	rmq.state.Store(int32(StateReconnecting))
+
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-rmq.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := rmq.connect(); err == nil {
+			return
+		}
+
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+// Health - текущее состояние подключения к брокеру
+func (rmq *RabbitMQConnection) Health() HealthState {
+	return HealthState(rmq.state.Load())
+}
+
+func (rmq *RabbitMQConnection) IsHealthy() bool {
+	return rmq.Health() == StateConnected
+}
+
+// newChannel - открывает канал и включает на нем режим подтверждения публикаций
+func (rmq *RabbitMQConnection) newChannel(conn *amqp.Connection) (*Channel, error) {
+	op := "rabbitmq.RabbitMQConnection.newChannel"
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = ch.Confirm(false)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Channel{
+		Channel:  ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		closed:   ch.NotifyClose(make(chan *amqp.Error, 1)),
 	}, nil
 }
 
+func (rmq *RabbitMQConnection) drainPool() {
+	for {
+		select {
+		case ch := <-rmq.ChannelPool:
+			ch.Close()
+		default:
+			return
+		}
+	}
+}
+
 func (rmq *RabbitMQConnection) Close() {
-	rmq.Connection.Close()
-	close(rmq.ChannelPool)
-	for ch := range rmq.ChannelPool {
-		ch.Close()
+	rmq.mu.Lock()
+	defer rmq.mu.Unlock()
+
+	if rmq.Health() == StateClosed {
+		return
 	}
+	rmq.state.Store(int32(StateClosed))
+	close(rmq.done)
+
+	rmq.drainPool()
+	rmq.Connection.Close()
 }
 
-func (rmq *RabbitMQConnection) GetChannel() (*amqp.Channel, error) {
-	select {
-	case ch := <-rmq.ChannelPool:
-		return ch, nil
-	default:
-		return rmq.Connection.Channel()
+func (rmq *RabbitMQConnection) GetChannel() (*Channel, error) {
+	op := "rabbitmq.RabbitMQConnection.GetChannel"
+
+	if !rmq.IsHealthy() {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConnected)
+	}
+
+	rmq.mu.RLock()
+	defer rmq.mu.RUnlock()
+
+	for {
+		select {
+		case ch := <-rmq.ChannelPool:
+			// канал мог быть закрыт брокером, например после ошибки объявления очереди
+			if ch.IsClosed() {
+				continue
+			}
+			return ch, nil
+		default:
+			return rmq.newChannel(rmq.Connection)
+		}
 	}
 }
 
-func (rmq *RabbitMQConnection) ReleaseChannel(ch *amqp.Channel) {
+func (rmq *RabbitMQConnection) ReleaseChannel(ch *Channel) {
+	if ch.IsClosed() || !rmq.IsHealthy() {
+		ch.Close()
+		return
+	}
+
 	select {
 	case rmq.ChannelPool <- ch:
 	default:
@@ -75,46 +312,101 @@ func (rmq *RabbitMQConnection) InitQueues(queues []string) error {
 	}
 	defer rmq.ReleaseChannel(ch)
 	for _, queue := range queues {
-		_, err = ch.QueueDeclare(
-			queue,
-			false,
-			false,
-			false,
-			false,
-			nil,
-		)
+		_, err = rmq.declareQueue(ch, queue)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
 	}
 
+	rmq.mu.Lock()
+	rmq.declaredQueues = append(rmq.declaredQueues, queues...)
+	rmq.mu.Unlock()
+
 	return nil
 }
 
+// Publish - публикует сообщение и ждет его подтверждения брокером.
+// Ошибка возвращается, если брокер отклонил сообщение или не подтвердил его за confirmTimeout
 func (rmq *RabbitMQConnection) Publish(queue string, message []byte) error {
 	op := "rabbitmq.RabbitMQConnection.Publish"
+
+	err := rmq.publish("", queue, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        message,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PublishWithPriority - публикует сообщение с приоритетом, ограниченным максимальным приоритетом очереди
+func (rmq *RabbitMQConnection) PublishWithPriority(queue string, message []byte, priority uint8) error {
+	op := "rabbitmq.RabbitMQConnection.PublishWithPriority"
+
+	if priority > rmq.maxPriority {
+		priority = rmq.maxPriority
+	}
+
+	err := rmq.publish("", queue, amqp.Publishing{
+		ContentType: "application/json",
+		Priority:    priority,
+		Body:        message,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// publish - публикует сообщение через канал из пула и ждет подтверждения брокером
+func (rmq *RabbitMQConnection) publish(exchange, routingKey string, msg amqp.Publishing) error {
+	op := "rabbitmq.RabbitMQConnection.publish"
 	ch, err := rmq.GetChannel()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer rmq.ReleaseChannel(ch)
+
+	msg.DeliveryMode = amqp.Transient
+	if rmq.durable {
+		msg.DeliveryMode = amqp.Persistent
+	}
 
 	err = ch.Publish(
-		"",
-		queue,
+		exchange,
+		routingKey,
 		false,
 		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        message,
-		})
+		msg)
 	if err != nil {
+		ch.Close()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	select {
+	case confirm, ok := <-ch.confirms:
+		if !ok {
+			return fmt.Errorf("%s: %w", op, amqp.ErrClosed)
+		}
+		rmq.ReleaseChannel(ch)
+		if !confirm.Ack {
+			return fmt.Errorf("%s: %w", op, ErrPublishNack)
+		}
+	case <-time.After(rmq.confirmTimeout):
+		// подтверждение может прийти позже и быть принято за подтверждение следующей публикации,
+		// поэтому канал в пул не возвращаем
+		ch.Close()
+		return fmt.Errorf("%s: %w", op, ErrConfirmTimeout)
+	}
+
 	return nil
 }
 
+// Consume - подписывается на очередь с ручным подтверждением сообщений (Ack, Nack, Retry).
+// При разрыве соединения канал сообщений закрывается, и после восстановления подключения (IsHealthy)
+// подписку нужно оформить заново
 func (rmq *RabbitMQConnection) Consume(queue string) (<-chan amqp.Delivery, func(), error) {
 	op := "rabbitmq.RabbitMQConnection.Consume"
 	ch, err := rmq.GetChannel()
@@ -122,34 +414,35 @@ func (rmq *RabbitMQConnection) Consume(queue string) (<-chan amqp.Delivery, func
 		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	q, err := ch.QueueDeclare(
-		queue,
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	q, err := rmq.declareQueue(ch, queue)
 	if err != nil {
-		rmq.ReleaseChannel(ch)
+		ch.Close()
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// ограничиваем количество неподтвержденных сообщений у потребителя
+	err = ch.Qos(rmq.prefetch, 0, false)
+	if err != nil {
+		ch.Close()
 		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	msgs, err := ch.Consume(
 		q.Name,
 		"",
-		true,
+		false,
 		false,
 		false,
 		false,
 		nil,
 	)
 	if err != nil {
-		rmq.ReleaseChannel(ch)
+		ch.Close()
 		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	closeFunc := func() { rmq.ReleaseChannel(ch) }
+	// канал с подпиской в пул не возвращаем, неподтвержденные сообщения вернутся в очередь при его закрытии
+	closeFunc := func() { ch.Close() }
 
 	return msgs, closeFunc, nil
 }
@@ -0,0 +1,288 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"github.com/streadway/amqp"
+	"time"
+)
+
+const (
+	// AttemptHeader - заголовок с номером попытки обработки сообщения
+	AttemptHeader = "x-attempt"
+
+	deadLetterExchangeSuffix = ".dlx"
+	deadLetterQueueSuffix    = ".dead"
+	retryQueueSuffix         = ".retry"
+)
+
+func DeadLetterExchange(queue string) string {
+	return queue + deadLetterExchangeSuffix
+}
+
+func DeadLetterQueue(queue string) string {
+	return queue + deadLetterQueueSuffix
+}
+
+func RetryQueue(queue string) string {
+	return queue + retryQueueSuffix
+}
+
+// DeadLetter - сообщение из очереди недоставленных
+type DeadLetter struct {
+	Body    []byte
+	Attempt int
+	Reason  string
+	Time    *time.Time
+}
+
+// declareQueue - объявляет очередь вместе с обменником и очередью недоставленных сообщений,
+// а также очередью отложенных повторов, сообщения из которой по истечении TTL возвращаются в основную очередь
+func (rmq *RabbitMQConnection) declareQueue(ch *Channel, queue string) (amqp.Queue, error) {
+	op := "rabbitmq.RabbitMQConnection.declareQueue"
+
+	err := ch.ExchangeDeclare(
+		DeadLetterExchange(queue),
+		amqp.ExchangeDirect,
+		rmq.durable,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = ch.QueueDeclare(
+		DeadLetterQueue(queue),
+		rmq.durable,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = ch.QueueBind(DeadLetterQueue(queue), queue, DeadLetterExchange(queue), false, nil)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = ch.QueueDeclare(
+		RetryQueue(queue),
+		rmq.durable,
+		false,
+		false,
+		false,
+		amqp.Table{
+			// int32 кодируется так же, как целые числа в других клиентах, и объявление очереди совпадает
+			"x-message-ttl":             int32(rmq.retryDelay.Milliseconds()),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		},
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	args := amqp.Table{
+		"x-dead-letter-exchange": DeadLetterExchange(queue),
+	}
+	if rmq.maxPriority > 0 {
+		args["x-max-priority"] = int32(rmq.maxPriority)
+	}
+
+	q, err := ch.QueueDeclare(
+		queue,
+		rmq.durable,
+		false,
+		false,
+		false,
+		args,
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return q, nil
+}
+
+// Attempt - номер попытки обработки сообщения, начиная с нуля
+func Attempt(d amqp.Delivery) int {
+	switch v := d.Headers[AttemptHeader].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+func (rmq *RabbitMQConnection) Ack(d amqp.Delivery) error {
+	op := "rabbitmq.RabbitMQConnection.Ack"
+
+	err := d.Ack(false)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Nack - отклоняет сообщение без повторной доставки, и оно попадает в очередь недоставленных
+func (rmq *RabbitMQConnection) Nack(d amqp.Delivery) error {
+	op := "rabbitmq.RabbitMQConnection.Nack"
+
+	err := d.Nack(false, false)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Retry - отправляет сообщение на повторную обработку через retryDelay с увеличенным номером попытки.
+// Если попытки исчерпаны, сообщение попадает в очередь недоставленных
+func (rmq *RabbitMQConnection) Retry(queue string, d amqp.Delivery) error {
+	op := "rabbitmq.RabbitMQConnection.Retry"
+
+	attempt := Attempt(d) + 1
+	if attempt >= rmq.maxAttempts {
+		return rmq.Nack(d)
+	}
+
+	err := rmq.publish("", RetryQueue(queue), withAttempt(d, attempt))
+	if err != nil {
+		// не смогли отложить повтор - возвращаем сообщение в очередь сразу
+		_ = d.Nack(false, true)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = d.Ack(false)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PeekDeadLetters - возвращает до limit сообщений из очереди недоставленных, не удаляя их
+func (rmq *RabbitMQConnection) PeekDeadLetters(queue string, limit int) ([]DeadLetter, error) {
+	op := "rabbitmq.RabbitMQConnection.PeekDeadLetters"
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rmq.ReleaseChannel(ch)
+
+	res := make([]DeadLetter, 0, limit)
+	var last *amqp.Delivery
+	for len(res) < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if !ok {
+			break
+		}
+		last = &d
+		res = append(res, toDeadLetter(d))
+	}
+
+	// возвращаем все полученные сообщения обратно в очередь недоставленных
+	if last != nil {
+		err = last.Nack(true, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return res, nil
+}
+
+// ReplayDeadLetters - переносит до limit сообщений из очереди недоставленных в основную очередь,
+// сбрасывая номер попытки. Возвращает количество перенесенных сообщений
+func (rmq *RabbitMQConnection) ReplayDeadLetters(queue string, limit int) (int, error) {
+	op := "rabbitmq.RabbitMQConnection.ReplayDeadLetters"
+	ch, err := rmq.GetChannel()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rmq.ReleaseChannel(ch)
+
+	var replayed int
+	for replayed < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return replayed, fmt.Errorf("%s: %w", op, err)
+		}
+		if !ok {
+			break
+		}
+
+		err = rmq.publish("", queue, withAttempt(d, 0))
+		if err != nil {
+			_ = d.Nack(false, true)
+			return replayed, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = d.Ack(false)
+		if err != nil {
+			return replayed, fmt.Errorf("%s: %w", op, err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// withAttempt - копия сообщения для повторной публикации с заданным номером попытки
+func withAttempt(d amqp.Delivery, attempt int) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		// историю попадания в очередь недоставленных брокер ведет сам
+		if k == "x-death" {
+			continue
+		}
+		headers[k] = v
+	}
+	headers[AttemptHeader] = int32(attempt)
+
+	return amqp.Publishing{
+		Headers:     headers,
+		ContentType: d.ContentType,
+		Priority:    d.Priority,
+		Body:        d.Body,
+	}
+}
+
+func toDeadLetter(d amqp.Delivery) DeadLetter {
+	res := DeadLetter{
+		Body:    d.Body,
+		Attempt: Attempt(d),
+	}
+
+	deaths, ok := d.Headers["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return res
+	}
+	death, ok := deaths[0].(amqp.Table)
+	if !ok {
+		return res
+	}
+	if reason, ok := death["reason"].(string); ok {
+		res.Reason = reason
+	}
+	if t, ok := death["time"].(time.Time); ok {
+		res.Time = &t
+	}
+
+	return res
+}
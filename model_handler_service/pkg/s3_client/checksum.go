@@ -0,0 +1,66 @@
+package s3_client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ChecksumMetadataKey - ключ метаданных объекта (заголовок x-amz-meta-sha256) с SHA-256 содержимого в hex
+const ChecksumMetadataKey = "sha256"
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrObjectNotFound   = errors.New("object not found")
+)
+
+// Checksum - считает SHA-256 содержимого и возвращает файл к началу
+func Checksum(file io.ReadSeeker) (string, error) {
+	op := "s3_client.Checksum"
+
+	h := sha256.New()
+	_, err := io.Copy(h, file)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyingReader - считает SHA-256 прочитанного содержимого и сверяет его с ожидаемым при достижении конца
+type verifyingReader struct {
+	io.ReadCloser
+	key      string
+	hash     hash.Hash
+	expected string
+}
+
+func newVerifyingReader(body io.ReadCloser, key, expected string) *verifyingReader {
+	return &verifyingReader{
+		ReadCloser: body,
+		key:        key,
+		hash:       sha256.New(),
+		expected:   expected,
+	}
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, fmt.Errorf("%w: %s: expected %s, got %s", ErrChecksumMismatch, r.key, r.expected, actual)
+		}
+	}
+
+	return n, err
+}
@@ -2,13 +2,16 @@ package s3_client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -21,10 +24,15 @@ type ConfigS3 struct {
 
 	AccessKeyID     string
 	SecretAccessKey string
+
+	// UploadPartSize - размер части составной загрузки в байтах, 0 - размер по умолчанию upload manager
+	UploadPartSize    int64
+	UploadConcurrency int
 }
 
 type S3Client struct {
 	s3Service  *s3.Client
+	uploader   *manager.Uploader
 	configS3   ConfigS3
 	bucketName string
 }
@@ -57,9 +65,19 @@ func NewS3Client(ctx context.Context, cfg ConfigS3) (*S3Client, error) {
 	}
 
 	s3Client := s3.NewFromConfig(awsCfg)
+	uploader := manager.NewUploader(s3Client, func(u *manager.Uploader) {
+		if cfg.UploadPartSize > 0 {
+			u.PartSize = cfg.UploadPartSize
+		}
+		if cfg.UploadConcurrency > 0 {
+			u.Concurrency = cfg.UploadConcurrency
+		}
+	})
+
 	return &S3Client{
 		configS3:   cfg,
 		s3Service:  s3Client,
+		uploader:   uploader,
 		bucketName: cfg.Bucket,
 	}, nil
 }
@@ -77,45 +95,90 @@ func (s *S3Client) DeleteFile(ctx context.Context, fileName string) error {
 	return nil
 }
 
+// DeleteObjectsBatchSize - максимальное количество ключей в одном запросе DeleteObjects
+const DeleteObjectsBatchSize = 1000
+
+// ObjectInfo - ключ объекта и время его последнего изменения
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
 func (s *S3Client) ListObjects(ctx context.Context, prefix string) ([]string, error) {
 	op := "s3_client.S3Client.ListObjects"
-	output, err := s.s3Service.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucketName),
-		Prefix: aws.String(prefix),
-	})
+	objects, err := s.ListObjectsInfo(ctx, prefix)
 	if err != nil {
 		return nil, fmt.Errorf("%s:%w", op, err)
 	}
 
-	var keys []string
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+
+	return keys, nil
+}
+
+// ListObjectsInfo - возвращает все объекты с префиксом prefix, постранично обходя список объектов бакета
+func (s *S3Client) ListObjectsInfo(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	op := "s3_client.S3Client.ListObjectsInfo"
+	paginator := s3.NewListObjectsV2Paginator(s.s3Service, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	})
+
+	var objects []ObjectInfo
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
 
-	for _, val := range output.Contents {
-		if val.Key != nil {
-			keys = append(keys, *val.Key)
+		for _, val := range output.Contents {
+			if val.Key == nil {
+				continue
+			}
+			objects = append(objects, ObjectInfo{
+				Key:          *val.Key,
+				Size:         aws.ToInt64(val.Size),
+				LastModified: aws.ToTime(val.LastModified),
+			})
 		}
 	}
 
-	return keys, nil
+	return objects, nil
 }
 
+// DeleteObjects - удаляет объекты пачками по DeleteObjectsBatchSize ключей, так как больше s3 за один запрос не принимает
 func (s *S3Client) DeleteObjects(ctx context.Context, keys []string) error {
 	op := "s3_client.S3Client.DeleteObjects"
-	objects := make([]types.ObjectIdentifier, 0, len(keys))
-	for _, key := range keys {
-		objects = append(objects, types.ObjectIdentifier{
-			Key: aws.String(key),
-		})
-	}
+	for start := 0; start < len(keys); start += DeleteObjectsBatchSize {
+		end := min(start+DeleteObjectsBatchSize, len(keys))
 
-	_, err := s.s3Service.DeleteObjects(ctx, &s3.DeleteObjectsInput{
-		Bucket: aws.String(s.bucketName),
-		Delete: &types.Delete{Objects: objects},
-	})
-	if err != nil {
-		return fmt.Errorf("%s:%w", op, err)
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{
+				Key: aws.String(key),
+			})
+		}
+
+		output, err := s.s3Service.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucketName),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+		// Ошибки отдельных ключей s3 возвращает в теле успешного ответа
+		if len(output.Errors) > 0 {
+			e := output.Errors[0]
+			return fmt.Errorf("%s: failed to delete %d objects, first: %s: %s", op, len(output.Errors),
+				aws.ToString(e.Key), aws.ToString(e.Message))
+		}
 	}
 
-	return err
+	return nil
 }
 
 func (s *S3Client) DeleteFolder(ctx context.Context, folder string) error {
@@ -131,6 +194,27 @@ func (s *S3Client) DeleteFolder(ctx context.Context, folder string) error {
 	return nil
 }
 
+// FileExists - проверяет наличие объекта запросом HeadObject, не скачивая содержимое
+func (s *S3Client) FileExists(ctx context.Context, fileName string) (bool, error) {
+	op := "s3_client.S3Client.FileExists"
+	_, err := s.s3Service.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		// HeadObject возвращается без тела, поэтому на отсутствующий объект s3 отвечает NotFound, а не NoSuchKey
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// GetFile - возвращает содержимое объекта. Если при загрузке в метаданные объекта записан SHA-256,
+// то по окончании чтения содержимое сверяется с ним, и при расхождении вместо io.EOF возвращается ErrChecksumMismatch
 func (s *S3Client) GetFile(ctx context.Context, fileName string) (io.ReadCloser, error) {
 	op := "s3_client.S3Client.GetFile"
 	output, err := s.s3Service.GetObject(ctx, &s3.GetObjectInput{
@@ -142,36 +226,125 @@ func (s *S3Client) GetFile(ctx context.Context, fileName string) (io.ReadCloser,
 		return nil, fmt.Errorf("%s:%w", op, err)
 	}
 
-	return output.Body, nil
+	// Объекты, загруженные до появления контрольных сумм, отдаются без проверки
+	checksum, ok := output.Metadata[ChecksumMetadataKey]
+	if !ok {
+		return output.Body, nil
+	}
+
+	return newVerifyingReader(output.Body, fileName, checksum), nil
 }
 
-func (s *S3Client) SaveFile(ctx context.Context, key string, file io.Reader) error {
+// SaveFile - загружает файл, при размере больше одной части - составной загрузкой, и возвращает SHA-256 содержимого.
+// Метаданные объекта передаются в начале загрузки, поэтому контрольная сумма считается отдельным проходом по файлу
+func (s *S3Client) SaveFile(ctx context.Context, key string, file io.ReadSeeker, contentType string) (string, error) {
 	op := "s3_client.S3Client.SaveFile"
-	input := &s3.PutObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(key),
-		Body:   file,
+
+	checksum, err := Checksum(file)
+	if err != nil {
+		return "", fmt.Errorf("%s:%w", op, err)
 	}
 
-	_, err := s.s3Service.PutObject(ctx, input)
+	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(key),
+		Body:        file,
+		ContentType: aws.String(contentType),
+		Metadata:    map[string]string{ChecksumMetadataKey: checksum},
+	})
 	if err != nil {
-		return fmt.Errorf("%s:%w", op, err)
+		return "", fmt.Errorf("%s:%w", op, err)
 	}
 
-	return nil
+	return checksum, nil
 }
 
-func (s *S3Client) GetPresignURL(ctx context.Context, fileName string) (string, error) {
+// GetPresignURL - возвращает ссылку на скачивание объекта, действующую в течение expires
+func (s *S3Client) GetPresignURL(ctx context.Context, fileName string, expires time.Duration) (string, error) {
 	op := "s3_client.S3Client.GetPresignURL"
 	presigner := s3.NewPresignClient(s.s3Service)
 
 	result, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(fileName),
-	}, s3.WithPresignExpires(time.Hour))
+	}, s3.WithPresignExpires(expires))
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return result.URL, nil
 }
+
+// PresignedUpload - ссылка на загрузку объекта PUT-запросом. Headers - подписанные заголовки,
+// которые нужно передать вместе с содержимым, иначе хранилище отклонит запрос
+type PresignedUpload struct {
+	URL       string
+	Headers   map[string]string
+	ExpiresAt time.Time
+}
+
+// GetPresignUploadURL - возвращает ссылку на загрузку объекта, действующую в течение expires.
+// Подписывается только заголовок метаданных с заявленной контрольной суммой: изменить ее нельзя, но содержимое
+// хранилище с ней не сверяет. Загруженный файл нужно проверить через VerifyFile, при чтении через GetFile
+// содержимое тоже сверяется с ней
+func (s *S3Client) GetPresignUploadURL(ctx context.Context, fileName, checksum, contentType string,
+	expires time.Duration) (*PresignedUpload, error) {
+	op := "s3_client.S3Client.GetPresignUploadURL"
+	presigner := s3.NewPresignClient(s.s3Service)
+
+	expiresAt := time.Now().Add(expires)
+	result, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(fileName),
+		ContentType: aws.String(contentType),
+		Metadata:    map[string]string{ChecksumMetadataKey: checksum},
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	headers := map[string]string{"Content-Type": contentType}
+	for name := range result.SignedHeader {
+		// Host выставляет http-клиент
+		if strings.EqualFold(name, "Host") {
+			continue
+		}
+		headers[name] = result.SignedHeader.Get(name)
+	}
+
+	return &PresignedUpload{
+		URL:       result.URL,
+		Headers:   headers,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifyFile - читает объект целиком и сверяет содержимое с контрольной суммой из его метаданных.
+// Возвращает контрольную сумму, ErrObjectNotFound, если объекта нет, и ErrChecksumMismatch при расхождении
+func (s *S3Client) VerifyFile(ctx context.Context, fileName string) (string, error) {
+	op := "s3_client.S3Client.VerifyFile"
+	output, err := s.s3Service.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return "", fmt.Errorf("%s: %w: %s", op, ErrObjectNotFound, fileName)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer output.Body.Close()
+
+	checksum, ok := output.Metadata[ChecksumMetadataKey]
+	if !ok {
+		return "", fmt.Errorf("%s: %w: %s: no checksum in metadata", op, ErrChecksumMismatch, fileName)
+	}
+
+	_, err = io.Copy(io.Discard, newVerifyingReader(output.Body, fileName, checksum))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checksum, nil
+}
@@ -0,0 +1,257 @@
+package s3_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	testBucket = "models"
+	// testPartSize - минимальный размер части составной загрузки, который принимает s3
+	testPartSize = 5 * 1024 * 1024
+)
+
+type testS3 struct {
+	client *S3Client
+	// multipartUploads - количество начатых составных загрузок
+	multipartUploads atomic.Int32
+}
+
+func newTestS3(t *testing.T) *testS3 {
+	t.Helper()
+
+	ts := &testS3{}
+	faker := gofakes3.New(s3mem.New()).Server()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Query().Has("uploads") {
+			ts.multipartUploads.Add(1)
+		}
+		faker.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewS3Client(context.Background(), ConfigS3{
+		Region:            "us-east-1",
+		S3Host:            server.URL,
+		PartitionID:       "aws",
+		HostnameImmutable: true,
+		Bucket:            testBucket,
+		AccessKeyID:       "access",
+		SecretAccessKey:   "secret",
+		UploadPartSize:    testPartSize,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+
+	_, err = client.GetPureS3Client().CreateBucket(context.Background(), &s3.CreateBucketInput{
+		Bucket: aws.String(testBucket),
+	})
+	if err != nil {
+		t.Fatalf("CreateBucket() error = %v", err)
+	}
+
+	ts.client = client
+	return ts
+}
+
+func randomBytes(size int) []byte {
+	b := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(b)
+	return b
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSaveFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		wantMultipart bool
+	}{
+		{name: "single part", size: 1024, wantMultipart: false},
+		{name: "multipart", size: 2*testPartSize + 1024, wantMultipart: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestS3(t)
+			ctx := context.Background()
+			content := randomBytes(tt.size)
+			key := "face_model/user/model.ubj"
+
+			checksum, err := ts.client.SaveFile(ctx, key, bytes.NewReader(content), "application/ubjson")
+			if err != nil {
+				t.Fatalf("SaveFile() error = %v", err)
+			}
+			if checksum != sha256Hex(content) {
+				t.Errorf("checksum = %s, want %s", checksum, sha256Hex(content))
+			}
+			if got := ts.multipartUploads.Load() > 0; got != tt.wantMultipart {
+				t.Errorf("multipart = %v, want %v", got, tt.wantMultipart)
+			}
+
+			head, err := ts.client.GetPureS3Client().HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(testBucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				t.Fatalf("HeadObject() error = %v", err)
+			}
+			if got := aws.ToString(head.ContentType); got != "application/ubjson" {
+				t.Errorf("Content-Type = %q, want application/ubjson", got)
+			}
+			if got := head.Metadata[ChecksumMetadataKey]; got != checksum {
+				t.Errorf("metadata %s = %q, want %q", ChecksumMetadataKey, got, checksum)
+			}
+
+			body, err := ts.client.GetFile(ctx, key)
+			if err != nil {
+				t.Fatalf("GetFile() error = %v", err)
+			}
+			defer body.Close()
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("read error = %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("content differs from saved, got %d bytes, want %d", len(got), len(content))
+			}
+
+			verified, err := ts.client.VerifyFile(ctx, key)
+			if err != nil {
+				t.Fatalf("VerifyFile() error = %v", err)
+			}
+			if verified != checksum {
+				t.Errorf("VerifyFile() = %s, want %s", verified, checksum)
+			}
+		})
+	}
+}
+
+func TestGetFileDetectsTamperedObject(t *testing.T) {
+	ts := newTestS3(t)
+	ctx := context.Background()
+	key := "face_model/user/model.ubj"
+
+	checksum, err := ts.client.SaveFile(ctx, key, bytes.NewReader([]byte("model")), "application/ubjson")
+	if err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	// Подменяем содержимое, оставляя контрольную сумму исходного файла
+	_, err = ts.client.GetPureS3Client().PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(testBucket),
+		Key:      aws.String(key),
+		Body:     bytes.NewReader([]byte("tampered model")),
+		Metadata: map[string]string{ChecksumMetadataKey: checksum},
+	})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	body, err := ts.client.GetFile(ctx, key)
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	defer body.Close()
+
+	_, err = io.ReadAll(body)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("read error = %v, want %v", err, ErrChecksumMismatch)
+	}
+
+	_, err = ts.client.VerifyFile(ctx, key)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyFile() error = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestGetFileWithoutChecksum(t *testing.T) {
+	ts := newTestS3(t)
+	ctx := context.Background()
+	key := "face_model/user/legacy.ubj"
+
+	// Объекты, загруженные до появления контрольных сумм, читаются без проверки
+	_, err := ts.client.GetPureS3Client().PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader([]byte("legacy model")),
+	})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	body, err := ts.client.GetFile(ctx, key)
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	defer body.Close()
+
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if string(got) != "legacy model" {
+		t.Errorf("content = %q, want %q", got, "legacy model")
+	}
+
+	_, err = ts.client.VerifyFile(ctx, key)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyFile() error = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestVerifyFileNotFound(t *testing.T) {
+	ts := newTestS3(t)
+
+	_, err := ts.client.VerifyFile(context.Background(), "face_model/user/missing.ubj")
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("VerifyFile() error = %v, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestFileExists(t *testing.T) {
+	ts := newTestS3(t)
+	ctx := context.Background()
+
+	_, err := ts.client.SaveFile(ctx, "face_model/user/model.ubj", bytes.NewReader([]byte("model")), "application/ubjson")
+	if err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "face_model/user/model.ubj", want: true},
+		{key: "face_model/user/missing.ubj", want: false},
+	}
+
+	for _, tt := range tests {
+		got, err := ts.client.FileExists(ctx, tt.key)
+		if err != nil {
+			t.Fatalf("FileExists(%s) error = %v", tt.key, err)
+		}
+		if got != tt.want {
+			t.Errorf("FileExists(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
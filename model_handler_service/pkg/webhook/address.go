@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrInvalidURL        = errors.New("invalid webhook url")
+	ErrForbiddenAddress  = errors.New("webhook address is not public")
+	sharedAddressSpace   = netip.MustParsePrefix("100.64.0.0/10")
+	ipv4MappedIPv6Prefix = netip.MustParsePrefix("::ffff:0:0/96")
+)
+
+// ValidateURL - проверяет адрес вебхука при регистрации: допускаются только http и https
+// без учетных данных, а хост не должен указывать на сам сервис или внутреннюю сеть.
+// Имена хостов здесь не разрешаются, адрес, в который они разрешились, проверяет DialControl при отправке
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, err.Error())
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrInvalidURL, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials are not allowed", ErrInvalidURL)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: empty host", ErrInvalidURL)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !IsPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// IsPublicAddr - возвращает false для адресов, запрос к которым попадет в сам сервис или во внутреннюю сеть
+func IsPublicAddr(addr netip.Addr) bool {
+	if ipv4MappedIPv6Prefix.Contains(addr) {
+		addr = addr.Unmap()
+	}
+
+	return addr.IsValid() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// DialControl - проверяет адрес, к которому подключается клиент вебхуков. Имя хоста уже разрешено,
+// поэтому проверка срабатывает и для имен, которые после регистрации стали указывать во внутреннюю сеть,
+// и для перенаправлений
+func DialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Заголовки запроса вебхука
+const (
+	HeaderEventID   = "X-Webhook-Event-Id"
+	HeaderEventType = "X-Webhook-Event-Type"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix - префикс подписи в заголовке, указывающий алгоритм
+const signaturePrefix = "sha256="
+
+// Sign - подписывает тело запроса: HMAC-SHA256 от строки "<timestamp>.<body>" с секретом вебхука.
+// Время входит в подпись, чтобы получатель мог отклонять повторно отправленные перехваченные запросы
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - проверяет подпись запроса на стороне получателя. Запросы старше tolerance отклоняются
+func Verify(secret, signature, timestampHeader string, body []byte, tolerance time.Duration) bool {
+	unix, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return false
+	}
+	timestamp := time.Unix(unix, 0)
+	if tolerance > 0 && time.Since(timestamp).Abs() > tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
@@ -0,0 +1,284 @@
+package xgboost
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые функции потерь. Для binary:logistic и reg:logistic предсказанием является вероятность класса 1,
+// для binary:logitraw - значение до сигмоиды
+const (
+	ObjectiveBinaryLogistic = "binary:logistic"
+	ObjectiveRegLogistic    = "reg:logistic"
+	ObjectiveBinaryLogitRaw = "binary:logitraw"
+)
+
+// leafNode - значение left_children у листа дерева
+const leafNode = -1
+
+var ErrUnsupportedModel = errors.New("unsupported xgboost model")
+
+// Model - бинарный классификатор xgboost (gbtree), загруженный из JSON или UBJSON файла модели
+type Model struct {
+	Objective    string
+	FeatureNames []string
+	NumFeature   int
+
+	// baseMargin - base_score, переведенный в пространство значений до сигмоиды
+	baseMargin float64
+	trees      []tree
+}
+
+// tree - дерево решений в том же представлении, что и в файле модели: параметры узлов лежат в параллельных массивах
+type tree struct {
+	leftChildren    []int32
+	rightChildren   []int32
+	splitIndices    []int32
+	splitConditions []float32
+	defaultLeft     []bool
+}
+
+// rawModel - поля файла модели, которые нужны для инференса
+type rawModel struct {
+	Learner struct {
+		FeatureNames    []string `json:"feature_names"`
+		GradientBooster struct {
+			Name  string `json:"name"`
+			Model struct {
+				Trees []rawTree `json:"trees"`
+			} `json:"model"`
+		} `json:"gradient_booster"`
+		LearnerModelParam struct {
+			BaseScore  string `json:"base_score"`
+			NumClass   string `json:"num_class"`
+			NumFeature string `json:"num_feature"`
+		} `json:"learner_model_param"`
+		Objective struct {
+			Name string `json:"name"`
+		} `json:"objective"`
+	} `json:"learner"`
+}
+
+type rawTree struct {
+	LeftChildren    []int32   `json:"left_children"`
+	RightChildren   []int32   `json:"right_children"`
+	SplitIndices    []int32   `json:"split_indices"`
+	SplitConditions []float32 `json:"split_conditions"`
+	DefaultLeft     []flag    `json:"default_left"`
+	SplitType       []int32   `json:"split_type"`
+}
+
+// flag - разные версии xgboost пишут default_left как булевы значения или как 0/1
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true", "1":
+		*f = true
+	case "false", "0":
+		*f = false
+	default:
+		return fmt.Errorf("invalid flag value %s", b)
+	}
+	return nil
+}
+
+// Load - читает модель в формате JSON или UBJSON, формат определяется по содержимому.
+// Старый бинарный формат xgboost не поддерживается
+func Load(r io.Reader) (*Model, error) {
+	op := "xgboost.Load"
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var raw *rawModel
+	if isJSON(data) {
+		raw = &rawModel{}
+		err = json.Unmarshal(data, raw)
+	} else {
+		raw, err = parseUBJSONModel(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	model, err := newModel(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return model, nil
+}
+
+// isJSON - и JSON, и UBJSON модели начинаются с '{', но в UBJSON за ним идет маркер длины ключа, а не кавычка
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return false
+	}
+	next := bytes.TrimLeft(data[1:], " \t\r\n")
+	return len(next) > 0 && (next[0] == '"' || next[0] == '}')
+}
+
+func newModel(raw *rawModel) (*Model, error) {
+	learner := raw.Learner
+
+	if learner.GradientBooster.Name != "gbtree" {
+		return nil, fmt.Errorf("%w: booster %q", ErrUnsupportedModel, learner.GradientBooster.Name)
+	}
+
+	switch learner.Objective.Name {
+	case ObjectiveBinaryLogistic, ObjectiveRegLogistic, ObjectiveBinaryLogitRaw:
+	default:
+		return nil, fmt.Errorf("%w: objective %q", ErrUnsupportedModel, learner.Objective.Name)
+	}
+
+	if numClass, _ := strconv.Atoi(learner.LearnerModelParam.NumClass); numClass > 1 {
+		return nil, fmt.Errorf("%w: multiclass model with %d classes", ErrUnsupportedModel, numClass)
+	}
+
+	numFeature, err := strconv.Atoi(learner.LearnerModelParam.NumFeature)
+	if err != nil || numFeature <= 0 {
+		return nil, fmt.Errorf("invalid num_feature %q", learner.LearnerModelParam.NumFeature)
+	}
+
+	// Начиная с xgboost 3.0 base_score записывается как вектор "[5E-1]"
+	baseScore, err := strconv.ParseFloat(strings.Trim(learner.LearnerModelParam.BaseScore, "[]"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base_score %q", learner.LearnerModelParam.BaseScore)
+	}
+
+	baseMargin := baseScore
+	if learner.Objective.Name != ObjectiveBinaryLogitRaw {
+		if baseScore <= 0 || baseScore >= 1 {
+			return nil, fmt.Errorf("base_score %v is out of (0, 1)", baseScore)
+		}
+		baseMargin = math.Log(baseScore / (1 - baseScore))
+	}
+
+	if len(learner.FeatureNames) != 0 && len(learner.FeatureNames) != numFeature {
+		return nil, fmt.Errorf("feature_names count %d does not match num_feature %d", len(learner.FeatureNames), numFeature)
+	}
+
+	trees := make([]tree, 0, len(learner.GradientBooster.Model.Trees))
+	for i, rt := range learner.GradientBooster.Model.Trees {
+		t, err := newTree(rt, numFeature)
+		if err != nil {
+			return nil, fmt.Errorf("tree %d: %w", i, err)
+		}
+		trees = append(trees, t)
+	}
+
+	return &Model{
+		Objective:    learner.Objective.Name,
+		FeatureNames: learner.FeatureNames,
+		NumFeature:   numFeature,
+		baseMargin:   baseMargin,
+		trees:        trees,
+	}, nil
+}
+
+// newTree - проверяет согласованность массивов дерева, чтобы обход не мог выйти за их границы или зациклиться
+func newTree(rt rawTree, numFeature int) (tree, error) {
+	n := len(rt.LeftChildren)
+	if n == 0 {
+		return tree{}, errors.New("empty tree")
+	}
+	if len(rt.RightChildren) != n || len(rt.SplitIndices) != n ||
+		len(rt.SplitConditions) != n || len(rt.DefaultLeft) != n {
+		return tree{}, errors.New("node arrays have different lengths")
+	}
+
+	for _, splitType := range rt.SplitType {
+		if splitType != 0 {
+			return tree{}, fmt.Errorf("%w: categorical splits", ErrUnsupportedModel)
+		}
+	}
+
+	defaultLeft := make([]bool, n)
+	for node := 0; node < n; node++ {
+		defaultLeft[node] = bool(rt.DefaultLeft[node])
+
+		left, right := rt.LeftChildren[node], rt.RightChildren[node]
+		if left == leafNode {
+			continue
+		}
+		// Потомки в xgboost всегда записываются после родителя
+		if left <= int32(node) || int(left) >= n || right <= int32(node) || int(right) >= n {
+			return tree{}, fmt.Errorf("node %d has invalid children", node)
+		}
+		if rt.SplitIndices[node] < 0 || int(rt.SplitIndices[node]) >= numFeature {
+			return tree{}, fmt.Errorf("node %d splits on unknown feature %d", node, rt.SplitIndices[node])
+		}
+	}
+
+	return tree{
+		leftChildren:    rt.LeftChildren,
+		rightChildren:   rt.RightChildren,
+		splitIndices:    rt.SplitIndices,
+		splitConditions: rt.SplitConditions,
+		defaultLeft:     defaultLeft,
+	}, nil
+}
+
+// leafValue - спускается от корня до листа. Как и в xgboost, сравнение идет во float32,
+// а пропущенное значение (NaN) уходит в ветку по умолчанию
+func (t *tree) leafValue(row []float64) float32 {
+	node := int32(0)
+	for t.leftChildren[node] != leafNode {
+		value := row[t.splitIndices[node]]
+		switch {
+		case math.IsNaN(value):
+			if t.defaultLeft[node] {
+				node = t.leftChildren[node]
+			} else {
+				node = t.rightChildren[node]
+			}
+		case float32(value) < t.splitConditions[node]:
+			node = t.leftChildren[node]
+		default:
+			node = t.rightChildren[node]
+		}
+	}
+	// У листа split_conditions хранит его значение
+	return t.splitConditions[node]
+}
+
+// Margin - сумма значений листьев всех деревьев и base_score, то есть предсказание до сигмоиды
+func (m *Model) Margin(row []float64) (float64, error) {
+	if len(row) != m.NumFeature {
+		return 0, fmt.Errorf("row has %d features, model expects %d", len(row), m.NumFeature)
+	}
+
+	margin := m.baseMargin
+	for i := range m.trees {
+		margin += float64(m.trees[i].leafValue(row))
+	}
+	return margin, nil
+}
+
+// Predict - возвращает предсказания модели для пакета строк признаков.
+// Для логистических функций потерь это вероятность класса 1
+func (m *Model) Predict(rows [][]float64) ([]float64, error) {
+	res := make([]float64, 0, len(rows))
+	for i, row := range rows {
+		margin, err := m.Margin(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+
+		if m.Objective != ObjectiveBinaryLogitRaw {
+			margin = 1 / (1 + math.Exp(-margin))
+		}
+		res = append(res, margin)
+	}
+	return res, nil
+}
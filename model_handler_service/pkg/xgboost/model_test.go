@@ -0,0 +1,144 @@
+package xgboost
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata/model.json - модель из двух деревьев глубины 1 в формате xgboost 2.0:
+// первое дерево делит по eye < 0.5 (листья 0.4 и -0.3, пропуск уходит влево),
+// второе - по mouth < 1.5 (листья 0.2 и -0.1, пропуск уходит вправо), base_score = 0.5.
+// testdata/model.ubj - та же модель в UBJSON с типизированными массивами, как ее сохраняет xgboost
+var fixtureRows = []struct {
+	name string
+	row  []float64
+	// want - сигмоида суммы листьев, посчитанная вручную
+	want float64
+}{
+	{name: "both left", row: []float64{0.1, 1.0}, want: 0.6456563062257954}, // 0.4 + 0.2
+	{name: "both right", row: []float64{0.9, 2.0}, want: 0.401312339887548}, // -0.3 - 0.1
+	{name: "split value goes right", row: []float64{0.5, 1.5}, want: 0.401312339887548},
+	{name: "missing values", row: []float64{math.NaN(), math.NaN()}, want: 0.574442516811659}, // 0.4 - 0.1
+	// как и в xgboost, признак сравнивается во float32: 0.49999999 округляется до 0.5 и уходит вправо
+	{name: "float32 comparison", row: []float64{0.49999999, 1.0}, want: 0.47502081252106}, // -0.3 + 0.2
+}
+
+func loadFixture(t *testing.T, name string) *Model {
+	t.Helper()
+
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer file.Close()
+
+	model, err := Load(file)
+	if err != nil {
+		t.Fatalf("Load(%s) error = %v", name, err)
+	}
+	return model
+}
+
+func TestPredictMatchesHandComputedProbabilities(t *testing.T) {
+	for _, fixture := range []string{"model.json", "model.ubj"} {
+		model := loadFixture(t, fixture)
+
+		if model.NumFeature != 2 || strings.Join(model.FeatureNames, ",") != "eye,mouth" {
+			t.Fatalf("%s: features = %d %v, want 2 [eye mouth]", fixture, model.NumFeature, model.FeatureNames)
+		}
+
+		for _, tt := range fixtureRows {
+			t.Run(fixture+"/"+tt.name, func(t *testing.T) {
+				got, err := model.Predict([][]float64{tt.row})
+				if err != nil {
+					t.Fatalf("Predict() error = %v", err)
+				}
+				if math.Abs(got[0]-tt.want) > 1e-6 {
+					t.Errorf("Predict() = %v, want %v", got[0], tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestJSONAndUBJSONModelsAreEqual(t *testing.T) {
+	jsonModel := loadFixture(t, "model.json")
+	ubjModel := loadFixture(t, "model.ubj")
+
+	rows := make([][]float64, 0, len(fixtureRows))
+	for _, tt := range fixtureRows {
+		rows = append(rows, tt.row)
+	}
+
+	jsonPredictions, err := jsonModel.Predict(rows)
+	if err != nil {
+		t.Fatalf("json Predict() error = %v", err)
+	}
+	ubjPredictions, err := ubjModel.Predict(rows)
+	if err != nil {
+		t.Fatalf("ubj Predict() error = %v", err)
+	}
+
+	for i := range rows {
+		if jsonPredictions[i] != ubjPredictions[i] {
+			t.Errorf("row %d: json = %v, ubj = %v", i, jsonPredictions[i], ubjPredictions[i])
+		}
+	}
+}
+
+func TestLoadVectorBaseScore(t *testing.T) {
+	data, err := os.ReadFile("testdata/model.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	// начиная с xgboost 3.0 base_score записывается вектором
+	model, err := Load(strings.NewReader(strings.Replace(string(data), `"5E-1"`, `"[5E-1]"`, 1)))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got, err := model.Predict([][]float64{fixtureRows[0].row})
+	if err != nil {
+		t.Fatalf("Predict() error = %v", err)
+	}
+	if math.Abs(got[0]-fixtureRows[0].want) > 1e-6 {
+		t.Errorf("Predict() = %v, want %v", got[0], fixtureRows[0].want)
+	}
+}
+
+func TestLoadRejectsUnsupportedModels(t *testing.T) {
+	data, err := os.ReadFile("testdata/model.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{name: "objective", old: `"binary:logistic"`, new: `"multi:softprob"`},
+		{name: "booster", old: `"gbtree"`, new: `"gblinear"`},
+		{name: "categorical split", old: `"split_type": [0, 0, 0]`, new: `"split_type": [1, 0, 0]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(strings.Replace(string(data), tt.old, tt.new, 1)))
+			if !errors.Is(err, ErrUnsupportedModel) {
+				t.Errorf("Load() error = %v, want ErrUnsupportedModel", err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsLegacyBinaryFormat(t *testing.T) {
+	// старый бинарный формат начинается с параметров learner, а не с объекта
+	_, err := Load(strings.NewReader("binf\x00\x00\x00\x3f"))
+	if err == nil {
+		t.Error("Load() error = nil, want error")
+	}
+}
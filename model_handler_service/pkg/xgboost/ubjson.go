@@ -0,0 +1,297 @@
+package xgboost
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxUBJSONDepth - ограничение вложенности, чтобы поврежденный файл не приводил к переполнению стека
+const maxUBJSONDepth = 64
+
+var errUnexpectedEnd = errors.New("unexpected end of ubjson data")
+
+// ubjsonDecoder - декодер формата UBJSON (https://ubjson.org), в котором xgboost сохраняет модели с расширением .ubj.
+// Файлы с другими расширениями, кроме .json, xgboost до версии 2.1 сохраняет в старом бинарном формате,
+// который не поддерживается. Объекты декодируются в map[string]any, типизированные массивы чисел -
+// в []int64 и []float64, остальные массивы - в []any
+type ubjsonDecoder struct {
+	data []byte
+	pos  int
+}
+
+func decodeUBJSON(data []byte) (any, error) {
+	d := &ubjsonDecoder{data: data}
+
+	marker, err := d.readMarker()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.readValue(marker, 0)
+}
+
+func (d *ubjsonDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errUnexpectedEnd
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *ubjsonDecoder) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readMarker - читает маркер типа, пропуская no-op маркеры
+func (d *ubjsonDecoder) readMarker() (byte, error) {
+	for {
+		marker, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if marker != 'N' {
+			return marker, nil
+		}
+	}
+}
+
+// readInt - читает целое число, тип которого задан маркером
+func (d *ubjsonDecoder) readInt(marker byte) (int64, error) {
+	switch marker {
+	case 'i':
+		b, err := d.readBytes(1)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int8(b[0])), nil
+	case 'U':
+		b, err := d.readBytes(1)
+		if err != nil {
+			return 0, err
+		}
+		return int64(b[0]), nil
+	case 'I':
+		b, err := d.readBytes(2)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case 'l':
+		b, err := d.readBytes(4)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case 'L':
+		b, err := d.readBytes(8)
+		if err != nil {
+			return 0, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	default:
+		return 0, fmt.Errorf("unexpected ubjson integer marker %q at %d", marker, d.pos-1)
+	}
+}
+
+func (d *ubjsonDecoder) readFloat(marker byte) (float64, error) {
+	switch marker {
+	case 'd':
+		b, err := d.readBytes(4)
+		if err != nil {
+			return 0, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 'D':
+		b, err := d.readBytes(8)
+		if err != nil {
+			return 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return 0, fmt.Errorf("unexpected ubjson float marker %q at %d", marker, d.pos-1)
+	}
+}
+
+// readLength - читает длину строки или контейнера, которая в UBJSON всегда записывается целым числом с маркером
+func (d *ubjsonDecoder) readLength() (int, error) {
+	marker, err := d.readMarker()
+	if err != nil {
+		return 0, err
+	}
+	n, err := d.readInt(marker)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > int64(len(d.data)) {
+		return 0, fmt.Errorf("invalid ubjson length %d", n)
+	}
+	return int(n), nil
+}
+
+func (d *ubjsonDecoder) readString() (string, error) {
+	n, err := d.readLength()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.readBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *ubjsonDecoder) readValue(marker byte, depth int) (any, error) {
+	if depth > maxUBJSONDepth {
+		return nil, errors.New("ubjson nesting is too deep")
+	}
+
+	switch marker {
+	case 'Z':
+		return nil, nil
+	case 'T':
+		return true, nil
+	case 'F':
+		return false, nil
+	case 'i', 'U', 'I', 'l', 'L':
+		return d.readInt(marker)
+	case 'd', 'D':
+		return d.readFloat(marker)
+	case 'C':
+		b, err := d.readBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 'S', 'H':
+		return d.readString()
+	case '[':
+		return d.readArray(depth)
+	case '{':
+		return d.readObject(depth)
+	default:
+		return nil, fmt.Errorf("unexpected ubjson marker %q at %d", marker, d.pos-1)
+	}
+}
+
+// readContainerHeader - читает необязательные тип элементов ($) и их количество (#) оптимизированного контейнера.
+// Возвращает count = -1, если количество не задано и контейнер завершается закрывающим маркером
+func (d *ubjsonDecoder) readContainerHeader() (elemType byte, count int, err error) {
+	count = -1
+	if d.pos < len(d.data) && d.data[d.pos] == '$' {
+		d.pos++
+		elemType, err = d.readByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		if d.pos >= len(d.data) || d.data[d.pos] != '#' {
+			return 0, 0, errors.New("ubjson typed container without count")
+		}
+	}
+	if d.pos < len(d.data) && d.data[d.pos] == '#' {
+		d.pos++
+		count, err = d.readLength()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return elemType, count, nil
+}
+
+func (d *ubjsonDecoder) readArray(depth int) (any, error) {
+	elemType, count, err := d.readContainerHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	// Типизированные числовые массивы - это основная часть модели (параметры узлов деревьев),
+	// поэтому декодируем их сразу в срезы чисел без упаковки каждого значения в any
+	switch elemType {
+	case 'i', 'U', 'I', 'l', 'L':
+		res := make([]int64, count)
+		for i := range res {
+			res[i], err = d.readInt(elemType)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case 'd', 'D':
+		res := make([]float64, count)
+		for i := range res {
+			res[i], err = d.readFloat(elemType)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	res := make([]any, 0)
+	for i := 0; count < 0 || i < count; i++ {
+		marker := elemType
+		if marker == 0 {
+			marker, err = d.readMarker()
+			if err != nil {
+				return nil, err
+			}
+			if count < 0 && marker == ']' {
+				break
+			}
+		}
+
+		value, err := d.readValue(marker, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, value)
+	}
+
+	return res, nil
+}
+
+func (d *ubjsonDecoder) readObject(depth int) (any, error) {
+	elemType, count, err := d.readContainerHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]any)
+	for i := 0; count < 0 || i < count; i++ {
+		if count < 0 {
+			if d.pos < len(d.data) && d.data[d.pos] == '}' {
+				d.pos++
+				break
+			}
+		}
+
+		// Ключи объекта записываются как строки без маркера S
+		key, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		marker := elemType
+		if marker == 0 {
+			marker, err = d.readMarker()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		value, err := d.readValue(marker, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = value
+	}
+
+	return res, nil
+}
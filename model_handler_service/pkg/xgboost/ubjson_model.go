@@ -0,0 +1,157 @@
+package xgboost
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// parseUBJSONModel - переносит нужные для инференса поля из декодированного UBJSON в rawModel
+func parseUBJSONModel(data []byte) (*rawModel, error) {
+	value, err := decodeUBJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("model root is not an object")
+	}
+
+	learner := object(root, "learner")
+	booster := object(learner, "gradient_booster")
+	params := object(learner, "learner_model_param")
+
+	raw := &rawModel{}
+	raw.Learner.GradientBooster.Name = str(booster, "name")
+	raw.Learner.Objective.Name = str(object(learner, "objective"), "name")
+	raw.Learner.LearnerModelParam.BaseScore = str(params, "base_score")
+	raw.Learner.LearnerModelParam.NumClass = str(params, "num_class")
+	raw.Learner.LearnerModelParam.NumFeature = str(params, "num_feature")
+
+	if names, ok := learner["feature_names"].([]any); ok {
+		for _, name := range names {
+			s, ok := name.(string)
+			if !ok {
+				return nil, errors.New("feature_names contains non-string value")
+			}
+			raw.Learner.FeatureNames = append(raw.Learner.FeatureNames, s)
+		}
+	}
+
+	trees, _ := object(booster, "model")["trees"].([]any)
+	raw.Learner.GradientBooster.Model.Trees = make([]rawTree, 0, len(trees))
+	for i, value := range trees {
+		t, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("tree %d is not an object", i)
+		}
+
+		var rt rawTree
+		if rt.LeftChildren, err = int32s(t["left_children"]); err != nil {
+			return nil, fmt.Errorf("tree %d left_children: %w", i, err)
+		}
+		if rt.RightChildren, err = int32s(t["right_children"]); err != nil {
+			return nil, fmt.Errorf("tree %d right_children: %w", i, err)
+		}
+		if rt.SplitIndices, err = int32s(t["split_indices"]); err != nil {
+			return nil, fmt.Errorf("tree %d split_indices: %w", i, err)
+		}
+		if rt.SplitType, err = int32s(t["split_type"]); err != nil {
+			return nil, fmt.Errorf("tree %d split_type: %w", i, err)
+		}
+		if rt.SplitConditions, err = float32s(t["split_conditions"]); err != nil {
+			return nil, fmt.Errorf("tree %d split_conditions: %w", i, err)
+		}
+		defaultLeft, err := int32s(t["default_left"])
+		if err != nil {
+			return nil, fmt.Errorf("tree %d default_left: %w", i, err)
+		}
+		rt.DefaultLeft = make([]flag, len(defaultLeft))
+		for j, v := range defaultLeft {
+			rt.DefaultLeft[j] = v != 0
+		}
+
+		raw.Learner.GradientBooster.Model.Trees = append(raw.Learner.GradientBooster.Model.Trees, rt)
+	}
+
+	return raw, nil
+}
+
+// object - возвращает вложенный объект или пустой объект, если ключа нет
+func object(m map[string]any, key string) map[string]any {
+	res, _ := m[key].(map[string]any)
+	return res
+}
+
+// str - параметры learner_model_param в xgboost хранятся строками
+func str(m map[string]any, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func int32s(value any) ([]int32, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []int64:
+		res := make([]int32, len(v))
+		for i, n := range v {
+			res[i] = int32(n)
+		}
+		return res, nil
+	case []any:
+		res := make([]int32, len(v))
+		for i, item := range v {
+			switch n := item.(type) {
+			case int64:
+				res[i] = int32(n)
+			case bool:
+				if n {
+					res[i] = 1
+				}
+			default:
+				return nil, fmt.Errorf("unexpected value %v", item)
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected array type %T", value)
+	}
+}
+
+func float32s(value any) ([]float32, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []float64:
+		res := make([]float32, len(v))
+		for i, n := range v {
+			res[i] = float32(n)
+		}
+		return res, nil
+	case []any:
+		res := make([]float32, len(v))
+		for i, item := range v {
+			switch n := item.(type) {
+			case float64:
+				res[i] = float32(n)
+			case int64:
+				res[i] = float32(n)
+			default:
+				return nil, fmt.Errorf("unexpected value %v", item)
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected array type %T", value)
+	}
+}
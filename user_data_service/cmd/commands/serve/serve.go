@@ -29,6 +29,10 @@ func Action(_ *cli.Context) error {
 		cfg.BaseURL,
 		cfg.FeaturesHandler,
 		cfg.StorageHandler,
+		cfg.SessionsHandler,
+		cfg.ModelTypesHandler,
+		cfg.FatigueEventsHandler,
+		cfg.ServiceToken,
 		dbClient,
 		validate,
 		l)
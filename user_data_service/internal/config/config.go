@@ -50,8 +50,16 @@ type Config struct {
 	LoggerConfig
 	SwaggerConfig
 	URLGeneratorConfig
-	StorageHandler  string `env:"STORAGE_HANDLER_URL" env-default:"http://0.0.0.0:3391/api/v1/get_models"`
-	FeaturesHandler string `env:"FEATURES_HANDLER_URL" env-default:"http://0.0.0.0:3392/api/v1/face_model/save_features"`
+	StorageHandler string `env:"STORAGE_HANDLER_URL" env-default:"http://0.0.0.0:3391/api/v1/get_models"`
+	// FeaturesHandler - адрес сохранения признаков, {model_type} заменяется на тип модели из запроса
+	FeaturesHandler string `env:"FEATURES_HANDLER_URL" env-default:"http://0.0.0.0:3392/api/v1/{model_type}/save_features"`
+	SessionsHandler string `env:"SESSIONS_HANDLER_URL" env-default:"http://0.0.0.0:3392/api/v1/face_model/sessions"`
+	// ModelTypesHandler - справочник типов моделей и их схем признаков, по нему выдаются ссылки на загрузку признаков
+	ModelTypesHandler string `env:"MODEL_TYPES_HANDLER_URL" env-default:"http://0.0.0.0:3391/api/v1/model_types"`
+	// FatigueEventsHandler - адрес сохранения событий усталости, принимает запросы только с токеном внутренних сервисов
+	FatigueEventsHandler string `env:"FATIGUE_EVENTS_HANDLER_URL" env-default:"http://0.0.0.0:3392/api/v1/fatigue_events"`
+	// ServiceToken - общий токен внутренних сервисов, передается в заголовке X-Service-Token
+	ServiceToken string `env:"SERVICE_TOKEN" env-required:"true"`
 }
 
 var instance *Config
@@ -13,6 +13,14 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"io"
 	"net/http"
+	"time"
+)
+
+const (
+	// faceModelType - тип модели, ссылка на загрузку признаков которого выдается при недоступности справочника типов
+	faceModelType = "face_model"
+	// modelTypesRequestTimeout - время ожидания справочника типов моделей, чтобы его недоступность не задерживала вход
+	modelTypesRequestTimeout = 3 * time.Second
 )
 
 // Register godoc
@@ -27,8 +35,6 @@ import (
 func (c *CoreHandler) Register(w http.ResponseWriter, r *http.Request) error {
 	// объявляем текущую операцию для оборачивания ошибки
 	op := "handlers.CoreHandler.Register"
-	// берем логгер из контекста
-	l := logger.EntryWithRequestIDFromContext(r.Context())
 
 	// десереализуем данные из тела запроса
 	var req fixtures.RegisterRequest
@@ -55,7 +61,7 @@ func (c *CoreHandler) Register(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Возвращаем пустой ответ со статусом 204
-	api.WriteSuccess(r.Context(), w, struct{}{}, http.StatusNoContent, l)
+	w.WriteHeader(http.StatusNoContent)
 
 	return nil
 }
@@ -112,8 +118,16 @@ func (c *CoreHandler) Login(w http.ResponseWriter, r *http.Request) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	// запрашиваем справочник типов моделей, чтобы выдать ссылки на загрузку признаков для каждого типа.
+	// Без справочника вход не прерывается: ссылки выдаются для типа face_model, схема которого есть всегда
+	modelTypes, err := c.getModelTypes()
+	if err != nil {
+		l.Warn(fmt.Sprintf("%s: fall back to %s: %v", op, faceModelType, err))
+		modelTypes = []string{faceModelType}
+	}
+
 	// формируем ответ на запрос
-	res, err := fixtures.NewLoginResponse(user.UserID, c.BaseURL, tokenString, models)
+	res, err := fixtures.NewLoginResponse(user.UserID, c.BaseURL, tokenString, modelTypes, models)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -124,7 +138,7 @@ func (c *CoreHandler) Login(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (c *CoreHandler) getModels(userID string) (map[string]interface{}, error) {
+func (c *CoreHandler) getModels(userID string) (map[string]fixtures.ModelURL, error) {
 	// объявляем текущую операцию для оборачивания ошибки
 	op := "handlers.CoreHandler.getModels"
 	requestBody := map[string]string{
@@ -158,22 +172,54 @@ func (c *CoreHandler) getModels(userID string) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// десериализуем JSON ответ в map[string]string
-	var result map[string]interface{}
+	// десериализуем JSON ответ
+	var result struct {
+		Content map[string]fixtures.ModelURL `json:"content"`
+	}
 	err = json.Unmarshal(responseData, &result)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// возвращаем в ответ ссылки на модели, если они есть
-	models, ok := result["content"].(map[string]interface{})
-	if ok {
-		_, ok = models["face_model"].(string)
-		if ok {
-			return models, nil
-		}
+	// возвращаем в ответ ссылки на модели всех типов, если они есть
+	if result.Content != nil {
+		return result.Content, nil
 	}
 
 	// возвращаем пустую структуру
-	return result, nil
+	return map[string]fixtures.ModelURL{}, nil
+}
+
+func (c *CoreHandler) getModelTypes() ([]string, error) {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.getModelTypes"
+
+	// отправляем запрос в справочник типов моделей, типы попадают в него только вместе со схемой признаков
+	client := &http.Client{Timeout: modelTypesRequestTimeout}
+	resp, err := client.Get(c.ModelTypesURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: error from model_handler service", op)
+	}
+
+	var result struct {
+		Content []struct {
+			ModelType string `json:"model_type"`
+		} `json:"content"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	modelTypes := make([]string, 0, len(result.Content))
+	for _, modelType := range result.Content {
+		modelTypes = append(modelTypes, modelType.ModelType)
+	}
+
+	return modelTypes, nil
 }
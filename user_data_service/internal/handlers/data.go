@@ -4,22 +4,28 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/garet2gis/fatigue-detection-system/user_data_service/internal/app_errors"
-	"github.com/garet2gis/fatigue-detection-system/user_data_service/pkg/api"
 	"github.com/garet2gis/fatigue-detection-system/user_data_service/pkg/logger"
+	"github.com/go-chi/chi/v5"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
+// ModelTypePlaceholder - подстрока в адресе сохранения признаков, заменяемая на тип модели
+const ModelTypePlaceholder = "{model_type}"
+
 // SaveVideoFeatures godoc
 //
-//	@Summary	Принимает csv файл с фичами из видео
+//	@Summary	Принимает csv файл с фичами для модели указанного типа
 //	@ID			save csv
 //	@Tags		Save CSV
-//	@Param		file	formData	file	true	"Загружаемый csv"
+//	@Param		model_type	path		string	true	"Тип модели"
+//	@Param		file		formData	file	true	"Загружаемый csv"
 //	@Success	204
 //	@Failure	400	{object}	app_errors.AppError
-//	@Router		/face_model/save_features [post]
+//	@Router		/{model_type}/save_features [post]
 func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request) error {
 	op := "handlers.CoreHandler.SaveVideoFeatures"
 
@@ -47,12 +53,12 @@ func (c *CoreHandler) SaveVideoFeatures(w http.ResponseWriter, r *http.Request)
 		}
 	}(file)
 
-	err = c.sendFeatures(file, header.Filename, userID, "face_model")
+	err = c.sendFeatures(file, header.Filename, userID, chi.URLParam(r, "model_type"))
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	api.WriteSuccess(r.Context(), w, struct{}{}, http.StatusNoContent, l)
+	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
@@ -87,7 +93,10 @@ func (c *CoreHandler) sendFeatures(file multipart.File, fileName, userID, modelT
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	req, err := http.NewRequest("POST", c.FeaturesURL, &requestBody)
+	// Адрес сохранения признаков зависит от типа модели
+	featuresURL := strings.ReplaceAll(c.FeaturesURL, ModelTypePlaceholder, url.PathEscape(modelType))
+
+	req, err := http.NewRequest("POST", featuresURL, &requestBody)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
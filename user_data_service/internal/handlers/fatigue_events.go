@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/user_data_service/internal/app_errors"
+	"net/http"
+)
+
+// serviceTokenHeader - заголовок, в котором сервис хранения лицевых признаков ожидает токен внутренних сервисов
+const serviceTokenHeader = "X-Service-Token"
+
+type saveFatigueEventsRequest struct {
+	Events []map[string]interface{} `json:"events"`
+}
+
+// SaveFatigueEvents godoc
+//
+//	@Summary		Сохраняет события усталости, зафиксированные клиентом
+//	@Description	Пользователь событий берется из токена доступа, user_id из тела запроса игнорируется.
+//	@ID				save fatigue events
+//	@Tags			Fatigue events
+//	@Param			access_token	query	string					true	"Токен доступа"
+//	@Param			events_data		body	map[string]interface{}	true	"Пакет событий"
+//	@Success		201
+//	@Failure		400	{object}	app_errors.AppError
+//	@Failure		401	{object}	app_errors.AppError
+//	@Router			/fatigue_events [post]
+func (c *CoreHandler) SaveFatigueEvents(w http.ResponseWriter, r *http.Request) error {
+	// объявляем текущую операцию для оборачивания ошибки
+	op := "handlers.CoreHandler.SaveFatigueEvents"
+
+	userID, err := c.userIDFromAccessToken(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// десереализуем данные из тела запроса
+	var req saveFatigueEventsRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return app_errors.ErrParseError.WrapError(op, err.Error())
+	}
+
+	// пользователь может сохранять только свои события
+	for _, event := range req.Events {
+		if event == nil {
+			return app_errors.ErrValidationError.WrapError(op, "empty event")
+		}
+		event["user_id"] = userID
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// сервис хранения принимает события только от внутренних сервисов
+	header := http.Header{}
+	header.Set(serviceTokenHeader, c.serviceToken)
+
+	err = c.forwardRequest(w, r, http.MethodPost, c.FatigueEventsURL, bytes.NewBuffer(jsonData), header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -17,33 +17,74 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-type UploadFeaturesURLs struct {
+type SessionsURLs struct {
 	FaceModel string `json:"face_model"`
 }
 
 type LoginResponse struct {
 	UserID string `json:"user_id"`
 
-	UploadFeaturesURLs UploadFeaturesURLs     `json:"upload_features"`
-	Models             map[string]interface{} `json:"model_urls"`
+	// UploadFeaturesURLs - ссылки на загрузку признаков по типам моделей
+	UploadFeaturesURLs map[string]string `json:"upload_features"`
+	SessionsURLs       SessionsURLs      `json:"sessions"`
+	// ModelURLs - ссылки только на персональные модели пользователя, по ним клиент определяет,
+	// нужно ли еще собирать признаки для первого обучения
+	ModelURLs map[string]string `json:"model_urls"`
+	// Models - ссылки на модели по типам, включая глобальные модели для типов, по которым у пользователя еще нет своей
+	Models map[string]ModelURL `json:"models"`
+	// FatigueEventsURL - ссылка на отправку событий усталости, зафиксированных клиентом
+	FatigueEventsURL string `json:"fatigue_events"`
+}
+
+// ModelURL - ссылка на скачивание модели, IsPersonalized равен false для глобальной модели
+type ModelURL struct {
+	URL string `json:"url"`
+	// SHA256 - контрольная сумма файла модели в hex для проверки после скачивания
+	SHA256         *string `json:"sha256"`
+	IsPersonalized bool    `json:"is_personalized"`
 }
 
 type ModelURLs struct {
 	FaceModelURL string
 }
 
-func NewLoginResponse(userID, baseURL, tokenString string, models map[string]interface{}) (*LoginResponse, error) {
+func NewLoginResponse(userID, baseURL, tokenString string, modelTypes []string, models map[string]ModelURL) (*LoginResponse, error) {
 	op := "fixtures.NewLoginResponse"
-	joinedPathFaceModel, err := url.JoinPath(baseURL, "face_model/save_features")
+
+	uploadFeaturesURLs := make(map[string]string, len(modelTypes))
+	for _, modelType := range modelTypes {
+		joinedPath, err := url.JoinPath(baseURL, modelType, "save_features")
+		if err != nil {
+			return nil, app_errors.ErrInternalServerError.WrapError(op, err.Error())
+		}
+		uploadFeaturesURLs[modelType] = joinedPath + "?access_token=" + tokenString
+	}
+
+	joinedPathFaceModelSessions, err := url.JoinPath(baseURL, "face_model/sessions")
 	if err != nil {
 		return nil, app_errors.ErrInternalServerError.WrapError(op, err.Error())
 	}
 
+	joinedPathFatigueEvents, err := url.JoinPath(baseURL, "fatigue_events")
+	if err != nil {
+		return nil, app_errors.ErrInternalServerError.WrapError(op, err.Error())
+	}
+
+	modelURLs := make(map[string]string, len(models))
+	for modelType, model := range models {
+		if model.IsPersonalized {
+			modelURLs[modelType] = model.URL
+		}
+	}
+
 	return &LoginResponse{
-		UserID: userID,
-		UploadFeaturesURLs: UploadFeaturesURLs{
-			FaceModel: joinedPathFaceModel + "?access_token=" + tokenString,
+		UserID:             userID,
+		UploadFeaturesURLs: uploadFeaturesURLs,
+		SessionsURLs: SessionsURLs{
+			FaceModel: joinedPathFaceModelSessions + "?access_token=" + tokenString,
 		},
-		Models: models,
+		ModelURLs:        modelURLs,
+		Models:           models,
+		FatigueEventsURL: joinedPathFatigueEvents + "?access_token=" + tokenString,
 	}, nil
 }
@@ -33,10 +33,15 @@ type CoreHandler struct {
 	transactor     postgresql.Transactor
 	validator      *validator.Validate
 
-	BaseURL     string
-	StorageURL  string
-	FeaturesURL string
-	logger      *zap.Logger
+	BaseURL       string
+	StorageURL    string
+	FeaturesURL   string
+	SessionsURL   string
+	ModelTypesURL string
+	// FatigueEventsURL - адрес сохранения событий усталости в сервисе хранения лицевых признаков
+	FatigueEventsURL string
+	serviceToken     string
+	logger           *zap.Logger
 }
 
 func NewCoreHandler(
@@ -45,19 +50,27 @@ func NewCoreHandler(
 	BaseURL string,
 	FeaturesURL string,
 	StorageURL string,
+	SessionsURL string,
+	ModelTypesURL string,
+	FatigueEventsURL string,
+	serviceToken string,
 	transactor postgresql.Transactor,
 	validator *validator.Validate,
 	logger *zap.Logger,
 ) *CoreHandler {
 	return &CoreHandler{
-		authRepository: authRepository,
-		tokenGenerator: tokenGenerator,
-		transactor:     transactor,
-		validator:      validator,
-		BaseURL:        BaseURL,
-		StorageURL:     StorageURL,
-		FeaturesURL:    FeaturesURL,
-		logger:         logger,
+		authRepository:   authRepository,
+		tokenGenerator:   tokenGenerator,
+		transactor:       transactor,
+		validator:        validator,
+		BaseURL:          BaseURL,
+		StorageURL:       StorageURL,
+		FeaturesURL:      FeaturesURL,
+		SessionsURL:      SessionsURL,
+		ModelTypesURL:    ModelTypesURL,
+		FatigueEventsURL: FatigueEventsURL,
+		serviceToken:     serviceToken,
+		logger:           logger,
 	}
 }
 
@@ -109,10 +122,17 @@ func (c *CoreHandler) Router() chi.Router {
 	router.Use(logger.WithLogger(c.logger))
 
 	router.Route("/api/v1", func(router chi.Router) {
-		router.Route("/face_model", func(router chi.Router) {
-			router.Post("/save_features", ErrorMiddleware(c.SaveVideoFeatures))
+		router.Post("/{model_type}/save_features", ErrorMiddleware(c.SaveVideoFeatures))
+
+		// Сессии записи видео есть только у модели по лицевым признакам
+		router.Route("/face_model/sessions", func(router chi.Router) {
+			router.Post("/", ErrorMiddleware(c.OpenVideoSession))
+			router.Get("/", ErrorMiddleware(c.GetVideoSessions))
+			router.Post("/{video_id}/close", ErrorMiddleware(c.CloseVideoSession))
 		})
 
+		router.Post("/fatigue_events", ErrorMiddleware(c.SaveFatigueEvents))
+
 		router.Route("/auth", func(router chi.Router) {
 			router.Post("/register", ErrorMiddleware(c.Register))
 			router.Post("/login", ErrorMiddleware(c.Login))
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/fatigue-detection-system/user_data_service/internal/app_errors"
+	"github.com/garet2gis/fatigue-detection-system/user_data_service/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// OpenVideoSession godoc
+//
+//	@Summary	Открывает сессию записи видео в сервисе хранения лицевых признаков
+//	@ID			open video session
+//	@Tags		Sessions
+//	@Param		access_token	query	string					true	"Токен доступа"
+//	@Param		session_data	body	map[string]interface{}	true	"Данные сессии"
+//	@Success	201
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/face_model/sessions [post]
+func (c *CoreHandler) OpenVideoSession(w http.ResponseWriter, r *http.Request) error {
+	op := "handlers.CoreHandler.OpenVideoSession"
+
+	userID, err := c.userIDFromAccessToken(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.proxySessionsRequest(w, r, http.MethodPost, c.SessionsURL, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CloseVideoSession godoc
+//
+//	@Summary	Закрывает сессию записи видео в сервисе хранения лицевых признаков
+//	@ID			close video session
+//	@Tags		Sessions
+//	@Param		access_token	query	string					true	"Токен доступа"
+//	@Param		video_id		path	string					true	"ID видео"
+//	@Param		session_data	body	map[string]interface{}	true	"Данные закрытия сессии"
+//	@Success	200
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/face_model/sessions/{video_id}/close [post]
+func (c *CoreHandler) CloseVideoSession(w http.ResponseWriter, r *http.Request) error {
+	op := "handlers.CoreHandler.CloseVideoSession"
+
+	userID, err := c.userIDFromAccessToken(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	closeURL, err := url.JoinPath(c.SessionsURL, chi.URLParam(r, "video_id"), "close")
+	if err != nil {
+		return app_errors.ErrInternalServerError.WrapError(op, err.Error())
+	}
+
+	err = c.proxySessionsRequest(w, r, http.MethodPost, closeURL, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetVideoSessions godoc
+//
+//	@Summary	Возвращает сессии записи видео пользователя
+//	@ID			get video sessions
+//	@Tags		Sessions
+//	@Param		access_token	query	string	true	"Токен доступа"
+//	@Param		status			query	string	false	"Статус сессии"	Enums(open, closed)
+//	@Success	200
+//	@Failure	400	{object}	app_errors.AppError
+//	@Router		/face_model/sessions [get]
+func (c *CoreHandler) GetVideoSessions(w http.ResponseWriter, r *http.Request) error {
+	op := "handlers.CoreHandler.GetVideoSessions"
+
+	userID, err := c.userIDFromAccessToken(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := url.Values{}
+	query.Set("user_id", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query.Set("status", status)
+	}
+
+	err = c.proxySessionsRequest(w, r, http.MethodGet, c.SessionsURL+"?"+query.Encode(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CoreHandler) userIDFromAccessToken(r *http.Request) (string, error) {
+	op := "handlers.CoreHandler.userIDFromAccessToken"
+
+	jwt := r.URL.Query().Get("access_token")
+	if jwt == "" {
+		return "", app_errors.ErrUnauthorized.WrapError(op, "empty access_token")
+	}
+
+	userID, err := c.tokenGenerator.GetUserIDFromToken(r.Context(), jwt)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// proxySessionsRequest - пересылает запрос в сервис хранения лицевых признаков, подставляя
+// в тело запроса id пользователя из токена, и возвращает клиенту ответ сервиса без изменений
+func (c *CoreHandler) proxySessionsRequest(w http.ResponseWriter, r *http.Request, method, targetURL, userID string) error {
+	op := "handlers.CoreHandler.proxySessionsRequest"
+
+	var body io.Reader
+	if method != http.MethodGet {
+		requestBody := make(map[string]interface{})
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		if err != nil && err != io.EOF {
+			return app_errors.ErrParseError.WrapError(op, err.Error())
+		}
+		// пользователь может работать только со своими сессиями
+		requestBody["user_id"] = userID
+
+		jsonData, err := json.Marshal(requestBody)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	err := c.forwardRequest(w, r, method, targetURL, body, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// forwardRequest - отправляет запрос в сервис хранения лицевых признаков с дополнительными заголовками header
+// и копирует ответ сервиса клиенту
+func (c *CoreHandler) forwardRequest(w http.ResponseWriter, r *http.Request, method, targetURL string, body io.Reader, header http.Header) error {
+	op := "handlers.CoreHandler.forwardRequest"
+	l := logger.EntryWithRequestIDFromContext(r.Context())
+
+	req, err := http.NewRequestWithContext(r.Context(), method, targetURL, body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		l.Error(fmt.Sprintf("%s: %v", op, err))
+	}
+
+	return nil
+}